@@ -0,0 +1,108 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/afranco07/receipt-processor/database"
+	"github.com/afranco07/receipt-processor/handler"
+)
+
+// openPersistent opens the store for a subcommand, which is only
+// useful with a store that outlives it
+func openPersistent(name string, opts *options) (handler.ReceiptHandler, io.Closer) {
+	if opts.dataDir == "" && opts.sqlitePath == "" {
+		log.Fatalf("%s needs -data-dir or -sqlite", name)
+	}
+
+	h, db, err := opts.handler()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	return h, db
+}
+
+// runImport imports the receipts in a CSV file, or stdin if the
+// file is -, into the store
+func runImport(args []string) {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	var opts options
+	opts.register(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s import [flags] file.csv\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	in := os.Stdin
+	if path := fs.Arg(0); path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer f.Close()
+		in = f
+	}
+
+	h, db := openPersistent("import", &opts)
+	results, err := h.ImportCSV(in)
+	closeStore(db)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	imported := 0
+	for _, result := range results {
+		if result.ID != "" {
+			imported++
+			fmt.Printf("%s (line %d): %s\n", result.Receipt, result.Line, result.ID)
+			continue
+		}
+
+		fmt.Printf("%s (line %d): %s\n", result.Receipt, result.Line, result.Error)
+	}
+	fmt.Printf("imported %d of %d receipts\n", imported, len(results))
+}
+
+// runExport writes every stored receipt and its points as CSV to
+// stdout, or the file set by -o
+func runExport(args []string) {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	var opts options
+	opts.register(fs)
+	output := fs.String("o", "", "file to write the CSV to instead of stdout")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s export [flags]\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+
+	out := os.Stdout
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			log.Fatal(err)
+		}
+		out = f
+	}
+
+	h, db := openPersistent("export", &opts)
+	err := h.ExportCSV(out, database.ListQuery{})
+	closeStore(db)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err := out.Close(); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -1,9 +1,14 @@
 package database
 
 import (
+	"cmp"
 	"crypto/sha256"
 	"encoding/json"
 	"errors"
+	"hash/fnv"
+	"slices"
+	"sync"
+	"time"
 
 	"github.com/afranco07/receipt-processor/receipt"
 	"github.com/google/uuid"
@@ -12,58 +17,331 @@ import (
 var (
 	ErrNotFound             = errors.New("not found")
 	ErrReceiptAlreadyExists = errors.New("receipt already exists")
+	ErrVersionNotScored     = errors.New("receipt has not been scored with this version")
 )
 
-type InMemoryDatabase struct {
-	data    map[string]int
+// shardCount is the number of independently locked shards the
+// receipts and their hashes are spread over
+const shardCount = 32
+
+// StoredReceipt is a receipt as it was submitted along with
+// when it was received
+type StoredReceipt struct {
+	ID         string
+	Receipt    receipt.Receipt
+	ReceivedAt time.Time
+}
+
+// RescoreResult is the outcome of rescoring the stored receipts
+type RescoreResult struct {
+	// Rescored is the number of receipts scored with the rules
+	Rescored int
+	// Failed are the receipts the rules could not score, ordered
+	// by id. They keep the scores they already had
+	Failed []RescoreFailure
+}
+
+// RescoreFailure is a stored receipt the rules could not score
+type RescoreFailure struct {
+	ID      string
+	Receipt receipt.Receipt
+	Err     error
+}
+
+// fail records a receipt the rules could not score
+func (r *RescoreResult) fail(id string, rcpt receipt.Receipt, err error) {
+	r.Failed = append(r.Failed, RescoreFailure{ID: id, Receipt: rcpt, Err: err})
+}
+
+// sortFailed orders the failures by id, since receipts are not
+// rescored in any particular order
+func (r *RescoreResult) sortFailed() {
+	slices.SortFunc(r.Failed, func(a, b RescoreFailure) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+}
+
+// record is what is stored for every processed receipt
+type record struct {
+	receipt    receipt.Receipt
+	receivedAt time.Time
+	breakdown  receipt.Breakdown
+	// rescores holds the breakdowns of the receipt under other
+	// versions of the rules, keyed by version. The original
+	// breakdown is never overwritten
+	rescores map[string]receipt.Breakdown
+	hash     string
+}
+
+// dataShard holds the records whose id hashes to the shard
+type dataShard struct {
+	mu   sync.RWMutex
+	data map[string]*record
+}
+
+// hashShard holds the receipt hashes that hash to the shard
+type hashShard struct {
+	mu      sync.Mutex
 	hashMap map[string]struct{}
 }
 
+// InMemoryDatabase is a store that is safe for concurrent use. The
+// records and the hashes used to detect duplicates are spread over
+// shards with their own locks so requests rarely wait on each other
+type InMemoryDatabase struct {
+	data   [shardCount]dataShard
+	hashes [shardCount]hashShard
+	index  *index
+}
+
 func NewInMemoryDatabase() *InMemoryDatabase {
-	return &InMemoryDatabase{
-		data:    make(map[string]int),
-		hashMap: make(map[string]struct{}),
+	db := &InMemoryDatabase{index: newIndex()}
+	for i := range shardCount {
+		db.data[i].data = make(map[string]*record)
+		db.hashes[i].hashMap = make(map[string]struct{})
 	}
+
+	return db
 }
 
-func (db *InMemoryDatabase) Insert(receipt receipt.Receipt, score int) (string, error) {
-	exists, err := db.check(receipt)
-	if err != nil {
+func (db *InMemoryDatabase) Insert(receipt receipt.Receipt, breakdown receipt.Breakdown) (string, error) {
+	id := uuid.NewString()
+	if err := db.insert(id, &record{receipt: receipt, receivedAt: time.Now().UTC(), breakdown: breakdown}); err != nil {
 		return "", err
-	} else if exists {
-		return "", ErrReceiptAlreadyExists
 	}
 
-	id := uuid.NewString()
-	db.data[id] = score
 	return id, nil
 }
 
+// insert stores the record under the id unless the receipt
+// has been submitted already
+func (db *InMemoryDatabase) insert(id string, rec *record) error {
+	hash, exists, err := db.check(rec.receipt)
+	if err != nil {
+		return err
+	} else if exists {
+		return ErrReceiptAlreadyExists
+	}
+
+	rec.hash = hash
+
+	shard := db.dataShard(id)
+	shard.mu.Lock()
+	shard.data[id] = rec
+	shard.mu.Unlock()
+
+	db.index.add(id, rec)
+
+	return nil
+}
+
+// delete removes the record and its hash so the receipt can
+// be submitted again
+func (db *InMemoryDatabase) delete(id string) {
+	shard := db.dataShard(id)
+	shard.mu.Lock()
+	rec, ok := shard.data[id]
+	delete(shard.data, id)
+	shard.mu.Unlock()
+
+	if !ok {
+		return
+	}
+
+	db.index.remove(id, rec)
+
+	hashes := db.hashShard(rec.hash)
+	hashes.mu.Lock()
+	delete(hashes.hashMap, rec.hash)
+	hashes.mu.Unlock()
+}
+
 func (db *InMemoryDatabase) Get(key string) (int, error) {
-	score, ok := db.data[key]
+	shard := db.dataShard(key)
+	shard.mu.RLock()
+	defer shard.mu.RUnlock()
+
+	rec, ok := shard.data[key]
 	if !ok {
 		return 0, ErrNotFound
 	}
 
-	return score, nil
+	return rec.breakdown.Points, nil
+}
+
+// GetReceipt returns the receipt as it was submitted
+func (db *InMemoryDatabase) GetReceipt(key string) (StoredReceipt, error) {
+	shard := db.dataShard(key)
+	shard.mu.RLock()
+	defer shard.mu.RUnlock()
+
+	rec, ok := shard.data[key]
+	if !ok {
+		return StoredReceipt{}, ErrNotFound
+	}
+
+	return StoredReceipt{ID: key, Receipt: rec.receipt, ReceivedAt: rec.receivedAt}, nil
+}
+
+// GetBreakdown returns the per-rule breakdown of the points
+// awarded to the receipt. An empty version returns the
+// breakdown the receipt was originally scored with, any
+// other version one produced by Rescore
+func (db *InMemoryDatabase) GetBreakdown(key, version string) (receipt.Breakdown, error) {
+	shard := db.dataShard(key)
+	shard.mu.RLock()
+	defer shard.mu.RUnlock()
+
+	rec, ok := shard.data[key]
+	if !ok {
+		return receipt.Breakdown{}, ErrNotFound
+	}
+
+	if version == "" || version == rec.breakdown.Version {
+		return rec.breakdown, nil
+	}
+
+	breakdown, ok := rec.rescores[version]
+	if !ok {
+		return receipt.Breakdown{}, ErrVersionNotScored
+	}
+
+	return breakdown, nil
+}
+
+// Rescore scores every stored receipt with the rules and keeps
+// the result next to the original score. A receipt the rules cannot
+// score is reported in the result instead of stopping the rescore
+func (db *InMemoryDatabase) Rescore(rules *receipt.RuleSet) (RescoreResult, error) {
+	return db.rescore(rules, nil)
+}
+
+// rescore scores every stored receipt with the rules, calling
+// saved, if set, after each new breakdown is kept. Receipts are
+// scored one shard at a time without holding its lock
+func (db *InMemoryDatabase) rescore(rules *receipt.RuleSet, saved func(id string, breakdown receipt.Breakdown) error) (RescoreResult, error) {
+	var result RescoreResult
+
+	for i := range db.data {
+		shard := &db.data[i]
+
+		pending := make(map[string]*record)
+		shard.mu.RLock()
+		for id, rec := range shard.data {
+			if rec.breakdown.Version != rules.Version() {
+				pending[id] = rec
+			}
+		}
+		shard.mu.RUnlock()
+
+		for id, rec := range pending {
+			breakdown, err := rules.Score(rec.receipt)
+			if err != nil {
+				result.fail(id, rec.receipt, err)
+				continue
+			}
+
+			shard.mu.Lock()
+			rec.setRescore(breakdown)
+			shard.mu.Unlock()
+			result.Rescored++
+
+			if saved != nil {
+				if err := saved(id, breakdown); err != nil {
+					return result, err
+				}
+			}
+		}
+	}
+	result.sortFailed()
+
+	return result, nil
+}
+
+// each calls fn for every stored record while holding the lock
+// of the shard it is in
+func (db *InMemoryDatabase) each(fn func(id string, rec *record)) {
+	for i := range db.data {
+		shard := &db.data[i]
+		shard.mu.RLock()
+		for id, rec := range shard.data {
+			fn(id, rec)
+		}
+		shard.mu.RUnlock()
+	}
+}
+
+// update calls fn with the record stored under the id while
+// holding the lock of its shard
+func (db *InMemoryDatabase) update(id string, fn func(rec *record)) error {
+	shard := db.dataShard(id)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	rec, ok := shard.data[id]
+	if !ok {
+		return ErrNotFound
+	}
+
+	fn(rec)
+
+	return nil
+}
+
+func (db *InMemoryDatabase) dataShard(id string) *dataShard {
+	return &db.data[shardIndex(id)]
+}
+
+func (db *InMemoryDatabase) hashShard(hash string) *hashShard {
+	return &db.hashes[shardIndex(hash)]
+}
+
+func shardIndex(key string) uint32 {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(key))
+
+	return h.Sum32() % shardCount
+}
+
+func (rec *record) setRescore(breakdown receipt.Breakdown) {
+	if rec.rescores == nil {
+		rec.rescores = make(map[string]receipt.Breakdown)
+	}
+	rec.rescores[breakdown.Version] = breakdown
 }
 
 // check checks if the receipt has been submitted already by checking is
-// sha256 hash
-func (db *InMemoryDatabase) check(receipt receipt.Receipt) (bool, error) {
-	b, err := json.Marshal(receipt)
+// sha256 hash. The hash is returned so the receipt can be removed. Checking
+// and recording the hash happen under the same lock, so only one of two
+// identical receipts submitted at the same time is accepted
+func (db *InMemoryDatabase) check(receipt receipt.Receipt) (string, bool, error) {
+	hashString, err := hashReceipt(receipt)
 	if err != nil {
-		return false, err
+		return "", false, err
 	}
 
-	hash := sha256.Sum256(b)
-	hashString := string(hash[:])
+	shard := db.hashShard(hashString)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	if _, ok := shard.hashMap[hashString]; ok {
+		return hashString, true, nil
+	}
+
+	shard.hashMap[hashString] = struct{}{}
 
-	if _, ok := db.hashMap[hashString]; ok {
-		return true, nil
+	return hashString, false, nil
+}
+
+// hashReceipt returns the sha256 hash used to detect receipts that
+// have been submitted already
+func hashReceipt(receipt receipt.Receipt) (string, error) {
+	b, err := json.Marshal(receipt)
+	if err != nil {
+		return "", err
 	}
 
-	db.hashMap[hashString] = struct{}{}
+	hash := sha256.Sum256(b)
 
-	return false, nil
+	return string(hash[:]), nil
 }
@@ -0,0 +1,218 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/afranco07/receipt-processor/receipt"
+)
+
+func countRecords(db *InMemoryDatabase) int {
+	count := 0
+	db.each(func(string, *record) { count++ })
+
+	return count
+}
+
+func TestInMemoryDatabase_ConcurrentInserts(t *testing.T) {
+	db := NewInMemoryDatabase()
+	breakdown := receipt.Breakdown{Points: 10, Version: receipt.DefaultVersion}
+
+	const workers = 16
+	const perWorker = 200
+
+	var wg sync.WaitGroup
+	ids := make(chan string, workers*perWorker)
+	for w := range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range perWorker {
+				id, err := db.Insert(testReceipt(fmt.Sprintf("Store %d-%d", w, i)), breakdown)
+				if err != nil {
+					t.Errorf("Insert() error = %v", err)
+					return
+				}
+				ids <- id
+
+				if _, err := db.Get(id); err != nil {
+					t.Errorf("Get() error = %v", err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(ids)
+
+	seen := make(map[string]struct{})
+	for id := range ids {
+		seen[id] = struct{}{}
+	}
+
+	if len(seen) != workers*perWorker || countRecords(db) != workers*perWorker {
+		t.Errorf("expected %d receipts, got %d ids and %d records", workers*perWorker, len(seen), countRecords(db))
+	}
+}
+
+func TestInMemoryDatabase_ConcurrentDuplicates(t *testing.T) {
+	for round := range 50 {
+		db := NewInMemoryDatabase()
+		rcpt := testReceipt(fmt.Sprintf("Duplicate %d", round))
+
+		const workers = 8
+		var accepted, rejected atomic.Int32
+		var wg sync.WaitGroup
+		start := make(chan struct{})
+		for range workers {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				<-start
+				_, err := db.Insert(rcpt, receipt.Breakdown{})
+				switch {
+				case err == nil:
+					accepted.Add(1)
+				case errors.Is(err, ErrReceiptAlreadyExists):
+					rejected.Add(1)
+				default:
+					t.Errorf("Insert() error = %v", err)
+				}
+			}()
+		}
+		close(start)
+		wg.Wait()
+
+		if accepted.Load() != 1 || rejected.Load() != workers-1 {
+			t.Fatalf("expected exactly 1 of %d identical receipts to be accepted, got %d accepted and %d rejected",
+				workers, accepted.Load(), rejected.Load())
+		}
+	}
+}
+
+func TestInMemoryDatabase_ConcurrentRescore(t *testing.T) {
+	db := NewInMemoryDatabase()
+
+	var ids []string
+	for i := range 100 {
+		ids = append(ids, insertScored(t, db, testReceipt(fmt.Sprintf("Store %d", i))))
+	}
+
+	cfg := receipt.DefaultConfig()
+	cfg.Version = "3"
+	v3, err := receipt.NewConfigRuleSet(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(3)
+	go func() {
+		defer wg.Done()
+		if _, err := db.Rescore(v3); err != nil {
+			t.Errorf("Rescore() error = %v", err)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for _, id := range ids {
+			_, err := db.GetBreakdown(id, "3")
+			if err != nil && !errors.Is(err, ErrVersionNotScored) {
+				t.Errorf("GetBreakdown() error = %v", err)
+			}
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := range 100 {
+			if _, err := db.Insert(testReceipt(fmt.Sprintf("New store %d", i)), receipt.Breakdown{}); err != nil {
+				t.Errorf("Insert() error = %v", err)
+			}
+		}
+	}()
+	wg.Wait()
+
+	for _, id := range ids {
+		if _, err := db.GetBreakdown(id, "3"); err != nil {
+			t.Errorf("GetBreakdown() after rescoring error = %v", err)
+		}
+	}
+}
+
+func BenchmarkInMemoryDatabase_Insert(b *testing.B) {
+	db := NewInMemoryDatabase()
+	breakdown := receipt.Breakdown{Points: 10, Version: receipt.DefaultVersion}
+
+	receipts := make([]receipt.Receipt, b.N)
+	for i := range receipts {
+		receipts[i] = testReceipt(fmt.Sprintf("Store %d", i))
+	}
+
+	var next atomic.Int64
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			i := next.Add(1) - 1
+			if _, err := db.Insert(receipts[i], breakdown); err != nil {
+				b.Error(err)
+			}
+		}
+	})
+}
+
+// BenchmarkInMemoryDatabase_InsertLarge inserts into a store that
+// already has 200k receipts, where keeping the list index sorted
+// must not serialize the inserts
+func BenchmarkInMemoryDatabase_InsertLarge(b *testing.B) {
+	db := NewInMemoryDatabase()
+	breakdown := receipt.Breakdown{Points: 10, Version: receipt.DefaultVersion}
+
+	for i := range 200_000 {
+		if _, err := db.Insert(testReceipt(fmt.Sprintf("Existing store %d", i)), breakdown); err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	receipts := make([]receipt.Receipt, b.N)
+	for i := range receipts {
+		receipts[i] = testReceipt(fmt.Sprintf("Store %d", i))
+	}
+
+	var next atomic.Int64
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			i := next.Add(1) - 1
+			if _, err := db.Insert(receipts[i], breakdown); err != nil {
+				b.Error(err)
+			}
+		}
+	})
+}
+
+func BenchmarkInMemoryDatabase_GetBreakdown(b *testing.B) {
+	db := NewInMemoryDatabase()
+	breakdown := receipt.Breakdown{Points: 10, Version: receipt.DefaultVersion}
+
+	ids := make([]string, 1000)
+	for i := range ids {
+		id, err := db.Insert(testReceipt(fmt.Sprintf("Store %d", i)), breakdown)
+		if err != nil {
+			b.Fatal(err)
+		}
+		ids[i] = id
+	}
+
+	var next atomic.Int64
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			i := next.Add(1)
+			if _, err := db.GetBreakdown(ids[i%int64(len(ids))], ""); err != nil {
+				b.Error(err)
+			}
+		}
+	})
+}
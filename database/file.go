@@ -0,0 +1,442 @@
+package database
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/afranco07/receipt-processor/receipt"
+	"github.com/google/uuid"
+)
+
+const (
+	walFileName      = "receipts.wal"
+	snapshotFileName = "receipts.snapshot"
+
+	// walHeaderSize is the length and checksum written before
+	// every log entry
+	walHeaderSize = 8
+
+	// maxEntrySize guards against allocating a corrupted length
+	maxEntrySize = 16 << 20
+
+	defaultCompactEvery = 1000
+)
+
+const (
+	opInsert  = "insert"
+	opRescore = "rescore"
+)
+
+// walEntry is a single change appended to the write-ahead log
+type walEntry struct {
+	// Seq numbers the entries in the order they were written, so
+	// entries already in a snapshot can be skipped
+	Seq        uint64             `json:"seq"`
+	Op         string             `json:"op"`
+	ID         string             `json:"id"`
+	Receipt    *receipt.Receipt   `json:"receipt,omitempty"`
+	ReceivedAt time.Time          `json:"receivedAt"`
+	Breakdown  *receipt.Breakdown `json:"breakdown"`
+}
+
+// snapshot is every stored receipt and the sequence number of the
+// last log entry it includes
+type snapshot struct {
+	Seq     uint64           `json:"seq"`
+	Records []snapshotRecord `json:"records"`
+}
+
+// snapshotRecord is a stored receipt as written in a snapshot
+type snapshotRecord struct {
+	ID         string                       `json:"id"`
+	Receipt    receipt.Receipt              `json:"receipt"`
+	ReceivedAt time.Time                    `json:"receivedAt"`
+	Breakdown  receipt.Breakdown            `json:"breakdown"`
+	Rescores   map[string]receipt.Breakdown `json:"rescores,omitempty"`
+}
+
+// FileDatabase is a store that keeps every receipt in memory and
+// persists every change to a write-ahead log in a directory. The
+// log is periodically compacted into a snapshot, and both are
+// replayed when the database is opened
+type FileDatabase struct {
+	// mu serializes writes to the log, reads are served by mem
+	mu           sync.Mutex
+	mem          *InMemoryDatabase
+	dir          string
+	wal          *os.File
+	walEntries   int
+	compactEvery int
+	// seq is the sequence number of the last log entry, and
+	// snapshotSeq the one of the last entry in the snapshot
+	seq         uint64
+	snapshotSeq uint64
+}
+
+// FileOption configures a FileDatabase
+type FileOption func(*FileDatabase)
+
+// WithCompactEvery sets the number of log entries after which the
+// log is compacted into a snapshot
+func WithCompactEvery(n int) FileOption {
+	return func(db *FileDatabase) {
+		db.compactEvery = n
+	}
+}
+
+// NewFileDatabase opens the database stored in dir, creating it if
+// needed. A log that ends in a partially written entry, such as
+// after a crash, is truncated to the last complete entry
+func NewFileDatabase(dir string, opts ...FileOption) (*FileDatabase, error) {
+	db := &FileDatabase{
+		mem:          NewInMemoryDatabase(),
+		dir:          dir,
+		compactEvery: defaultCompactEvery,
+	}
+
+	for _, opt := range opts {
+		opt(db)
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	if err := db.loadSnapshot(); err != nil {
+		return nil, err
+	}
+
+	if err := db.replay(); err != nil {
+		return nil, err
+	}
+
+	return db, nil
+}
+
+func (db *FileDatabase) Insert(rcpt receipt.Receipt, breakdown receipt.Breakdown) (string, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	id := uuid.NewString()
+	receivedAt := time.Now().UTC()
+	if err := db.mem.insert(id, &record{receipt: rcpt, receivedAt: receivedAt, breakdown: breakdown}); err != nil {
+		return "", err
+	}
+
+	entry := walEntry{Op: opInsert, ID: id, Receipt: &rcpt, ReceivedAt: receivedAt, Breakdown: &breakdown}
+	if err := db.append(entry); err != nil {
+		db.mem.delete(id)
+		return "", err
+	}
+
+	if err := db.maybeCompact(); err != nil {
+		log.Printf("error compacting database: %v", err)
+	}
+
+	return id, nil
+}
+
+func (db *FileDatabase) Get(key string) (int, error) {
+	return db.mem.Get(key)
+}
+
+// GetReceipt returns the receipt as it was submitted
+func (db *FileDatabase) GetReceipt(key string) (StoredReceipt, error) {
+	return db.mem.GetReceipt(key)
+}
+
+// GetBreakdown returns the per-rule breakdown of the points
+// awarded to the receipt, see InMemoryDatabase.GetBreakdown
+func (db *FileDatabase) GetBreakdown(key, version string) (receipt.Breakdown, error) {
+	return db.mem.GetBreakdown(key, version)
+}
+
+// Rescore scores every stored receipt with the rules and logs
+// every new breakdown, see InMemoryDatabase.Rescore
+func (db *FileDatabase) Rescore(rules *receipt.RuleSet) (RescoreResult, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	result, err := db.mem.rescore(rules, func(id string, breakdown receipt.Breakdown) error {
+		return db.append(walEntry{Op: opRescore, ID: id, Breakdown: &breakdown})
+	})
+	if err != nil {
+		return result, err
+	}
+
+	if err := db.maybeCompact(); err != nil {
+		log.Printf("error compacting database: %v", err)
+	}
+
+	return result, nil
+}
+
+// Compact writes every stored receipt to a new snapshot and
+// empties the log
+func (db *FileDatabase) Compact() error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	return db.compact()
+}
+
+// Close compacts the log and closes the database
+func (db *FileDatabase) Close() error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if err := db.compact(); err != nil {
+		return err
+	}
+
+	return db.wal.Close()
+}
+
+// append writes the entry to the end of the log, prefixed by its
+// length and checksum, and syncs it to disk
+func (db *FileDatabase) append(entry walEntry) error {
+	db.seq++
+	entry.Seq = db.seq
+
+	payload, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	buf := make([]byte, walHeaderSize+len(payload))
+	binary.BigEndian.PutUint32(buf[0:4], uint32(len(payload)))
+	binary.BigEndian.PutUint32(buf[4:8], crc32.ChecksumIEEE(payload))
+	copy(buf[walHeaderSize:], payload)
+
+	if _, err := db.wal.Write(buf); err != nil {
+		return err
+	}
+
+	if err := db.wal.Sync(); err != nil {
+		return err
+	}
+
+	db.walEntries++
+
+	return nil
+}
+
+func (db *FileDatabase) maybeCompact() error {
+	if db.compactEvery <= 0 || db.walEntries < db.compactEvery {
+		return nil
+	}
+
+	return db.compact()
+}
+
+// compact writes the snapshot to a temporary file and renames it
+// over the previous one before the log is emptied. A crash before
+// the rename leaves the old snapshot and the full log, and a crash
+// after it leaves entries in the log that are already in the new
+// snapshot, which replay skips by their sequence number
+func (db *FileDatabase) compact() error {
+	snap := snapshot{Seq: db.seq}
+	db.mem.each(func(id string, rec *record) {
+		snap.Records = append(snap.Records, snapshotRecord{
+			ID:         id,
+			Receipt:    rec.receipt,
+			ReceivedAt: rec.receivedAt,
+			Breakdown:  rec.breakdown,
+			Rescores:   rec.rescores,
+		})
+	})
+
+	tmp, err := os.CreateTemp(db.dir, snapshotFileName+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	w := bufio.NewWriter(tmp)
+	if err := json.NewEncoder(w).Encode(snap); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+
+	if err := w.Flush(); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+
+	if err := tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmp.Name(), filepath.Join(db.dir, snapshotFileName)); err != nil {
+		return err
+	}
+
+	if err := db.wal.Truncate(0); err != nil {
+		return err
+	}
+
+	if _, err := db.wal.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	db.walEntries = 0
+	db.snapshotSeq = snap.Seq
+
+	return db.wal.Sync()
+}
+
+func (db *FileDatabase) loadSnapshot() error {
+	f, err := os.Open(filepath.Join(db.dir, snapshotFileName))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var raw json.RawMessage
+	if err := json.NewDecoder(bufio.NewReader(f)).Decode(&raw); err != nil {
+		return fmt.Errorf("error reading snapshot: %w", err)
+	}
+
+	// snapshots written before sequence numbers are only the records
+	var snap snapshot
+	if raw[0] == '[' {
+		err = json.Unmarshal(raw, &snap.Records)
+	} else {
+		err = json.Unmarshal(raw, &snap)
+	}
+	if err != nil {
+		return fmt.Errorf("error reading snapshot: %w", err)
+	}
+	db.seq, db.snapshotSeq = snap.Seq, snap.Seq
+
+	for _, r := range snap.Records {
+		rec := &record{receipt: r.Receipt, receivedAt: r.ReceivedAt, breakdown: r.Breakdown, rescores: r.Rescores}
+		if err := db.mem.insert(r.ID, rec); err != nil {
+			return fmt.Errorf("error loading receipt %s from snapshot: %w", r.ID, err)
+		}
+	}
+
+	return nil
+}
+
+// replay applies every complete entry in the log that is not
+// already in the snapshot and truncates anything after the last one
+func (db *FileDatabase) replay() error {
+	f, err := os.OpenFile(filepath.Join(db.dir, walFileName), os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return err
+	}
+
+	r := bufio.NewReader(f)
+	var offset int64
+	for {
+		entry, n, err := readEntry(r)
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			log.Printf("truncating write-ahead log at offset %d: %v", offset, err)
+			if err := f.Truncate(offset); err != nil {
+				_ = f.Close()
+				return err
+			}
+			break
+		}
+
+		// entries before the snapshot are left behind by a crash
+		// between writing it and emptying the log
+		if db.snapshotSeq == 0 || entry.Seq > db.snapshotSeq {
+			if err := db.apply(entry); err != nil {
+				_ = f.Close()
+				return fmt.Errorf("error replaying write-ahead log at offset %d: %w", offset, err)
+			}
+		}
+		db.seq = max(db.seq, entry.Seq)
+
+		offset += n
+		db.walEntries++
+	}
+
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		_ = f.Close()
+		return err
+	}
+
+	db.wal = f
+
+	return nil
+}
+
+func (db *FileDatabase) apply(entry walEntry) error {
+	if entry.Breakdown == nil {
+		return fmt.Errorf("%s entry for %s has no breakdown", entry.Op, entry.ID)
+	}
+
+	switch entry.Op {
+	case opInsert:
+		if entry.Receipt == nil {
+			return fmt.Errorf("insert entry for %s has no receipt", entry.ID)
+		}
+		return db.mem.insert(entry.ID, &record{receipt: *entry.Receipt, receivedAt: entry.ReceivedAt, breakdown: *entry.Breakdown})
+	case opRescore:
+		err := db.mem.update(entry.ID, func(rec *record) {
+			rec.setRescore(*entry.Breakdown)
+		})
+		if err != nil {
+			return fmt.Errorf("rescore entry for receipt %s: %w", entry.ID, err)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown operation %q", entry.Op)
+	}
+}
+
+// readEntry reads the next entry in the log and the number of
+// bytes it took. io.EOF is only returned at the end of a complete
+// entry, a partially written entry returns io.ErrUnexpectedEOF
+func readEntry(r io.Reader) (walEntry, int64, error) {
+	var header [walHeaderSize]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return walEntry{}, 0, err
+	}
+
+	size := binary.BigEndian.Uint32(header[0:4])
+	if size > maxEntrySize {
+		return walEntry{}, 0, fmt.Errorf("entry size %d is too large", size)
+	}
+
+	payload := make([]byte, size)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+		return walEntry{}, 0, err
+	}
+
+	if crc32.ChecksumIEEE(payload) != binary.BigEndian.Uint32(header[4:8]) {
+		return walEntry{}, 0, errors.New("checksum mismatch")
+	}
+
+	var entry walEntry
+	if err := json.Unmarshal(payload, &entry); err != nil {
+		return walEntry{}, 0, err
+	}
+
+	return entry, int64(walHeaderSize + size), nil
+}
@@ -0,0 +1,238 @@
+package database
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/afranco07/receipt-processor/receipt"
+)
+
+func openFileDatabase(t *testing.T, dir string, opts ...FileOption) *FileDatabase {
+	t.Helper()
+
+	db, err := NewFileDatabase(dir, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return db
+}
+
+func TestFileDatabase_Reopen(t *testing.T) {
+	dir := t.TempDir()
+
+	db := openFileDatabase(t, dir)
+	target := testReceipt("Target")
+	id := insertScored(t, db, target)
+
+	cfg := receipt.DefaultConfig()
+	cfg.Version = "3"
+	v3, err := receipt.NewConfigRuleSet(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := db.Rescore(v3); err != nil {
+		t.Fatal(err)
+	}
+
+	// simulate a crash by not closing the database
+	db = openFileDatabase(t, dir)
+
+	got, err := db.GetBreakdown(id, "")
+	if err != nil {
+		t.Fatalf("GetBreakdown() error = %v", err)
+	}
+
+	want, _ := receipt.DefaultRuleSet().Score(target)
+	if got.Points != want.Points || got.Version != receipt.DefaultVersion {
+		t.Errorf("GetBreakdown() = %d points with version %s, want %d with version %s", got.Points, got.Version, want.Points, receipt.DefaultVersion)
+	}
+
+	if _, err := db.GetBreakdown(id, "3"); err != nil {
+		t.Errorf("GetBreakdown() for the rescore error = %v", err)
+	}
+
+	if _, err := db.Insert(target, want); !errors.Is(err, ErrReceiptAlreadyExists) {
+		t.Errorf("Insert() of a duplicate after reopening error = %v, want %v", err, ErrReceiptAlreadyExists)
+	}
+
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	db = openFileDatabase(t, dir)
+	if _, err := db.GetBreakdown(id, "3"); err != nil {
+		t.Errorf("GetBreakdown() for the rescore after closing error = %v", err)
+	}
+}
+
+func TestFileDatabase_Compact(t *testing.T) {
+	dir := t.TempDir()
+
+	db := openFileDatabase(t, dir, WithCompactEvery(2))
+	ids := []string{
+		insertScored(t, db, testReceipt("Target")),
+		insertScored(t, db, testReceipt("Walgreens")),
+		insertScored(t, db, testReceipt("Costco")),
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, snapshotFileName)); err != nil {
+		t.Fatalf("expected a snapshot to be written: %v", err)
+	}
+
+	if db.walEntries != 1 {
+		t.Errorf("expected 1 entry in the log after compacting, got %d", db.walEntries)
+	}
+
+	db = openFileDatabase(t, dir, WithCompactEvery(2))
+	for _, id := range ids {
+		if _, err := db.Get(id); err != nil {
+			t.Errorf("Get(%s) error = %v", id, err)
+		}
+	}
+
+	if _, err := db.Insert(testReceipt("Target"), receipt.Breakdown{}); !errors.Is(err, ErrReceiptAlreadyExists) {
+		t.Errorf("Insert() of a duplicate from the snapshot error = %v, want %v", err, ErrReceiptAlreadyExists)
+	}
+}
+
+func TestFileDatabase_CrashAfterSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	walPath := filepath.Join(dir, walFileName)
+
+	db := openFileDatabase(t, dir, WithCompactEvery(0))
+	ids := []string{
+		insertScored(t, db, testReceipt("Target")),
+		insertScored(t, db, testReceipt("Walgreens")),
+	}
+
+	cfg := receipt.DefaultConfig()
+	cfg.Version = "3"
+	v3, err := receipt.NewConfigRuleSet(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := db.Rescore(v3); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := os.ReadFile(walPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// simulate a crash after the snapshot is written but before the
+	// log is emptied by putting the old log back
+	if err := db.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(walPath, entries, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	db = openFileDatabase(t, dir, WithCompactEvery(0))
+	if count := countRecords(db.mem); count != len(ids) {
+		t.Errorf("expected %d receipts after reopening, got %d", len(ids), count)
+	}
+
+	for _, id := range ids {
+		if _, err := db.GetBreakdown(id, "3"); err != nil {
+			t.Errorf("GetBreakdown(%s) for the rescore error = %v", id, err)
+		}
+	}
+
+	// entries written after reopening are not mistaken for old ones
+	third := insertScored(t, db, testReceipt("Costco"))
+	db = openFileDatabase(t, dir, WithCompactEvery(0))
+	if _, err := db.Get(third); err != nil {
+		t.Errorf("the receipt written after reopening was lost: %v", err)
+	}
+}
+
+func TestFileDatabase_TruncatedLog(t *testing.T) {
+	walPath := func(dir string) string { return filepath.Join(dir, walFileName) }
+
+	// write two entries to find where the second one starts
+	dir := t.TempDir()
+	db := openFileDatabase(t, dir, WithCompactEvery(0))
+	first := insertScored(t, db, testReceipt("Target"))
+	info, err := os.Stat(walPath(dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	firstSize := info.Size()
+
+	insertScored(t, db, testReceipt("Walgreens"))
+	full, err := os.ReadFile(walPath(dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		corrupt func([]byte) []byte
+	}{
+		{
+			name:    "cut inside the header",
+			corrupt: func(b []byte) []byte { return b[:firstSize+3] },
+		},
+		{
+			name:    "cut after the header",
+			corrupt: func(b []byte) []byte { return b[:firstSize+walHeaderSize] },
+		},
+		{
+			name:    "cut inside the payload",
+			corrupt: func(b []byte) []byte { return b[:len(b)-10] },
+		},
+		{
+			name:    "cut before the last byte",
+			corrupt: func(b []byte) []byte { return b[:len(b)-1] },
+		},
+		{
+			name: "flipped byte in the payload",
+			corrupt: func(b []byte) []byte {
+				c := append([]byte(nil), b...)
+				c[len(c)-5] ^= 0xff
+				return c
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if err := os.WriteFile(walPath(dir), tt.corrupt(full), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			db := openFileDatabase(t, dir, WithCompactEvery(0))
+			if _, err := db.Get(first); err != nil {
+				t.Errorf("the first receipt was lost: %v", err)
+			}
+
+			if count := countRecords(db.mem); count != 1 {
+				t.Errorf("expected only the first receipt to be replayed, got %d receipts", count)
+			}
+
+			info, err := os.Stat(walPath(dir))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if info.Size() != firstSize {
+				t.Errorf("expected the log to be truncated to %d bytes, got %d", firstSize, info.Size())
+			}
+
+			// the torn receipt can be submitted again and survives a reopen
+			second := insertScored(t, db, testReceipt("Walgreens"))
+			db = openFileDatabase(t, dir, WithCompactEvery(0))
+			if _, err := db.Get(second); err != nil {
+				t.Errorf("the receipt written after truncating was lost: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,475 @@
+package database
+
+import (
+	"cmp"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/afranco07/receipt-processor/receipt"
+)
+
+var (
+	ErrInvalidCursor = errors.New("invalid cursor")
+	ErrInvalidSort   = errors.New("invalid sort")
+)
+
+// SortField is the field receipts are listed in order of
+type SortField string
+
+const (
+	SortReceivedAt   SortField = "receivedAt"
+	SortPurchaseDate SortField = "purchaseDate"
+	SortPoints       SortField = "points"
+)
+
+const (
+	DefaultListLimit = 50
+	MaxListLimit     = 500
+)
+
+// sortFields are the fields that are indexed so receipts can be
+// filtered and listed in their order
+var sortFields = []SortField{SortReceivedAt, SortPurchaseDate, SortPoints}
+
+// ListQuery selects the receipts to list. Zero values do not
+// filter and every range is inclusive
+type ListQuery struct {
+	// Retailer matches the retailer name ignoring case and
+	// surrounding spaces
+	Retailer      string
+	PurchasedFrom time.Time
+	PurchasedTo   time.Time
+	MinPoints     *int
+	MaxPoints     *int
+	ReceivedFrom  time.Time
+	ReceivedTo    time.Time
+
+	// Sort defaults to SortReceivedAt
+	Sort       SortField
+	Descending bool
+	// Limit defaults to DefaultListLimit and is capped at
+	// MaxListLimit
+	Limit int
+	// Cursor is the NextCursor of the previous page
+	Cursor string
+}
+
+// ListedReceipt is a stored receipt along with the points it was
+// originally awarded
+type ListedReceipt struct {
+	StoredReceipt
+	Points  int
+	Version string
+}
+
+// ReceiptPage is a page of listed receipts. NextCursor is empty
+// on the last page
+type ReceiptPage struct {
+	Receipts   []ListedReceipt
+	NextCursor string
+}
+
+// cursor is the position after the last receipt of a page. The
+// id breaks ties between receipts with the same sort key so every
+// receipt is listed exactly once
+type cursor struct {
+	Sort       SortField `json:"s"`
+	Descending bool      `json:"d,omitempty"`
+	Key        int64     `json:"k"`
+	ID         string    `json:"id"`
+}
+
+func (c cursor) encode() string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeCursor(s string) (cursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return cursor{}, ErrInvalidCursor
+	}
+
+	var c cursor
+	if err := json.Unmarshal(b, &c); err != nil || c.ID == "" {
+		return cursor{}, ErrInvalidCursor
+	}
+
+	return c, nil
+}
+
+// normalize applies the defaults of the query and decodes its
+// cursor, which must have been created with the same order
+func (q ListQuery) normalize() (ListQuery, *cursor, error) {
+	if q.Sort == "" {
+		q.Sort = SortReceivedAt
+	}
+
+	if !slices.Contains(sortFields, q.Sort) {
+		return q, nil, fmt.Errorf("%w: %q", ErrInvalidSort, q.Sort)
+	}
+
+	if q.Limit <= 0 {
+		q.Limit = DefaultListLimit
+	} else if q.Limit > MaxListLimit {
+		q.Limit = MaxListLimit
+	}
+
+	if q.Cursor == "" {
+		return q, nil, nil
+	}
+
+	c, err := decodeCursor(q.Cursor)
+	if err != nil {
+		return q, nil, err
+	}
+
+	if c.Sort != q.Sort || c.Descending != q.Descending {
+		return q, nil, fmt.Errorf("%w: cursor was created for a different order", ErrInvalidCursor)
+	}
+
+	return q, &c, nil
+}
+
+// keyRange is an inclusive range of sort keys
+type keyRange struct {
+	min, max int64
+}
+
+// ranges returns the range of keys the query allows for every
+// field it filters on
+func (q ListQuery) ranges() map[SortField]keyRange {
+	ranges := make(map[SortField]keyRange)
+
+	if !q.ReceivedFrom.IsZero() || !q.ReceivedTo.IsZero() {
+		r := keyRange{min: math.MinInt64, max: math.MaxInt64}
+		if !q.ReceivedFrom.IsZero() {
+			r.min = receivedKey(q.ReceivedFrom)
+		}
+		if !q.ReceivedTo.IsZero() {
+			r.max = receivedKey(q.ReceivedTo)
+		}
+		ranges[SortReceivedAt] = r
+	}
+
+	if !q.PurchasedFrom.IsZero() || !q.PurchasedTo.IsZero() {
+		r := keyRange{min: math.MinInt64, max: math.MaxInt64}
+		if !q.PurchasedFrom.IsZero() {
+			r.min = q.PurchasedFrom.Unix()
+		}
+		if !q.PurchasedTo.IsZero() {
+			r.max = q.PurchasedTo.Unix()
+		}
+		ranges[SortPurchaseDate] = r
+	}
+
+	if q.MinPoints != nil || q.MaxPoints != nil {
+		r := keyRange{min: math.MinInt64, max: math.MaxInt64}
+		if q.MinPoints != nil {
+			r.min = int64(*q.MinPoints)
+		}
+		if q.MaxPoints != nil {
+			r.max = int64(*q.MaxPoints)
+		}
+		ranges[SortPoints] = r
+	}
+
+	return ranges
+}
+
+// sortKey returns the key of the receipt in the order of the field
+func sortKey(field SortField, rcpt receipt.Receipt, receivedAt time.Time, points int) int64 {
+	switch field {
+	case SortPurchaseDate:
+		return time.Time(rcpt.PurchaseDate).Unix()
+	case SortPoints:
+		return int64(points)
+	default:
+		return receivedKey(receivedAt)
+	}
+}
+
+// receivedKey orders receipts stored before the time they were
+// received was kept first
+func receivedKey(t time.Time) int64 {
+	if t.IsZero() {
+		return math.MinInt64
+	}
+
+	return t.UnixNano()
+}
+
+// retailerKey is the retailer name as it is matched when filtering
+func retailerKey(retailer string) string {
+	return strings.ToLower(strings.TrimSpace(retailer))
+}
+
+// nextCursor returns the cursor of the page following the
+// receipts, if there are more
+func nextCursor(q ListQuery, receipts []ListedReceipt, more bool) string {
+	if !more || len(receipts) == 0 {
+		return ""
+	}
+
+	last := receipts[len(receipts)-1]
+	c := cursor{
+		Sort:       q.Sort,
+		Descending: q.Descending,
+		Key:        sortKey(q.Sort, last.Receipt, last.ReceivedAt, last.Points),
+		ID:         last.ID,
+	}
+
+	return c.encode()
+}
+
+// indexEntry is a receipt in a sorted index
+type indexEntry struct {
+	key int64
+	id  string
+}
+
+// compare orders entries by key, then id
+func (e indexEntry) compare(other indexEntry) int {
+	if c := cmp.Compare(e.key, other.key); c != 0 {
+		return c
+	}
+
+	return strings.Compare(e.id, other.id)
+}
+
+func (e indexEntry) less(other indexEntry) bool {
+	return e.compare(other) < 0
+}
+
+// index holds the secondary indexes of the in-memory store, so
+// listing receipts does not scan every record. It is sharded like
+// the records, so an insert only waits on inserts to the same
+// shard, and the matches of every shard are merged when listing
+type index struct {
+	shards [shardCount]indexShard
+}
+
+// indexShard indexes the receipts of one data shard
+type indexShard struct {
+	mu        sync.RWMutex
+	retailers map[string]map[string]struct{}
+	sorted    map[SortField]*tree
+}
+
+func newIndex() *index {
+	idx := &index{}
+	for i := range shardCount {
+		shard := &idx.shards[i]
+		shard.retailers = make(map[string]map[string]struct{})
+		shard.sorted = make(map[SortField]*tree)
+		for _, field := range sortFields {
+			shard.sorted[field] = &tree{}
+		}
+	}
+
+	return idx
+}
+
+func (idx *index) add(id string, rec *record) {
+	shard := &idx.shards[shardIndex(id)]
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	key := retailerKey(rec.receipt.Retailer)
+	if shard.retailers[key] == nil {
+		shard.retailers[key] = make(map[string]struct{})
+	}
+	shard.retailers[key][id] = struct{}{}
+
+	for _, field := range sortFields {
+		shard.sorted[field].insert(indexEntry{key: rec.sortKey(field), id: id})
+	}
+}
+
+func (idx *index) remove(id string, rec *record) {
+	shard := &idx.shards[shardIndex(id)]
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	key := retailerKey(rec.receipt.Retailer)
+	delete(shard.retailers[key], id)
+	if len(shard.retailers[key]) == 0 {
+		delete(shard.retailers, key)
+	}
+
+	for _, field := range sortFields {
+		shard.sorted[field].delete(indexEntry{key: rec.sortKey(field), id: id})
+	}
+}
+
+// find returns the ids of up to limit receipts matching the query
+// that come after the cursor in its order. Every shard finds its
+// own first matches, which are then merged
+func (idx *index) find(q ListQuery, after *cursor, limit int) []string {
+	var entries []indexEntry
+	for i := range idx.shards {
+		entries = append(entries, idx.shards[i].find(q, after, limit)...)
+	}
+
+	slices.SortFunc(entries, indexEntry.compare)
+	if q.Descending {
+		slices.Reverse(entries)
+	}
+	if len(entries) > limit {
+		entries = entries[:limit]
+	}
+
+	ids := make([]string, 0, len(entries))
+	for _, e := range entries {
+		ids = append(ids, e.id)
+	}
+
+	return ids
+}
+
+// find returns the entries of up to limit receipts of the shard
+// matching the query that come after the cursor in its order. The
+// other filters are applied by intersecting the matching ids before
+// walking the index of the sort field
+func (s *indexShard) find(q ListQuery, after *cursor, limit int) []indexEntry {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	// candidates is nil when every receipt matches
+	var candidates map[string]struct{}
+	if q.Retailer != "" {
+		candidates = s.retailers[retailerKey(q.Retailer)]
+		if len(candidates) == 0 {
+			return nil
+		}
+	}
+
+	ranges := q.ranges()
+	for field, r := range ranges {
+		if field == q.Sort {
+			continue
+		}
+
+		candidates = s.matching(field, r, candidates)
+		if len(candidates) == 0 {
+			return nil
+		}
+	}
+
+	r, ok := ranges[q.Sort]
+	if !ok {
+		r = keyRange{min: math.MinInt64, max: math.MaxInt64}
+	}
+
+	var entries []indexEntry
+	visit := func(e indexEntry) bool {
+		if e.key < r.min || e.key > r.max {
+			return false
+		}
+
+		if after != nil && e.key == after.Key && e.id == after.ID {
+			return true
+		}
+
+		if candidates != nil {
+			if _, ok := candidates[e.id]; !ok {
+				return true
+			}
+		}
+
+		entries = append(entries, e)
+		return len(entries) < limit
+	}
+
+	var last *indexEntry
+	if after != nil {
+		last = &indexEntry{key: after.Key, id: after.ID}
+	}
+
+	if !q.Descending {
+		from := indexEntry{key: r.min}
+		if last != nil && from.less(*last) {
+			from = *last
+		}
+		s.sorted[q.Sort].ascend(from, visit)
+	} else {
+		to := last
+		if r.max < math.MaxInt64 && (to == nil || to.key > r.max) {
+			to = &indexEntry{key: r.max + 1}
+		}
+		s.sorted[q.Sort].descend(to, visit)
+	}
+
+	return entries
+}
+
+// matching returns the candidates with a key of the field in the
+// range. nil candidates match every receipt
+func (s *indexShard) matching(field SortField, r keyRange, candidates map[string]struct{}) map[string]struct{} {
+	matches := make(map[string]struct{})
+	s.sorted[field].ascend(indexEntry{key: r.min}, func(e indexEntry) bool {
+		if e.key > r.max {
+			return false
+		}
+
+		if candidates == nil {
+			matches[e.id] = struct{}{}
+		} else if _, ok := candidates[e.id]; ok {
+			matches[e.id] = struct{}{}
+		}
+
+		return true
+	})
+
+	return matches
+}
+
+func (rec *record) sortKey(field SortField) int64 {
+	return sortKey(field, rec.receipt, rec.receivedAt, rec.breakdown.Points)
+}
+
+// List returns a page of the receipts matching the query
+func (db *InMemoryDatabase) List(q ListQuery) (ReceiptPage, error) {
+	q, after, err := q.normalize()
+	if err != nil {
+		return ReceiptPage{}, err
+	}
+
+	// one more receipt than requested tells if there is a next page
+	ids := db.index.find(q, after, q.Limit+1)
+	more := len(ids) > q.Limit
+	if more {
+		ids = ids[:q.Limit]
+	}
+
+	page := ReceiptPage{Receipts: make([]ListedReceipt, 0, len(ids))}
+	for _, id := range ids {
+		shard := db.dataShard(id)
+		shard.mu.RLock()
+		rec, ok := shard.data[id]
+		if ok {
+			page.Receipts = append(page.Receipts, ListedReceipt{
+				StoredReceipt: StoredReceipt{ID: id, Receipt: rec.receipt, ReceivedAt: rec.receivedAt},
+				Points:        rec.breakdown.Points,
+				Version:       rec.breakdown.Version,
+			})
+		}
+		shard.mu.RUnlock()
+	}
+
+	page.NextCursor = nextCursor(q, page.Receipts, more)
+
+	return page, nil
+}
+
+// List returns a page of the receipts matching the query
+func (db *FileDatabase) List(q ListQuery) (ReceiptPage, error) {
+	return db.mem.List(q)
+}
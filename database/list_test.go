@@ -0,0 +1,215 @@
+package database
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/afranco07/receipt-processor/receipt"
+)
+
+func datedReceipt(retailer, date string) receipt.Receipt {
+	var r receipt.Receipt
+	body := fmt.Sprintf(`{"retailer": %q, "purchaseDate": %q, "purchaseTime": "13:01", "total": "6.49",
+		"items": [{"shortDescription": "Mountain Dew 12PK", "price": "6.49"}]}`, retailer, date)
+	if err := json.Unmarshal([]byte(body), &r); err != nil {
+		panic(err)
+	}
+
+	return r
+}
+
+func day(date string) time.Time {
+	t, err := time.Parse(time.DateOnly, date)
+	if err != nil {
+		panic(err)
+	}
+
+	return t
+}
+
+// listAll follows the cursors until the last page
+func listAll(t *testing.T, db interface {
+	List(ListQuery) (ReceiptPage, error)
+}, q ListQuery) []ListedReceipt {
+	t.Helper()
+
+	var all []ListedReceipt
+	for range 100 {
+		page, err := db.List(q)
+		if err != nil {
+			t.Fatalf("List() error = %v", err)
+		}
+
+		if len(page.Receipts) > q.Limit {
+			t.Fatalf("List() returned %d receipts, want at most %d", len(page.Receipts), q.Limit)
+		}
+
+		all = append(all, page.Receipts...)
+		if page.NextCursor == "" {
+			return all
+		}
+		q.Cursor = page.NextCursor
+	}
+
+	t.Fatal("List() did not reach the last page")
+	return nil
+}
+
+func TestStores_List(t *testing.T) {
+	receipts := []receipt.Receipt{
+		datedReceipt("Target", "2022-01-01"),
+		datedReceipt("Target", "2022-01-02"),
+		datedReceipt(" target ", "2022-01-03"),
+		datedReceipt("Walgreens", "2022-01-02"),
+		datedReceipt("Walgreens", "2022-02-01"),
+		datedReceipt("M&M Corner Market", "2022-03-20"),
+		datedReceipt("M&M Corner Market", "2022-03-21"),
+	}
+
+	minPoints, maxPoints := 15, 20
+
+	tests := []struct {
+		name  string
+		query ListQuery
+		match func(ListedReceipt) bool
+		count int
+	}{
+		{
+			name:  "every receipt in the order received",
+			query: ListQuery{},
+			match: func(ListedReceipt) bool { return true },
+			count: 7,
+		},
+		{
+			name:  "retailer ignores case and spaces",
+			query: ListQuery{Retailer: "TARGET"},
+			match: func(l ListedReceipt) bool { return retailerKey(l.Receipt.Retailer) == "target" },
+			count: 3,
+		},
+		{
+			name:  "purchase date range is inclusive",
+			query: ListQuery{PurchasedFrom: day("2022-01-02"), PurchasedTo: day("2022-02-01"), Sort: SortPurchaseDate},
+			match: func(l ListedReceipt) bool {
+				d := time.Time(l.Receipt.PurchaseDate)
+				return !d.Before(day("2022-01-02")) && !d.After(day("2022-02-01"))
+			},
+			count: 4,
+		},
+		{
+			name:  "points range sorted by points descending",
+			query: ListQuery{MinPoints: &minPoints, MaxPoints: &maxPoints, Sort: SortPoints, Descending: true},
+			match: func(l ListedReceipt) bool { return l.Points >= minPoints && l.Points <= maxPoints },
+			count: 2,
+		},
+		{
+			name:  "retailer and purchase date sorted by points",
+			query: ListQuery{Retailer: "walgreens", PurchasedFrom: day("2022-01-15"), Sort: SortPoints},
+			match: func(l ListedReceipt) bool {
+				return l.Receipt.Retailer == "Walgreens" && time.Time(l.Receipt.PurchaseDate).After(day("2022-01-15"))
+			},
+			count: 1,
+		},
+		{
+			name:  "unknown retailer",
+			query: ListQuery{Retailer: "Costco"},
+			match: func(ListedReceipt) bool { return false },
+			count: 0,
+		},
+	}
+
+	for name, db := range stores(t) {
+		t.Run(name, func(t *testing.T) {
+			db := db.(interface {
+				store
+				List(ListQuery) (ReceiptPage, error)
+			})
+
+			var inserted []ListedReceipt
+			for _, r := range receipts {
+				id := insertScored(t, db, r)
+
+				stored, err := db.GetReceipt(id)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				breakdown, _ := db.GetBreakdown(id, "")
+				inserted = append(inserted, ListedReceipt{StoredReceipt: stored, Points: breakdown.Points, Version: breakdown.Version})
+			}
+
+			// receipts received since the first one
+			all := listAll(t, db, ListQuery{ReceivedFrom: inserted[0].ReceivedAt, Limit: 100})
+			if len(all) != len(inserted) {
+				t.Errorf("List() with received from = %d receipts, want %d", len(all), len(inserted))
+			}
+
+			for _, tt := range tests {
+				t.Run(tt.name, func(t *testing.T) {
+					q := tt.query
+					q.Limit = 2
+					sortBy := q.Sort
+					if sortBy == "" {
+						sortBy = SortReceivedAt
+					}
+
+					var want []string
+					for _, l := range inserted {
+						if tt.match(l) {
+							want = append(want, l.ID)
+						}
+					}
+
+					key := func(id string) indexEntry {
+						i := slices.IndexFunc(inserted, func(l ListedReceipt) bool { return l.ID == id })
+						l := inserted[i]
+						return indexEntry{key: sortKey(sortBy, l.Receipt, l.ReceivedAt, l.Points), id: id}
+					}
+					slices.SortFunc(want, func(a, b string) int {
+						less := key(a).less(key(b))
+						if q.Descending {
+							less = key(b).less(key(a))
+						}
+						if less {
+							return -1
+						}
+						return 1
+					})
+
+					if len(want) != tt.count {
+						t.Fatalf("expected %d matching receipts, got %d", tt.count, len(want))
+					}
+
+					var got []string
+					for _, l := range listAll(t, db, q) {
+						got = append(got, l.ID)
+					}
+
+					if !slices.Equal(got, want) {
+						t.Errorf("List() = %v, want %v", got, want)
+					}
+				})
+			}
+
+			if _, err := db.List(ListQuery{Cursor: "not a cursor"}); !errors.Is(err, ErrInvalidCursor) {
+				t.Errorf("List() with an invalid cursor error = %v, want %v", err, ErrInvalidCursor)
+			}
+
+			page, err := db.List(ListQuery{Limit: 1})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := db.List(ListQuery{Limit: 1, Sort: SortPoints, Cursor: page.NextCursor}); !errors.Is(err, ErrInvalidCursor) {
+				t.Errorf("List() with a cursor of another order error = %v, want %v", err, ErrInvalidCursor)
+			}
+
+			if _, err := db.List(ListQuery{Sort: "retailer"}); !errors.Is(err, ErrInvalidSort) {
+				t.Errorf("List() with an invalid sort error = %v, want %v", err, ErrInvalidSort)
+			}
+		})
+	}
+}
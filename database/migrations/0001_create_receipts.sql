@@ -0,0 +1,35 @@
+CREATE TABLE receipts (
+    id            TEXT PRIMARY KEY,
+    retailer      TEXT    NOT NULL,
+    purchase_date TEXT    NOT NULL,
+    purchase_time TEXT    NOT NULL,
+    total_cents   INTEGER NOT NULL,
+    -- the receipt as submitted, used to score it again
+    body          TEXT    NOT NULL
+);
+
+CREATE TABLE items (
+    receipt_id        TEXT    NOT NULL REFERENCES receipts (id) ON DELETE CASCADE,
+    position          INTEGER NOT NULL,
+    short_description TEXT    NOT NULL,
+    price_cents       INTEGER NOT NULL,
+    PRIMARY KEY (receipt_id, position)
+);
+
+CREATE TABLE scores (
+    receipt_id TEXT    NOT NULL REFERENCES receipts (id) ON DELETE CASCADE,
+    version    TEXT    NOT NULL,
+    points     INTEGER NOT NULL,
+    breakdown  TEXT    NOT NULL,
+    -- the score the receipt was awarded when it was submitted,
+    -- every other row is a rescore
+    original   INTEGER NOT NULL,
+    PRIMARY KEY (receipt_id, version)
+);
+
+CREATE UNIQUE INDEX scores_original ON scores (receipt_id) WHERE original = 1;
+
+CREATE TABLE receipt_hashes (
+    hash       TEXT PRIMARY KEY,
+    receipt_id TEXT NOT NULL REFERENCES receipts (id) ON DELETE CASCADE
+);
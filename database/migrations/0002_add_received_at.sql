@@ -0,0 +1,2 @@
+-- receipts stored before this migration have no received time
+ALTER TABLE receipts ADD COLUMN received_at TEXT NOT NULL DEFAULT '';
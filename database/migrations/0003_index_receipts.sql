@@ -0,0 +1,10 @@
+-- retailer_key is the retailer name as it is matched when listing
+-- receipts, see retailerKey
+ALTER TABLE receipts ADD COLUMN retailer_key TEXT NOT NULL DEFAULT '';
+
+UPDATE receipts SET retailer_key = lower(trim(retailer));
+
+CREATE INDEX receipts_retailer_key ON receipts (retailer_key);
+CREATE INDEX receipts_received_at ON receipts (received_at, id);
+CREATE INDEX receipts_purchase_date ON receipts (purchase_date, id);
+CREATE INDEX scores_points ON scores (points, receipt_id) WHERE original = 1;
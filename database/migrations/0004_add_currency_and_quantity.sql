@@ -0,0 +1,18 @@
+-- the amounts of a receipt are in the minor units of its currency
+ALTER TABLE receipts ADD COLUMN currency TEXT NOT NULL DEFAULT 'USD';
+
+-- an item without a quantity or unit price is a single unit at its
+-- price, see receipt.Item
+ALTER TABLE items ADD COLUMN quantity INTEGER;
+ALTER TABLE items ADD COLUMN unit_price_cents INTEGER;
+
+-- receipts stored before this migration only have them in their
+-- body. Amounts are stored with exactly the decimal places of the
+-- currency, so their minor units are their digits
+UPDATE receipts SET currency = COALESCE(json_extract(body, '$.currency'), 'USD');
+
+UPDATE items SET
+    quantity = (SELECT json_extract(r.body, '$.items[' || items.position || '].quantity')
+        FROM receipts r WHERE r.id = items.receipt_id),
+    unit_price_cents = (SELECT CAST(replace(json_extract(r.body, '$.items[' || items.position || '].unitPrice'), '.', '') AS INTEGER)
+        FROM receipts r WHERE r.id = items.receipt_id);
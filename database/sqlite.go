@@ -0,0 +1,473 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/afranco07/receipt-processor/receipt"
+	"github.com/google/uuid"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+// receivedAtLayout has a fixed width so the times sort in order
+const receivedAtLayout = "2006-01-02T15:04:05.000000000Z"
+
+// sortColumns are the columns receipts are listed in order of
+var sortColumns = map[SortField]string{
+	SortReceivedAt:   "r.received_at",
+	SortPurchaseDate: "r.purchase_date",
+	SortPoints:       "s.points",
+}
+
+// SQLiteDatabase is a store backed by a SQLite database file
+type SQLiteDatabase struct {
+	db *sql.DB
+}
+
+// NewSQLiteDatabase opens the SQLite database at path, creating it
+// if needed, and migrates it to the latest schema
+func NewSQLiteDatabase(path string) (*SQLiteDatabase, error) {
+	dsn := fmt.Sprintf("file:%s?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)", path)
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	// SQLite only allows a single writer
+	db.SetMaxOpenConns(1)
+
+	if err := migrate(context.Background(), db); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+
+	return &SQLiteDatabase{db: db}, nil
+}
+
+// Close closes the database
+func (db *SQLiteDatabase) Close() error {
+	return db.db.Close()
+}
+
+func (db *SQLiteDatabase) Insert(rcpt receipt.Receipt, breakdown receipt.Breakdown) (string, error) {
+	hash, err := hashReceipt(rcpt)
+	if err != nil {
+		return "", err
+	}
+
+	body, err := json.Marshal(rcpt)
+	if err != nil {
+		return "", err
+	}
+
+	var cols struct {
+		PurchaseDate string `json:"purchaseDate"`
+		PurchaseTime string `json:"purchaseTime"`
+	}
+	if err := json.Unmarshal(body, &cols); err != nil {
+		return "", err
+	}
+
+	scored, err := json.Marshal(breakdown)
+	if err != nil {
+		return "", err
+	}
+
+	id := uuid.NewString()
+	receivedAt := time.Now().UTC()
+	err = db.inTx(func(tx *sql.Tx) error {
+		_, err := tx.Exec(
+			`INSERT INTO receipts (id, retailer, retailer_key, purchase_date, purchase_time, total_cents, currency, body, received_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			id, rcpt.Retailer, retailerKey(rcpt.Retailer), cols.PurchaseDate, cols.PurchaseTime, rcpt.Total.Minor(),
+			rcpt.CurrencyCode(), string(body), receivedAt.Format(receivedAtLayout),
+		)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(`INSERT INTO receipt_hashes (hash, receipt_id) VALUES (?, ?)`, hex.EncodeToString([]byte(hash)), id)
+		if isConstraintError(err) {
+			return ErrReceiptAlreadyExists
+		} else if err != nil {
+			return err
+		}
+
+		for i, item := range rcpt.Items {
+			var unitPrice *int64
+			if item.UnitPrice != nil {
+				unitPrice = new(int64)
+				*unitPrice = item.UnitPrice.Minor()
+			}
+
+			_, err := tx.Exec(
+				`INSERT INTO items (receipt_id, position, short_description, price_cents, quantity, unit_price_cents)
+				VALUES (?, ?, ?, ?, ?, ?)`,
+				id, i, item.ShortDescription, item.Price.Minor(), item.Quantity, unitPrice,
+			)
+			if err != nil {
+				return err
+			}
+		}
+
+		_, err = tx.Exec(
+			`INSERT INTO scores (receipt_id, version, points, breakdown, original) VALUES (?, ?, ?, ?, 1)`,
+			id, breakdown.Version, breakdown.Points, string(scored),
+		)
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return id, nil
+}
+
+func (db *SQLiteDatabase) Get(key string) (int, error) {
+	breakdown, err := db.GetBreakdown(key, "")
+	if err != nil {
+		return 0, err
+	}
+
+	return breakdown.Points, nil
+}
+
+// GetReceipt returns the receipt as it was submitted
+func (db *SQLiteDatabase) GetReceipt(key string) (StoredReceipt, error) {
+	var body, receivedAt string
+	err := db.db.QueryRow(`SELECT body, received_at FROM receipts WHERE id = ?`, key).Scan(&body, &receivedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return StoredReceipt{}, ErrNotFound
+	} else if err != nil {
+		return StoredReceipt{}, err
+	}
+
+	return storedReceipt(key, body, receivedAt)
+}
+
+// storedReceipt reads a receipt from its row
+func storedReceipt(id, body, receivedAt string) (StoredReceipt, error) {
+	stored := StoredReceipt{ID: id}
+	if err := json.Unmarshal([]byte(body), &stored.Receipt); err != nil {
+		return StoredReceipt{}, fmt.Errorf("error reading receipt %s: %w", id, err)
+	}
+
+	if receivedAt != "" {
+		var err error
+		stored.ReceivedAt, err = time.Parse(time.RFC3339Nano, receivedAt)
+		if err != nil {
+			return StoredReceipt{}, err
+		}
+	}
+
+	return stored, nil
+}
+
+// List returns a page of the receipts matching the query, see
+// InMemoryDatabase.List
+func (db *SQLiteDatabase) List(q ListQuery) (ReceiptPage, error) {
+	q, after, err := q.normalize()
+	if err != nil {
+		return ReceiptPage{}, err
+	}
+
+	var where []string
+	var args []any
+	if q.Retailer != "" {
+		where = append(where, "r.retailer_key = ?")
+		args = append(args, retailerKey(q.Retailer))
+	}
+
+	for field, r := range q.ranges() {
+		col := sortColumns[field]
+		if r.min != math.MinInt64 {
+			where = append(where, col+" >= ?")
+			args = append(args, sortValue(field, r.min))
+		}
+		if r.max != math.MaxInt64 {
+			where = append(where, col+" <= ?")
+			args = append(args, sortValue(field, r.max))
+		}
+	}
+
+	col := sortColumns[q.Sort]
+	op, dir := ">", "ASC"
+	if q.Descending {
+		op, dir = "<", "DESC"
+	}
+
+	if after != nil {
+		where = append(where, fmt.Sprintf("(%[1]s %[2]s ? OR (%[1]s = ? AND r.id %[2]s ?))", col, op))
+		key := sortValue(q.Sort, after.Key)
+		args = append(args, key, key, after.ID)
+	}
+
+	query := `SELECT r.id, r.body, r.received_at, s.points, s.version FROM receipts r
+		JOIN scores s ON s.receipt_id = r.id AND s.original = 1`
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	// one more receipt than requested tells if there is a next page
+	query += fmt.Sprintf(" ORDER BY %[1]s %[2]s, r.id %[2]s LIMIT ?", col, dir)
+	args = append(args, q.Limit+1)
+
+	rows, err := db.db.Query(query, args...)
+	if err != nil {
+		return ReceiptPage{}, err
+	}
+	defer rows.Close()
+
+	page := ReceiptPage{Receipts: make([]ListedReceipt, 0, q.Limit)}
+	for rows.Next() {
+		var id, body, receivedAt string
+		var listed ListedReceipt
+		if err := rows.Scan(&id, &body, &receivedAt, &listed.Points, &listed.Version); err != nil {
+			return ReceiptPage{}, err
+		}
+
+		listed.StoredReceipt, err = storedReceipt(id, body, receivedAt)
+		if err != nil {
+			return ReceiptPage{}, err
+		}
+
+		page.Receipts = append(page.Receipts, listed)
+	}
+	if err := rows.Err(); err != nil {
+		return ReceiptPage{}, err
+	}
+
+	more := len(page.Receipts) > q.Limit
+	if more {
+		page.Receipts = page.Receipts[:q.Limit]
+	}
+	page.NextCursor = nextCursor(q, page.Receipts, more)
+
+	return page, nil
+}
+
+// sortValue converts a sort key to the value stored in the
+// column of the field
+func sortValue(field SortField, key int64) any {
+	switch field {
+	case SortPurchaseDate:
+		return time.Unix(key, 0).UTC().Format(time.DateOnly)
+	case SortPoints:
+		return key
+	default:
+		if key == math.MinInt64 {
+			return ""
+		}
+		return time.Unix(0, key).UTC().Format(receivedAtLayout)
+	}
+}
+
+// GetBreakdown returns the per-rule breakdown of the points
+// awarded to the receipt, see InMemoryDatabase.GetBreakdown
+func (db *SQLiteDatabase) GetBreakdown(key, version string) (receipt.Breakdown, error) {
+	query := `SELECT breakdown FROM scores WHERE receipt_id = ? AND original = 1`
+	args := []any{key}
+	if version != "" {
+		query = `SELECT breakdown FROM scores WHERE receipt_id = ? AND version = ?`
+		args = append(args, version)
+	}
+
+	var body string
+	err := db.db.QueryRow(query, args...).Scan(&body)
+	if errors.Is(err, sql.ErrNoRows) {
+		var exists bool
+		if err := db.db.QueryRow(`SELECT EXISTS (SELECT 1 FROM receipts WHERE id = ?)`, key).Scan(&exists); err != nil {
+			return receipt.Breakdown{}, err
+		}
+
+		if exists {
+			return receipt.Breakdown{}, ErrVersionNotScored
+		}
+		return receipt.Breakdown{}, ErrNotFound
+	} else if err != nil {
+		return receipt.Breakdown{}, err
+	}
+
+	var breakdown receipt.Breakdown
+	if err := json.Unmarshal([]byte(body), &breakdown); err != nil {
+		return receipt.Breakdown{}, err
+	}
+
+	return breakdown, nil
+}
+
+// Rescore scores every stored receipt with the rules and keeps
+// the result next to the original score, see
+// InMemoryDatabase.Rescore
+func (db *SQLiteDatabase) Rescore(rules *receipt.RuleSet) (RescoreResult, error) {
+	var result RescoreResult
+	err := db.inTx(func(tx *sql.Tx) error {
+		rows, err := tx.Query(
+			`SELECT r.id, r.body FROM receipts r
+			JOIN scores s ON s.receipt_id = r.id AND s.original = 1
+			WHERE s.version != ?`,
+			rules.Version(),
+		)
+		if err != nil {
+			return err
+		}
+
+		bodies := make(map[string]string)
+		for rows.Next() {
+			var id, body string
+			if err := rows.Scan(&id, &body); err != nil {
+				_ = rows.Close()
+				return err
+			}
+			bodies[id] = body
+		}
+		if err := rows.Close(); err != nil {
+			return err
+		}
+
+		for id, body := range bodies {
+			var rcpt receipt.Receipt
+			if err := json.Unmarshal([]byte(body), &rcpt); err != nil {
+				return fmt.Errorf("error reading receipt %s: %w", id, err)
+			}
+
+			breakdown, err := rules.Score(rcpt)
+			if err != nil {
+				result.fail(id, rcpt, err)
+				continue
+			}
+
+			scored, err := json.Marshal(breakdown)
+			if err != nil {
+				return err
+			}
+
+			_, err = tx.Exec(
+				`INSERT INTO scores (receipt_id, version, points, breakdown, original) VALUES (?, ?, ?, ?, 0)
+				ON CONFLICT (receipt_id, version) DO UPDATE SET points = excluded.points, breakdown = excluded.breakdown`,
+				id, breakdown.Version, breakdown.Points, string(scored),
+			)
+			if err != nil {
+				return err
+			}
+			result.Rescored++
+		}
+
+		return nil
+	})
+	if err != nil {
+		return RescoreResult{}, err
+	}
+	result.sortFailed()
+
+	return result, nil
+}
+
+func (db *SQLiteDatabase) inTx(fn func(*sql.Tx) error) error {
+	tx, err := db.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	if err := fn(tx); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func isConstraintError(err error) bool {
+	var sqliteErr *sqlite.Error
+	if !errors.As(err, &sqliteErr) {
+		return false
+	}
+
+	return sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY || sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE
+}
+
+// migrate applies every embedded migration that has not been
+// applied yet, in order. Migrations are named <version>_<name>.sql
+// and each one runs in its own transaction
+func migrate(ctx context.Context, db *sql.DB) error {
+	_, err := db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    INTEGER PRIMARY KEY,
+		applied_at TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP
+	)`)
+	if err != nil {
+		return err
+	}
+
+	var current int
+	if err := db.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&current); err != nil {
+		return err
+	}
+
+	files, err := fs.Glob(migrations, "migrations/*.sql")
+	if err != nil {
+		return err
+	}
+
+	type migration struct {
+		version int
+		file    string
+	}
+
+	var pending []migration
+	for _, file := range files {
+		prefix, _, _ := strings.Cut(path.Base(file), "_")
+		version, err := strconv.Atoi(prefix)
+		if err != nil {
+			return fmt.Errorf("migration %s must start with its version: %w", file, err)
+		}
+
+		if version > current {
+			pending = append(pending, migration{version: version, file: file})
+		}
+	}
+
+	sort.Slice(pending, func(i, j int) bool { return pending[i].version < pending[j].version })
+
+	for _, m := range pending {
+		script, err := migrations.ReadFile(m.file)
+		if err != nil {
+			return err
+		}
+
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+
+		if _, err := tx.ExecContext(ctx, string(script)); err != nil {
+			_ = tx.Rollback()
+			return fmt.Errorf("error applying migration %s: %w", m.file, err)
+		}
+
+		if _, err := tx.ExecContext(ctx, `INSERT INTO schema_migrations (version) VALUES (?)`, m.version); err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,159 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/afranco07/receipt-processor/receipt"
+)
+
+func TestSQLiteDatabase_Reopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "receipts.db")
+
+	db, err := NewSQLiteDatabase(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	target := testReceipt("Target")
+	id := insertScored(t, db, target)
+
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// opening the database again must not apply the migrations twice
+	db, err = NewSQLiteDatabase(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	var migrations int
+	if err := db.db.QueryRow(`SELECT COUNT(*) FROM schema_migrations`).Scan(&migrations); err != nil {
+		t.Fatal(err)
+	}
+
+	if migrations != 4 {
+		t.Errorf("expected 4 applied migrations, got %d", migrations)
+	}
+
+	if _, err := db.Get(id); err != nil {
+		t.Errorf("Get() error = %v", err)
+	}
+
+	if _, err := db.Insert(target, receiptBreakdown(t, target)); !errors.Is(err, ErrReceiptAlreadyExists) {
+		t.Errorf("Insert() error = %v, want %v", err, ErrReceiptAlreadyExists)
+	}
+
+	var items int
+	if err := db.db.QueryRow(`SELECT COUNT(*) FROM items WHERE receipt_id = ?`, id).Scan(&items); err != nil {
+		t.Fatal(err)
+	}
+
+	if items != len(target.Items) {
+		t.Errorf("expected %d items, got %d", len(target.Items), items)
+	}
+
+	// a failed insert leaves nothing behind
+	var receipts int
+	if err := db.db.QueryRow(`SELECT COUNT(*) FROM receipts`).Scan(&receipts); err != nil {
+		t.Fatal(err)
+	}
+
+	if receipts != 1 {
+		t.Errorf("expected 1 receipt, got %d", receipts)
+	}
+}
+
+func TestSQLiteDatabase_CurrencyAndQuantity(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "receipts.db")
+
+	db, err := NewSQLiteDatabase(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var rcpt receipt.Receipt
+	body := `{"retailer": "Lidl", "purchaseDate": "2022-01-01", "purchaseTime": "13:01", "total": "7.50", "currency": "EUR",
+		"items": [{"shortDescription": "Apples", "price": "7.50", "quantity": 3, "unitPrice": "2.50"},
+			{"shortDescription": "Bag", "price": "0.00"}]}`
+	if err := json.Unmarshal([]byte(body), &rcpt); err != nil {
+		t.Fatal(err)
+	}
+
+	id, err := db.Insert(rcpt, receipt.Breakdown{Version: receipt.DefaultVersion})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// check reads the columns of the receipt and its items
+	check := func(t *testing.T, db *SQLiteDatabase) {
+		t.Helper()
+
+		var currency string
+		if err := db.db.QueryRow(`SELECT currency FROM receipts WHERE id = ?`, id).Scan(&currency); err != nil {
+			t.Fatal(err)
+		}
+
+		if currency != "EUR" {
+			t.Errorf("the currency did not match. Got %q, want %q", currency, "EUR")
+		}
+
+		var quantity, unitPrice sql.NullInt64
+		err := db.db.QueryRow(`SELECT quantity, unit_price_cents FROM items WHERE receipt_id = ? AND position = 0`, id).
+			Scan(&quantity, &unitPrice)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if want := (sql.NullInt64{Int64: 3, Valid: true}); quantity != want {
+			t.Errorf("the quantity did not match. Got %+v, want %+v", quantity, want)
+		}
+		if want := (sql.NullInt64{Int64: 250, Valid: true}); unitPrice != want {
+			t.Errorf("the unit price did not match. Got %+v, want %+v", unitPrice, want)
+		}
+
+		// an item without them is a single unit at its price
+		err = db.db.QueryRow(`SELECT quantity, unit_price_cents FROM items WHERE receipt_id = ? AND position = 1`, id).
+			Scan(&quantity, &unitPrice)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if quantity.Valid || unitPrice.Valid {
+			t.Errorf("the item without a quantity has quantity %+v and unit price %+v, want NULL", quantity, unitPrice)
+		}
+	}
+
+	t.Run("insert", func(t *testing.T) {
+		check(t, db)
+	})
+
+	// a receipt stored before the migration gets the columns from
+	// its body
+	_, err = db.db.Exec(`ALTER TABLE receipts DROP COLUMN currency;
+		ALTER TABLE items DROP COLUMN quantity;
+		ALTER TABLE items DROP COLUMN unit_price_cents;
+		DELETE FROM schema_migrations WHERE version = 4`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err = NewSQLiteDatabase(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	t.Run("migration", func(t *testing.T) {
+		check(t, db)
+	})
+}
@@ -0,0 +1,203 @@
+package database
+
+import (
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/afranco07/receipt-processor/receipt"
+)
+
+// store is the behavior every database implementation shares
+type store interface {
+	Get(string) (int, error)
+	GetBreakdown(id, version string) (receipt.Breakdown, error)
+	GetReceipt(string) (StoredReceipt, error)
+	Insert(receipt.Receipt, receipt.Breakdown) (string, error)
+	Rescore(*receipt.RuleSet) (RescoreResult, error)
+}
+
+func testReceipt(retailer string) receipt.Receipt {
+	var r receipt.Receipt
+	body := `{"retailer": "` + retailer + `", "purchaseDate": "2022-01-01", "purchaseTime": "13:01", "total": "6.49",
+		"items": [{"shortDescription": "Mountain Dew 12PK", "price": "6.49"}]}`
+	if err := json.Unmarshal([]byte(body), &r); err != nil {
+		panic(err)
+	}
+
+	return r
+}
+
+func receiptBreakdown(t *testing.T, r receipt.Receipt) receipt.Breakdown {
+	t.Helper()
+
+	breakdown, err := receipt.DefaultRuleSet().Score(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return breakdown
+}
+
+func insertScored(t *testing.T, db store, r receipt.Receipt) string {
+	t.Helper()
+
+	id, err := db.Insert(r, receiptBreakdown(t, r))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return id
+}
+
+func stores(t *testing.T) map[string]store {
+	t.Helper()
+
+	file, err := NewFileDatabase(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sqlite, err := NewSQLiteDatabase(filepath.Join(t.TempDir(), "receipts.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = sqlite.Close() })
+
+	return map[string]store{
+		"memory": NewInMemoryDatabase(),
+		"file":   file,
+		"sqlite": sqlite,
+	}
+}
+
+func TestStores(t *testing.T) {
+	cfg := receipt.DefaultConfig()
+	cfg.Version = "3"
+	cfg.Retailer.PointsPerCharacter = 2
+	v3, err := receipt.NewConfigRuleSet(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, db := range stores(t) {
+		t.Run(name, func(t *testing.T) {
+			target := testReceipt("Target")
+			original, _ := receipt.DefaultRuleSet().Score(target)
+
+			before := time.Now().UTC()
+			id := insertScored(t, db, target)
+
+			stored, err := db.GetReceipt(id)
+			if err != nil {
+				t.Fatalf("GetReceipt() error = %v", err)
+			}
+
+			if stored.ID != id || stored.Receipt.Retailer != target.Retailer || stored.ReceivedAt.Before(before) {
+				t.Errorf("GetReceipt() = %+v, want the receipt received after %v", stored, before)
+			}
+
+			want, _ := json.Marshal(target)
+			if got, _ := json.Marshal(stored.Receipt); string(got) != string(want) {
+				t.Errorf("GetReceipt() receipt = %s, want %s", got, want)
+			}
+
+			if _, err := db.GetReceipt("does-not-exist"); !errors.Is(err, ErrNotFound) {
+				t.Errorf("GetReceipt() error = %v, want %v", err, ErrNotFound)
+			}
+
+			if got, err := db.Get(id); err != nil || got != original.Points {
+				t.Errorf("Get() = %d, %v, want %d", got, err, original.Points)
+			}
+
+			if _, err := db.Get("does-not-exist"); !errors.Is(err, ErrNotFound) {
+				t.Errorf("Get() error = %v, want %v", err, ErrNotFound)
+			}
+
+			if _, err := db.GetBreakdown("does-not-exist", ""); !errors.Is(err, ErrNotFound) {
+				t.Errorf("GetBreakdown() error = %v, want %v", err, ErrNotFound)
+			}
+
+			if _, err := db.Insert(target, original); !errors.Is(err, ErrReceiptAlreadyExists) {
+				t.Errorf("Insert() error = %v, want %v", err, ErrReceiptAlreadyExists)
+			}
+
+			if _, err := db.GetBreakdown(id, "3"); !errors.Is(err, ErrVersionNotScored) {
+				t.Errorf("GetBreakdown() error = %v, want %v", err, ErrVersionNotScored)
+			}
+
+			insertScored(t, db, testReceipt("Walgreens"))
+
+			result, err := db.Rescore(v3)
+			if err != nil || result.Rescored != 2 || len(result.Failed) != 0 {
+				t.Errorf("Rescore() = %+v, %v, want 2 rescored", result, err)
+			}
+
+			got, err := db.GetBreakdown(id, "3")
+			if err != nil {
+				t.Fatalf("GetBreakdown() error = %v", err)
+			}
+
+			if got.Version != "3" || got.Points != original.Points+6 {
+				t.Errorf("GetBreakdown() = %d points with version %s, want %d with version 3", got.Points, got.Version, original.Points+6)
+			}
+
+			got, err = db.GetBreakdown(id, "")
+			if err != nil || got.Points != original.Points || got.Version != receipt.DefaultVersion {
+				t.Errorf("GetBreakdown() of the original = %+v, %v, want %d points", got, err, original.Points)
+			}
+
+			// a receipt scored with the version is not rescored
+			if result, err := db.Rescore(receipt.DefaultRuleSet()); err != nil || result.Rescored != 0 {
+				t.Errorf("Rescore() with the original version = %+v, %v, want 0 rescored", result, err)
+			}
+		})
+	}
+}
+
+func TestStores_RescoreFailure(t *testing.T) {
+	errNoRate := errors.New("no exchange rate")
+	rules, err := receipt.NewRuleSet("3", receipt.NewRule("walgreens", func(r receipt.Receipt) ([]receipt.RuleResult, error) {
+		if r.Retailer == "Walgreens" {
+			return nil, errNoRate
+		}
+		return nil, nil
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, db := range stores(t) {
+		t.Run(name, func(t *testing.T) {
+			target := insertScored(t, db, testReceipt("Target"))
+			walgreens := insertScored(t, db, testReceipt("Walgreens"))
+
+			result, err := db.Rescore(rules)
+			if err != nil {
+				t.Fatalf("Rescore() error = %v", err)
+			}
+
+			if result.Rescored != 1 {
+				t.Errorf("Rescore() rescored %d receipts, want 1", result.Rescored)
+			}
+
+			if len(result.Failed) != 1 || result.Failed[0].ID != walgreens || !errors.Is(result.Failed[0].Err, errNoRate) {
+				t.Fatalf("Rescore() failed = %+v, want only %s", result.Failed, walgreens)
+			}
+
+			if result.Failed[0].Receipt.Retailer != "Walgreens" {
+				t.Errorf("Rescore() failed receipt = %+v, want the Walgreens receipt", result.Failed[0].Receipt)
+			}
+
+			if _, err := db.GetBreakdown(target, "3"); err != nil {
+				t.Errorf("GetBreakdown() of the rescored receipt error = %v", err)
+			}
+
+			if _, err := db.GetBreakdown(walgreens, "3"); !errors.Is(err, ErrVersionNotScored) {
+				t.Errorf("GetBreakdown() of the failed receipt error = %v, want %v", err, ErrVersionNotScored)
+			}
+		})
+	}
+}
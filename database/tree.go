@@ -0,0 +1,124 @@
+package database
+
+import "math/rand/v2"
+
+// tree is a treap of index entries ordered by key, then id. Nodes
+// are kept balanced by their random priorities, so entries are
+// added and removed in O(log n) on average
+type tree struct {
+	root *node
+}
+
+type node struct {
+	entry       indexEntry
+	priority    uint64
+	left, right *node
+}
+
+// insert adds the entry, which must not be in the tree
+func (t *tree) insert(e indexEntry) {
+	t.root = t.root.insert(&node{entry: e, priority: rand.Uint64()})
+}
+
+// delete removes the entry if it is in the tree
+func (t *tree) delete(e indexEntry) {
+	t.root = t.root.delete(e)
+}
+
+// ascend calls fn for every entry that is not before from, in
+// order, until fn returns false
+func (t *tree) ascend(from indexEntry, fn func(indexEntry) bool) {
+	t.root.ascend(from, fn)
+}
+
+// descend calls fn for every entry before to, or every entry if
+// to is nil, in reverse order until fn returns false
+func (t *tree) descend(to *indexEntry, fn func(indexEntry) bool) {
+	t.root.descend(to, fn)
+}
+
+func (n *node) insert(add *node) *node {
+	if n == nil {
+		return add
+	}
+
+	if add.entry.less(n.entry) {
+		n.left = n.left.insert(add)
+		if n.left.priority > n.priority {
+			// rotate right
+			top := n.left
+			n.left, top.right = top.right, n
+			return top
+		}
+	} else {
+		n.right = n.right.insert(add)
+		if n.right.priority > n.priority {
+			// rotate left
+			top := n.right
+			n.right, top.left = top.left, n
+			return top
+		}
+	}
+
+	return n
+}
+
+func (n *node) delete(e indexEntry) *node {
+	switch {
+	case n == nil:
+		return nil
+	case e.less(n.entry):
+		n.left = n.left.delete(e)
+	case n.entry.less(e):
+		n.right = n.right.delete(e)
+	default:
+		return join(n.left, n.right)
+	}
+
+	return n
+}
+
+// join merges two treaps where every entry of a is before every
+// entry of b
+func join(a, b *node) *node {
+	switch {
+	case a == nil:
+		return b
+	case b == nil:
+		return a
+	case a.priority > b.priority:
+		a.right = join(a.right, b)
+		return a
+	default:
+		b.left = join(a, b.left)
+		return b
+	}
+}
+
+func (n *node) ascend(from indexEntry, fn func(indexEntry) bool) bool {
+	if n == nil {
+		return true
+	}
+
+	if !n.entry.less(from) {
+		if !n.left.ascend(from, fn) || !fn(n.entry) {
+			return false
+		}
+	}
+
+	return n.right.ascend(from, fn)
+}
+
+func (n *node) descend(to *indexEntry, fn func(indexEntry) bool) bool {
+	if n == nil {
+		return true
+	}
+
+	if to == nil || n.entry.less(*to) {
+		if !n.right.descend(to, fn) || !fn(n.entry) {
+			return false
+		}
+	}
+
+	return n.left.descend(to, fn)
+}
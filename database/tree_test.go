@@ -0,0 +1,61 @@
+package database
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"testing"
+)
+
+func TestTree(t *testing.T) {
+	var tr tree
+	var want []indexEntry
+
+	rnd := rand.New(rand.NewPCG(1, 2))
+	for i := range 2000 {
+		e := indexEntry{key: rnd.Int64N(50), id: fmt.Sprintf("%04d", i)}
+		tr.insert(e)
+		want = append(want, e)
+
+		// remove a random entry every third insert
+		if i%3 == 0 {
+			j := rnd.IntN(len(want))
+			tr.delete(want[j])
+			want = slices.Delete(want, j, j+1)
+		}
+	}
+	slices.SortFunc(want, indexEntry.compare)
+
+	var got []indexEntry
+	tr.ascend(indexEntry{}, func(e indexEntry) bool {
+		got = append(got, e)
+		return true
+	})
+
+	if !slices.Equal(got, want) {
+		t.Fatalf("ascend() returned %d entries out of order, want %d", len(got), len(want))
+	}
+
+	from := want[len(want)/2]
+	got = got[:0]
+	tr.ascend(from, func(e indexEntry) bool {
+		got = append(got, e)
+		return len(got) < 10
+	})
+
+	if !slices.Equal(got, want[len(want)/2:len(want)/2+10]) {
+		t.Errorf("ascend() from %v = %v, want %v", from, got, want[len(want)/2:len(want)/2+10])
+	}
+
+	got = got[:0]
+	tr.descend(&from, func(e indexEntry) bool {
+		got = append(got, e)
+		return len(got) < 10
+	})
+
+	before := slices.Clone(want[len(want)/2-10 : len(want)/2])
+	slices.Reverse(before)
+	if !slices.Equal(got, before) {
+		t.Errorf("descend() to %v = %v, want %v", from, got, before)
+	}
+}
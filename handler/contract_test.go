@@ -0,0 +1,219 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/afranco07/receipt-processor/database"
+	"github.com/afranco07/receipt-processor/openapi"
+	"github.com/afranco07/receipt-processor/problem"
+)
+
+// TestReceiptHandler_Contract sends requests to the operations in
+// api.yml through the middleware rejecting anything that does not
+// conform to it, so a handler that drifts from the contract answers
+// with 500 Internal Server Error
+func TestReceiptHandler_Contract(t *testing.T) {
+	contract, err := openapi.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h := New(database.NewInMemoryDatabase(), WithAdminToken("secret"))
+	mux := http.NewServeMux()
+	for pattern, handle := range h.Routes() {
+		mux.HandleFunc(pattern, handle)
+	}
+	server := contract.Middleware(openapi.ModeReject, mux)
+
+	testFile, err := os.ReadFile("../examples/morning-receipt.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	process := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/receipts/process", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, req)
+		return w
+	}
+
+	w := process(string(testFile))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("the response status code did not match. Got %d, want %d", w.Code, http.StatusCreated)
+	}
+
+	var processed processReceiptResponse
+	if err := json.NewDecoder(w.Body).Decode(&processed); err != nil {
+		t.Fatal(err)
+	}
+
+	// request creates a request with a body of the content type
+	request := func(method, target, contentType, body string) *http.Request {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		return req
+	}
+
+	// admin creates a rescore request with the admin token
+	admin := func(body string) *http.Request {
+		req := request(http.MethodPost, "/admin/rescore", "application/json", body)
+		req.Header.Set("Authorization", "Bearer secret")
+		return req
+	}
+
+	simple := compactFile(t, "../examples/simple-receipt.json")
+	csvBody := "receipt,retailer,purchaseDate,purchaseTime,total,shortDescription,price\n" +
+		"a,Walgreens,2022-01-02,08:13,2.65,Pepsi - 12-oz,1.25\n" +
+		"a,Walgreens,2022-01-02,08:13,2.65,Dasani,1.40\n"
+
+	tests := []struct {
+		name           string
+		req            *http.Request
+		wantStatusCode int
+	}{
+		{
+			name:           "points of a processed receipt",
+			req:            httptest.NewRequest(http.MethodGet, "/receipts/"+processed.Id+"/points", nil),
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name:           "points of an unknown receipt",
+			req:            httptest.NewRequest(http.MethodGet, "/receipts/does-not-exist/points", nil),
+			wantStatusCode: http.StatusNotFound,
+		},
+		{
+			name:           "points of a version the receipt was not scored with",
+			req:            httptest.NewRequest(http.MethodGet, "/receipts/"+processed.Id+"/points?version=9", nil),
+			wantStatusCode: http.StatusNotFound,
+		},
+		{
+			name:           "breakdown of a processed receipt",
+			req:            httptest.NewRequest(http.MethodGet, "/receipts/"+processed.Id+"/breakdown", nil),
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name:           "processed receipt",
+			req:            httptest.NewRequest(http.MethodGet, "/receipts/"+processed.Id, nil),
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name:           "unknown receipt",
+			req:            httptest.NewRequest(http.MethodGet, "/receipts/does-not-exist", nil),
+			wantStatusCode: http.StatusNotFound,
+		},
+		{
+			name:           "list receipts",
+			req:            httptest.NewRequest(http.MethodGet, "/receipts?sort=points&order=desc&limit=10", nil),
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name:           "list with an invalid cursor",
+			req:            httptest.NewRequest(http.MethodGet, "/receipts?cursor=abc", nil),
+			wantStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:           "export receipts",
+			req:            httptest.NewRequest(http.MethodGet, "/receipts/export?retailer=target", nil),
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name:           "batch",
+			req:            request(http.MethodPost, "/receipts/batch", "application/json", `[`+simple+`, {"retailer": "Target"}]`),
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name:           "stream",
+			req:            request(http.MethodPost, "/receipts/stream", "application/x-ndjson", simple+"\n"),
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name:           "import",
+			req:            request(http.MethodPost, "/receipts/import", "text/csv", csvBody),
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name:           "invalid import",
+			req:            request(http.MethodPost, "/receipts/import", "text/csv", "retailer\nTarget\n"),
+			wantStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:           "simulate",
+			req:            request(http.MethodPost, "/receipts/simulate", "application/json", `{"id": "`+processed.Id+`", "rules": {"version": "3"}}`),
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name:           "simulate an unknown receipt",
+			req:            request(http.MethodPost, "/receipts/simulate", "application/json", `{"id": "does-not-exist", "rules": {}}`),
+			wantStatusCode: http.StatusNotFound,
+		},
+		{
+			name:           "rescore",
+			req:            admin(`{"version": "1"}`),
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name:           "rescore with an unknown version",
+			req:            admin(`{"version": "9"}`),
+			wantStatusCode: http.StatusNotFound,
+		},
+		{
+			name:           "rescore without the admin token",
+			req:            request(http.MethodPost, "/admin/rescore", "application/json", `{"version": "1"}`),
+			wantStatusCode: http.StatusUnauthorized,
+		},
+		{
+			name:           "api contract",
+			req:            httptest.NewRequest(http.MethodGet, "/openapi.yml", nil),
+			wantStatusCode: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			server.ServeHTTP(w, tt.req)
+
+			if w.Code != tt.wantStatusCode {
+				t.Errorf("the response status code did not match. Got %d, want %d: %s", w.Code, tt.wantStatusCode, w.Body.String())
+			}
+		})
+	}
+
+	// a duplicate conforms to the contract, and is rejected by the
+	// handler rather than the middleware
+	w = process(string(testFile))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("the response status code did not match. Got %d, want %d", w.Code, http.StatusBadRequest)
+	}
+
+	var p problem.Problem
+	if err := json.NewDecoder(w.Body).Decode(&p); err != nil || p.Detail != "receipt has already been submitted" {
+		t.Errorf("the response body did not match. Got %+v, %v", p, err)
+	}
+}
+
+// TestReceiptHandler_ContractRoutes checks every route of the handler
+// is documented in api.yml, so it is served in the contract and its
+// requests and responses are checked
+func TestReceiptHandler_ContractRoutes(t *testing.T) {
+	contract, err := openapi.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h := New(database.NewInMemoryDatabase())
+	for pattern := range h.Routes() {
+		method, template, _ := strings.Cut(pattern, " ")
+		if !contract.Documents(method, template) {
+			t.Errorf("%s is not documented in api.yml", pattern)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+
+	"github.com/afranco07/receipt-processor/database"
+	"github.com/afranco07/receipt-processor/receiptcsv"
+)
+
+const csvContentType = "text/csv"
+
+// ImportResult is the outcome of importing a receipt from a CSV
+type ImportResult struct {
+	// Receipt is the key of the receipt in the CSV
+	Receipt string `json:"receipt"`
+	Line    int    `json:"line"`
+	receiptResult
+}
+
+// ImportCSV validates, scores and stores every receipt in a CSV the
+// same way as a receipt submitted as JSON. See receiptcsv.Read for
+// the format of the CSV
+func (h *ReceiptHandler) ImportCSV(r io.Reader) ([]ImportResult, error) {
+	records, err := receiptcsv.Read(r, receiptcsv.WithDateFormats(h.dates))
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]ImportResult, len(records))
+	for i, record := range records {
+		var id string
+		var perr *processError
+		if record.Err != nil {
+			log.Printf("error reading receipt %s on line %d: %v", record.Key, record.Line, record.Err)
+			perr = decodeError(record.Err)
+		} else {
+			id, perr = h.storeReceipt(record.Receipt)
+		}
+
+		results[i] = ImportResult{Receipt: record.Key, Line: record.Line, receiptResult: newReceiptResult(id, perr)}
+	}
+
+	return results, nil
+}
+
+// ExportCSV writes every stored receipt matching the query along
+// with its points. The limit and cursor of the query are ignored
+func (h *ReceiptHandler) ExportCSV(w io.Writer, q database.ListQuery) error {
+	q.Limit = database.MaxListLimit
+	q.Cursor = ""
+
+	// the first page is read before anything is written so an
+	// invalid query can still be reported
+	page, err := h.store.List(q)
+	if err != nil {
+		return err
+	}
+
+	cw := receiptcsv.NewWriter(w)
+	for {
+		for _, l := range page.Receipts {
+			if err := cw.Write(l); err != nil {
+				return err
+			}
+		}
+
+		if page.NextCursor == "" {
+			break
+		}
+
+		q.Cursor = page.NextCursor
+		page, err = h.store.List(q)
+		if err != nil {
+			return err
+		}
+	}
+
+	return cw.Flush()
+}
+
+type importResponse struct {
+	Results []ImportResult `json:"results"`
+}
+
+// ImportReceipts imports the receipts in a text/csv body
+func (h *ReceiptHandler) ImportReceipts(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != csvContentType {
+		log.Printf("import rejected with content type %q", r.Header.Get("Content-Type"))
+		w.WriteHeader(http.StatusUnsupportedMediaType)
+		_ = enc.Encode(errorMessage{Message: "the body must be " + csvContentType})
+		return
+	}
+
+	results, err := h.ImportCSV(r.Body)
+	if err != nil {
+		log.Printf("error importing receipts: %v", err)
+		if errors.Is(err, receiptcsv.ErrInvalidCSV) {
+			w.WriteHeader(http.StatusBadRequest)
+			_ = enc.Encode(errorMessage{Message: err.Error()})
+			return
+		}
+
+		w.WriteHeader(http.StatusInternalServerError)
+		_ = enc.Encode(errorMessage{Message: "something went wrong"})
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	_ = enc.Encode(importResponse{Results: results})
+}
+
+// csvResponse sets the headers of a CSV response on the first
+// write, so errors before anything is written can still be sent
+// as JSON
+type csvResponse struct {
+	w       http.ResponseWriter
+	started bool
+}
+
+func (c *csvResponse) Write(p []byte) (int, error) {
+	if !c.started {
+		c.started = true
+		c.w.Header().Set("Content-Type", csvContentType)
+		c.w.Header().Set("Content-Disposition", `attachment; filename="receipts.csv"`)
+		c.w.WriteHeader(http.StatusOK)
+	}
+
+	return c.w.Write(p)
+}
+
+// ExportReceipts writes the stored receipts matching the filters of
+// GET /receipts as CSV
+func (h *ReceiptHandler) ExportReceipts(w http.ResponseWriter, r *http.Request) {
+	enc := json.NewEncoder(w)
+
+	q, err := parseListQuery(r.URL.Query())
+	if err != nil {
+		log.Printf("invalid export query: %v", err)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		_ = enc.Encode(errorMessage{Message: err.Error()})
+		return
+	}
+
+	resp := &csvResponse{w: w}
+	err = h.ExportCSV(resp, q)
+	if err == nil {
+		return
+	}
+
+	log.Printf("error exporting receipts: %v", err)
+	// the response can only be changed if nothing has been written
+	if resp.started {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if errors.Is(err, database.ErrInvalidSort) {
+		w.WriteHeader(http.StatusBadRequest)
+		_ = enc.Encode(errorMessage{Message: err.Error()})
+		return
+	}
+
+	w.WriteHeader(http.StatusInternalServerError)
+	_ = enc.Encode(errorMessage{Message: "something went wrong"})
+}
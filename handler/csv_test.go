@@ -0,0 +1,130 @@
+package handler
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/afranco07/receipt-processor/database"
+	"github.com/afranco07/receipt-processor/receipt"
+)
+
+func TestReceiptHandler_ImportExport(t *testing.T) {
+	h := &ReceiptHandler{
+		store:     database.NewInMemoryDatabase(),
+		validator: receipt.NewValidator(),
+		rules:     receipt.NewRegistry(receipt.DefaultRuleSet()),
+	}
+
+	body := "receipt,retailer,purchaseDate,purchaseTime,total,shortDescription,price\n" +
+		"a,Target,2022-01-01,13:01,35.35,Mountain Dew 12PK,6.49\n" +
+		"b,M&M Corner Market,2022-03-20,14:33,9.00,Gatorade,2.25\n" +
+		"a,Target,2022-01-01,13:01,35.35,Emils Cheese Pizza,12.25\n" +
+		"b,M&M Corner Market,2022-03-20,14:33,9.00,Gatorade,2.25\n" +
+		"c,,2022-03-20,14:33,9.00,Gatorade,2.25\n" +
+		"d,Target,2022-01-01,13:01,35.35,Mountain Dew 12PK,6.49\n" +
+		"d,Target,2022-01-01,13:01,35.35,Emils Cheese Pizza,12.25\n"
+
+	tests := []struct {
+		name             string
+		contentType      string
+		body             string
+		expectResults    []ImportResult
+		expectStatusCode int
+	}{
+		{
+			name:        "receipts are validated and deduplicated like JSON ones",
+			contentType: "text/csv",
+			body:        body,
+			expectResults: []ImportResult{
+				{Receipt: "a", Line: 2},
+				{Receipt: "b", Line: 3},
+				{Receipt: "c", Line: 6, receiptResult: receiptResult{Errors: []receipt.FieldError{{Pointer: "/retailer", Constraint: "required"}}}},
+				{Receipt: "d", Line: 7, receiptResult: receiptResult{Duplicate: true}},
+			},
+			expectStatusCode: http.StatusOK,
+		},
+		{
+			name:             "missing columns",
+			contentType:      "text/csv",
+			body:             "receipt,retailer\na,Target\n",
+			expectStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:             "wrong content type",
+			contentType:      "application/json",
+			body:             body,
+			expectStatusCode: http.StatusUnsupportedMediaType,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodPost, "/receipts/import", strings.NewReader(tt.body))
+			r.Header.Set("Content-Type", tt.contentType)
+			h.ImportReceipts(w, r)
+
+			if w.Result().StatusCode != tt.expectStatusCode {
+				t.Fatalf("the response status code did not match. Got %d, want %d", w.Result().StatusCode, tt.expectStatusCode)
+			}
+
+			if tt.expectStatusCode != http.StatusOK {
+				return
+			}
+
+			var resp importResponse
+			if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+				t.Fatal(err)
+			}
+
+			if len(resp.Results) != len(tt.expectResults) {
+				t.Fatalf("the number of results did not match. Got %d, want %d", len(resp.Results), len(tt.expectResults))
+			}
+
+			for i, want := range tt.expectResults {
+				got := resp.Results[i]
+				if got.Receipt != want.Receipt || got.Line != want.Line || got.Duplicate != want.Duplicate || len(got.Errors) != len(want.Errors) {
+					t.Errorf("result %d did not match. Got %+v, want %+v", i, got, want)
+				}
+
+				if (got.ID != "") != (!want.Duplicate && want.Errors == nil) {
+					t.Errorf("result %d id did not match. Got %q", i, got.ID)
+				}
+			}
+		})
+	}
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/receipts/export?retailer=target", nil)
+	h.ExportReceipts(w, r)
+
+	if w.Result().StatusCode != http.StatusOK {
+		t.Fatalf("the export status code did not match. Got %d, want %d", w.Result().StatusCode, http.StatusOK)
+	}
+
+	if got := w.Header().Get("Content-Type"); got != "text/csv" {
+		t.Errorf("the export content type did not match. Got %s, want text/csv", got)
+	}
+
+	rows, err := csv.NewReader(w.Body).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the header and a row for each item of receipt a
+	if len(rows) != 3 || rows[1][1] != "Target" || rows[1][17] != "20" {
+		t.Errorf("the export did not match. Got %v", rows)
+	}
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest(http.MethodGet, "/receipts/export?sort=retailer", nil)
+	h.ExportReceipts(w, r)
+
+	if w.Result().StatusCode != http.StatusBadRequest {
+		t.Errorf("the export status code did not match. Got %d, want %d", w.Result().StatusCode, http.StatusBadRequest)
+	}
+}
@@ -1,23 +1,33 @@
 package handler
 
 import (
+	"bytes"
+	"crypto/subtle"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/afranco07/receipt-processor/database"
+	"github.com/afranco07/receipt-processor/openapi"
+	"github.com/afranco07/receipt-processor/problem"
 	"github.com/afranco07/receipt-processor/receipt"
+	"github.com/afranco07/receipt-processor/receiptcsv"
 	"github.com/go-playground/validator/v10"
 )
 
 // store is the interface used for the database
 // operations
 type store interface {
-	Get(string) (int, error)
-	Insert(receipt.Receipt, int) (string, error)
+	GetBreakdown(id, version string) (receipt.Breakdown, error)
+	GetReceipt(string) (database.StoredReceipt, error)
+	List(database.ListQuery) (database.ReceiptPage, error)
+	Insert(receipt.Receipt, receipt.Breakdown) (string, error)
+	Rescore(*receipt.RuleSet) (database.RescoreResult, error)
 }
 
 type errorMessage struct {
@@ -27,23 +37,185 @@ type errorMessage struct {
 type ReceiptHandler struct {
 	store     store
 	validator *validator.Validate
+	rules     *receipt.Registry
+	dates     receipt.DateFormats
+	// adminToken is the bearer token of the admin endpoints, which
+	// are disabled without it
+	adminToken string
 }
 
-func New(store store) ReceiptHandler {
-	return ReceiptHandler{
+// Option configures a ReceiptHandler
+type Option func(*ReceiptHandler)
+
+// WithRuleSet sets the rules used to score receipts. The
+// default rules are used otherwise
+func WithRuleSet(rules *receipt.RuleSet) Option {
+	return func(h *ReceiptHandler) {
+		h.rules = receipt.NewRegistry(rules)
+	}
+}
+
+// WithRegistry sets the versions of the rules known to the
+// handler. New receipts are scored with the current version
+func WithRegistry(rules *receipt.Registry) Option {
+	return func(h *ReceiptHandler) {
+		h.rules = rules
+	}
+}
+
+// WithDateFormats sets the formats the purchase date and time of a
+// receipt are accepted in. Only the formats of api.yml are accepted
+// otherwise
+func WithDateFormats(f receipt.DateFormats) Option {
+	return func(h *ReceiptHandler) {
+		h.dates = f
+	}
+}
+
+// WithAdminToken sets the bearer token the admin endpoints require.
+// They are disabled otherwise
+func WithAdminToken(token string) Option {
+	return func(h *ReceiptHandler) {
+		h.adminToken = token
+	}
+}
+
+func New(store store, opts ...Option) ReceiptHandler {
+	h := ReceiptHandler{
 		store:     store,
-		validator: validator.New(validator.WithRequiredStructEnabled()),
+		validator: receipt.NewValidator(),
+		rules:     receipt.NewDefaultRegistry(),
+	}
+
+	for _, opt := range opts {
+		opt(&h)
+	}
+
+	return h
+}
+
+// Routes returns every operation of the handler, keyed by the
+// pattern it is registered with on an http.ServeMux. Every route is
+// documented in openapi/api.yml
+func (h *ReceiptHandler) Routes() map[string]http.HandlerFunc {
+	return map[string]http.HandlerFunc{
+		"GET /openapi.yml":             openapi.ServeSpec,
+		"GET /receipts":                h.ListReceipts,
+		"GET /receipts/export":         h.ExportReceipts,
+		"POST /receipts/import":        h.ImportReceipts,
+		"GET /receipts/{id}":           h.GetReceiptForID,
+		"GET /receipts/{id}/points":    h.GetPointsForID,
+		"GET /receipts/{id}/breakdown": h.GetBreakdownForID,
+		"POST /receipts/process":       h.ProcessReceipt,
+		"POST /receipts/batch":         h.ProcessBatch,
+		"POST /receipts/stream":        h.ProcessStream,
+		"POST /receipts/simulate":      h.Simulate,
+		"POST /admin/rescore":          h.admin(h.Rescore),
+	}
+}
+
+// admin only calls next for a request with the admin token
+func (h *ReceiptHandler) admin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if h.adminToken == "" {
+			log.Printf("admin request to %s without an admin token configured", r.URL.Path)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusForbidden)
+			_ = json.NewEncoder(w).Encode(errorMessage{Message: "the admin endpoints are disabled"})
+			return
+		}
+
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(h.adminToken)) != 1 {
+			log.Printf("unauthorized admin request to %s", r.URL.Path)
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			w.WriteHeader(http.StatusUnauthorized)
+			_ = json.NewEncoder(w).Encode(errorMessage{Message: "the admin token is missing or invalid"})
+			return
+		}
+
+		next(w, r)
 	}
 }
 
 type getPointsResponse struct {
-	Points int `json:"points"`
+	Points  int    `json:"points"`
+	Version string `json:"version"`
 }
 
 func (h *ReceiptHandler) GetPointsForID(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	enc := json.NewEncoder(w)
 
+	breakdown, ok := h.breakdownForID(w, r)
+	if !ok {
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	_ = enc.Encode(getPointsResponse{Points: breakdown.Points, Version: breakdown.Version})
+}
+
+func (h *ReceiptHandler) GetBreakdownForID(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+
+	breakdown, ok := h.breakdownForID(w, r)
+	if !ok {
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	_ = enc.Encode(breakdown)
+}
+
+// breakdownForID returns the breakdown of the receipt in the path
+// under the version in the query string. The error is written to w
+// if it cannot be found
+func (h *ReceiptHandler) breakdownForID(w http.ResponseWriter, r *http.Request) (receipt.Breakdown, bool) {
+	enc := json.NewEncoder(w)
+
+	id := r.PathValue("id")
+	if id == "" {
+		log.Println("Missing id parameter")
+		w.WriteHeader(http.StatusBadRequest)
+		_ = enc.Encode(errorMessage{Message: "id is required"})
+		return receipt.Breakdown{}, false
+	}
+
+	version := r.URL.Query().Get("version")
+	breakdown, err := h.store.GetBreakdown(id, version)
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			log.Printf("receipt with ID '%s' not found", id)
+			w.WriteHeader(http.StatusNotFound)
+			_ = enc.Encode(errorMessage{Message: fmt.Sprintf("receipt with ID '%s' not found", id)})
+			return receipt.Breakdown{}, false
+		}
+
+		if errors.Is(err, database.ErrVersionNotScored) {
+			log.Printf("receipt with ID '%s' not scored with version '%s'", id, version)
+			w.WriteHeader(http.StatusNotFound)
+			_ = enc.Encode(errorMessage{Message: fmt.Sprintf("receipt with ID '%s' has not been scored with version '%s'", id, version)})
+			return receipt.Breakdown{}, false
+		}
+
+		log.Printf("error getting breakdown for receipt with id %s: %v", id, err)
+		w.WriteHeader(http.StatusInternalServerError)
+		_ = enc.Encode(errorMessage{Message: "something went wrong"})
+		return receipt.Breakdown{}, false
+	}
+
+	return breakdown, true
+}
+
+// GetReceiptForID returns the receipt in the shape it was
+// submitted in. Last-Modified is set to when it was received
+func (h *ReceiptHandler) GetReceiptForID(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+
 	id := r.PathValue("id")
 	if id == "" {
 		log.Println("Missing id parameter")
@@ -52,7 +224,7 @@ func (h *ReceiptHandler) GetPointsForID(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	score, err := h.store.Get(id)
+	stored, err := h.store.GetReceipt(id)
 	if err != nil {
 		if errors.Is(err, database.ErrNotFound) {
 			log.Printf("receipt with ID '%s' not found", id)
@@ -67,65 +239,370 @@ func (h *ReceiptHandler) GetPointsForID(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	if !stored.ReceivedAt.IsZero() {
+		w.Header().Set("Last-Modified", stored.ReceivedAt.Format(http.TimeFormat))
+	}
+
 	w.WriteHeader(http.StatusOK)
-	_ = enc.Encode(getPointsResponse{Points: score})
-	return
+	_ = enc.Encode(stored.Receipt)
 }
 
-type processReceiptResponse struct {
-	Id string `json:"id"`
+type listedReceipt struct {
+	ID         string          `json:"id"`
+	Points     int             `json:"points"`
+	Version    string          `json:"version"`
+	ReceivedAt time.Time       `json:"receivedAt"`
+	Receipt    receipt.Receipt `json:"receipt"`
 }
 
-func (h *ReceiptHandler) ProcessReceipt(w http.ResponseWriter, r *http.Request) {
+type listReceiptsResponse struct {
+	Receipts   []listedReceipt `json:"receipts"`
+	NextCursor string          `json:"nextCursor,omitempty"`
+}
+
+// ListReceipts returns a page of the stored receipts matching the
+// filters in the query string. The next page is requested by
+// passing nextCursor as the cursor
+func (h *ReceiptHandler) ListReceipts(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	enc := json.NewEncoder(w)
-	dec := json.NewDecoder(r.Body)
 
-	var rcpt receipt.Receipt
-	if err := dec.Decode(&rcpt); err != nil {
-		log.Printf("error marshalling receipt: %v", err)
-		var timeErr *time.ParseError
-		if errors.As(err, &timeErr) {
+	q, err := parseListQuery(r.URL.Query())
+	if err != nil {
+		log.Printf("invalid list query: %v", err)
+		w.WriteHeader(http.StatusBadRequest)
+		_ = enc.Encode(errorMessage{Message: err.Error()})
+		return
+	}
+
+	page, err := h.store.List(q)
+	if err != nil {
+		if errors.Is(err, database.ErrInvalidCursor) || errors.Is(err, database.ErrInvalidSort) {
+			log.Printf("invalid list query: %v", err)
 			w.WriteHeader(http.StatusBadRequest)
-			_ = enc.Encode(errorMessage{Message: fmt.Sprintf("%s is not a valid value", timeErr.Value)})
+			_ = enc.Encode(errorMessage{Message: err.Error()})
 			return
 		}
 
+		log.Printf("error listing receipts: %v", err)
 		w.WriteHeader(http.StatusInternalServerError)
 		_ = enc.Encode(errorMessage{Message: "something went wrong"})
 		return
 	}
 
+	resp := listReceiptsResponse{Receipts: make([]listedReceipt, 0, len(page.Receipts)), NextCursor: page.NextCursor}
+	for _, l := range page.Receipts {
+		resp.Receipts = append(resp.Receipts, listedReceipt{
+			ID:         l.ID,
+			Points:     l.Points,
+			Version:    l.Version,
+			ReceivedAt: l.ReceivedAt,
+			Receipt:    l.Receipt,
+		})
+	}
+
+	w.WriteHeader(http.StatusOK)
+	_ = enc.Encode(resp)
+}
+
+type processReceiptResponse struct {
+	Id string `json:"id"`
+}
+
+func (h *ReceiptHandler) ProcessReceipt(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+
+	id, perr := h.processReceipt(json.NewDecoder(r.Body))
+	if perr != nil {
+		writeProblem(w, perr)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	_ = enc.Encode(processReceiptResponse{Id: id})
+}
+
+// processError is why a receipt could not be processed, along
+// with the status code it is reported with
+type processError struct {
+	status    int
+	message   string
+	fields    []receipt.FieldError
+	duplicate bool
+}
+
+func writeProblem(w http.ResponseWriter, perr *processError) {
+	problem.Write(w, perr.status, perr.message, perr.fields)
+}
+
+// processReceipt decodes, validates, scores and stores a single
+// receipt, returning its id
+func (h *ReceiptHandler) processReceipt(dec *json.Decoder) (string, *processError) {
+	var raw json.RawMessage
+	var rcpt receipt.Receipt
+	err := dec.Decode(&raw)
+	if err == nil {
+		err = h.dates.Unmarshal(raw, &rcpt)
+	}
+	if err != nil {
+		log.Printf("error marshalling receipt: %v", err)
+		return "", decodeError(err)
+	}
+
+	return h.storeReceipt(rcpt)
+}
+
+// decodeError reports why a receipt could not be parsed, along with
+// the field that could not be decoded
+func decodeError(err error) *processError {
+	perr := &processError{status: http.StatusBadRequest}
+
+	var decodeErr *receipt.DecodeError
+	if errors.As(err, &decodeErr) {
+		perr.fields = []receipt.FieldError{decodeErr.FieldError}
+	}
+
+	var timeErr *time.ParseError
+	var syntaxErr *json.SyntaxError
+	switch {
+	case errors.As(err, &timeErr):
+		perr.message = fmt.Sprintf("%s is not a valid value", timeErr.Value)
+	case decodeErr != nil:
+		perr.message = decodeErr.Err.Error()
+	case errors.Is(err, receiptcsv.ErrInvalidCSV):
+		perr.message = err.Error()
+	case errors.As(err, &syntaxErr), errors.Is(err, io.ErrUnexpectedEOF), errors.Is(err, io.EOF):
+		perr.message = "the body is not valid JSON"
+	default:
+		return &processError{status: http.StatusInternalServerError, message: "something went wrong"}
+	}
+
+	return perr
+}
+
+// storeReceipt validates, scores and stores a parsed receipt
+func (h *ReceiptHandler) storeReceipt(rcpt receipt.Receipt) (string, *processError) {
+	breakdown, perr := h.scoreReceipt(h.rules.Current(), rcpt)
+	if perr != nil {
+		return "", perr
+	}
+
+	if perr := reconciliationError(breakdown); perr != nil {
+		return "", perr
+	}
+
+	id, err := h.store.Insert(rcpt, breakdown)
+	if err != nil {
+		log.Printf("error inserting receipt into database: %v", err)
+		if errors.Is(err, database.ErrReceiptAlreadyExists) {
+			return "", &processError{status: http.StatusBadRequest, message: "receipt has already been submitted", duplicate: true}
+		}
+
+		return "", &processError{status: http.StatusInternalServerError, message: "something went wrong"}
+	}
+
+	return id, nil
+}
+
+// reconciliationError returns the response for a receipt whose lines
+// do not add up to its total, if the policy of the rules that scored
+// it rejects such a receipt
+func reconciliationError(breakdown receipt.Breakdown) *processError {
+	rec := breakdown.Reconciliation
+	if rec == nil || rec.Status != receipt.ReconciliationInconsistent {
+		return nil
+	}
+
+	log.Printf("receipt lines add up to %s for a total of %s, policy %s", rec.Expected, rec.Total, rec.Policy)
+	if !rec.Rejected() {
+		return nil
+	}
+
+	return &processError{
+		status:  http.StatusBadRequest,
+		message: fmt.Sprintf("the receipt lines add up to %s, which does not match the total of %s", rec.Expected, rec.Total),
+		fields:  []receipt.FieldError{{Pointer: "/total", Constraint: receipt.ConstraintReconciliation, Value: rec.Total.String()}},
+	}
+}
+
+// scoreReceipt validates a parsed receipt and scores it with the rules
+func (h *ReceiptHandler) scoreReceipt(rules *receipt.RuleSet, rcpt receipt.Receipt) (receipt.Breakdown, *processError) {
 	validationErrors, err := rcpt.ValidateReceipt(h.validator)
 	if err != nil {
 		log.Printf("error validating receipt: %v", validationErrors)
-		w.WriteHeader(http.StatusBadRequest)
-		_ = enc.Encode(errorMessage{Message: err.Error()})
-		return
+		perr := &processError{status: http.StatusBadRequest, message: err.Error()}
+
+		var validationErr *receipt.ValidationError
+		if errors.As(err, &validationErr) {
+			perr.fields = validationErr.Fields
+		}
+		return receipt.Breakdown{}, perr
 	}
 
-	score, err := rcpt.GetScore()
+	breakdown, err := rules.Score(rcpt)
 	if err != nil {
 		log.Printf("error getting receipt score: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		_ = enc.Encode(errorMessage{Message: "something went wrong"})
+		return receipt.Breakdown{}, scoreError(rcpt, err)
+	}
+
+	return breakdown, nil
+}
+
+// scoreError returns the response for a receipt the rules could not
+// score. Only errors caused by the receipt are described
+func scoreError(rcpt receipt.Receipt, err error) *processError {
+	if errors.Is(err, receipt.ErrNoExchangeRate) {
+		return &processError{
+			status:  http.StatusBadRequest,
+			message: fmt.Sprintf("the receipt cannot be scored, %v", err),
+			fields:  []receipt.FieldError{{Pointer: "/currency", Constraint: receipt.ConstraintExchangeRate, Value: rcpt.CurrencyCode()}},
+		}
+	}
+
+	var scoringErr *receipt.ScoringError
+	if !errors.As(err, &scoringErr) {
+		return &processError{status: http.StatusInternalServerError, message: "something went wrong"}
+	}
+
+	perr := &processError{
+		status:  http.StatusUnprocessableEntity,
+		message: fmt.Sprintf("the receipt cannot be scored, %v", err),
+	}
+	if field, ok := scoringErr.FieldError(); ok {
+		perr.fields = []receipt.FieldError{field}
+	}
+
+	return perr
+}
+
+// maxBatchSize is the most receipts accepted by ProcessBatch, and
+// maxBatchBytes the largest body, so a batch is never decoded past
+// what 1000 large receipts need
+const (
+	maxBatchSize  = 1000
+	maxBatchBytes = 10 << 20
+)
+
+// receiptResult is the outcome of processing one of many receipts
+type receiptResult struct {
+	ID        string               `json:"id,omitempty"`
+	Error     string               `json:"error,omitempty"`
+	Errors    []receipt.FieldError `json:"errors,omitempty"`
+	Duplicate bool                 `json:"duplicate,omitempty"`
+}
+
+func newReceiptResult(id string, perr *processError) receiptResult {
+	result := receiptResult{ID: id}
+	if perr == nil {
+		return result
+	}
+
+	result.Error = perr.message
+	result.Duplicate = perr.duplicate
+	result.Errors = perr.fields
+
+	return result
+}
+
+type batchResult struct {
+	Index int `json:"index"`
+	receiptResult
+}
+
+type batchResponse struct {
+	Results []batchResult `json:"results"`
+}
+
+// ProcessBatch processes an array of receipts. Every receipt is
+// validated, scored and stored on its own, in order, so a receipt
+// that repeats one earlier in the batch is reported as a duplicate
+func (h *ReceiptHandler) ProcessBatch(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+
+	var receipts []json.RawMessage
+	var maxErr *http.MaxBytesError
+	err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBatchBytes)).Decode(&receipts)
+	if errors.As(err, &maxErr) {
+		log.Printf("batch larger than %d bytes rejected", maxErr.Limit)
+		w.WriteHeader(http.StatusRequestEntityTooLarge)
+		_ = enc.Encode(errorMessage{Message: fmt.Sprintf("a batch must be at most %d bytes", maxErr.Limit)})
+		return
+	} else if err != nil {
+		log.Printf("error decoding batch: %v", err)
+		w.WriteHeader(http.StatusBadRequest)
+		_ = enc.Encode(errorMessage{Message: "the body must be an array of receipts"})
+		return
+	}
+
+	if len(receipts) == 0 || len(receipts) > maxBatchSize {
+		log.Printf("batch of %d receipts rejected", len(receipts))
+		w.WriteHeader(http.StatusBadRequest)
+		_ = enc.Encode(errorMessage{Message: fmt.Sprintf("a batch must have between 1 and %d receipts", maxBatchSize)})
+		return
+	}
+
+	resp := batchResponse{Results: make([]batchResult, len(receipts))}
+	for i, raw := range receipts {
+		id, perr := h.processReceipt(json.NewDecoder(bytes.NewReader(raw)))
+		resp.Results[i] = batchResult{Index: i, receiptResult: newReceiptResult(id, perr)}
+	}
+
+	w.WriteHeader(http.StatusOK)
+	_ = enc.Encode(resp)
+}
+
+type rescoreRequest struct {
+	Version string `json:"version"`
+}
+
+type rescoreResponse struct {
+	Version  string `json:"version"`
+	Rescored int    `json:"rescored"`
+	// Failed are the receipts the rules could not score
+	Failed []receiptResult `json:"failed"`
+}
+
+// Rescore scores every stored receipt with the requested version
+// of the rules. The points the receipts were originally awarded
+// are kept, and a receipt the rules cannot score is reported on its
+// own without stopping the others
+func (h *ReceiptHandler) Rescore(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+
+	var req rescoreRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Version == "" {
+		log.Printf("invalid rescore request: %v", err)
+		w.WriteHeader(http.StatusBadRequest)
+		_ = enc.Encode(errorMessage{Message: "version is required"})
 		return
 	}
 
-	id, err := h.store.Insert(rcpt, score)
+	rules, err := h.rules.Get(req.Version)
 	if err != nil {
-		log.Printf("error inserting receipt into database: %v", err)
-		if errors.Is(err, database.ErrReceiptAlreadyExists) {
-			w.WriteHeader(http.StatusBadRequest)
-			_ = enc.Encode(errorMessage{Message: "receipt has already been submitted"})
-			return
-		}
+		log.Printf("error getting rules for rescore: %v", err)
+		w.WriteHeader(http.StatusNotFound)
+		_ = enc.Encode(errorMessage{Message: fmt.Sprintf("rules version '%s' not found", req.Version)})
+		return
+	}
 
+	result, err := h.store.Rescore(rules)
+	if err != nil {
+		log.Printf("error rescoring receipts with version %s: %v", req.Version, err)
 		w.WriteHeader(http.StatusInternalServerError)
 		_ = enc.Encode(errorMessage{Message: "something went wrong"})
 		return
 	}
 
-	w.WriteHeader(http.StatusCreated)
-	_ = enc.Encode(processReceiptResponse{Id: id})
+	resp := rescoreResponse{Version: req.Version, Rescored: result.Rescored, Failed: []receiptResult{}}
+	for _, f := range result.Failed {
+		log.Printf("error rescoring receipt %s with version %s: %v", f.ID, req.Version, f.Err)
+		resp.Failed = append(resp.Failed, newReceiptResult(f.ID, scoreError(f.Receipt, f.Err)))
+	}
+
+	log.Printf("rescored %d receipts with version %s, %d failed", result.Rescored, req.Version, len(result.Failed))
+	w.WriteHeader(http.StatusOK)
+	_ = enc.Encode(resp)
 }
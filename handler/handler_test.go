@@ -2,15 +2,18 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/afranco07/receipt-processor/database"
+	"github.com/afranco07/receipt-processor/problem"
 	"github.com/afranco07/receipt-processor/receipt"
-	"github.com/go-playground/validator/v10"
 )
 
 func TestReceiptHandler_GetPointsForID(t *testing.T) {
@@ -28,7 +31,7 @@ func TestReceiptHandler_GetPointsForID(t *testing.T) {
 	}
 
 	score := 10
-	id, err := db.Insert(rcpt, score)
+	id, err := db.Insert(rcpt, receipt.Breakdown{Points: score})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -92,6 +95,91 @@ func TestReceiptHandler_GetPointsForID(t *testing.T) {
 	}
 }
 
+func TestReceiptHandler_GetBreakdownForID(t *testing.T) {
+	db := database.NewInMemoryDatabase()
+	testFile, err := os.ReadFile("../examples/test-receipt.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var rcpt receipt.Receipt
+	err = json.Unmarshal(testFile, &rcpt)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	breakdown, err := rcpt.GetBreakdown()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	id, err := db.Insert(rcpt, breakdown)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name             string
+		id               string
+		expectPoints     int
+		expectStatusCode int
+	}{
+		{
+			name:             "successful get",
+			id:               id,
+			expectPoints:     breakdown.Points,
+			expectStatusCode: http.StatusOK,
+		},
+		{
+			name:             "id not found",
+			id:               "does-not-exist",
+			expectPoints:     -1,
+			expectStatusCode: http.StatusNotFound,
+		},
+		{
+			name:             "id path value missing",
+			id:               "",
+			expectPoints:     -1,
+			expectStatusCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &ReceiptHandler{
+				store: db,
+			}
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/receipts/%s/breakdown", tt.id), nil)
+			r.SetPathValue("id", tt.id)
+
+			h.GetBreakdownForID(w, r)
+
+			if w.Result().StatusCode != tt.expectStatusCode {
+				t.Errorf("the response status code did not match. Got %d, want %d", w.Result().StatusCode, tt.expectStatusCode)
+			}
+
+			if tt.expectPoints < 0 {
+				return
+			}
+
+			var got receipt.Breakdown
+			if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+				t.Fatal(err)
+			}
+
+			if got.Points != tt.expectPoints {
+				t.Errorf("the response points did not match. Got %d, want %d", got.Points, tt.expectPoints)
+			}
+
+			if len(got.Rules) != len(breakdown.Rules) {
+				t.Errorf("the response rules did not match. Got %d rules, want %d", len(got.Rules), len(breakdown.Rules))
+			}
+		})
+	}
+}
+
 func TestReceiptHandler_ProcessReceipt(t *testing.T) {
 	db := database.NewInMemoryDatabase()
 	// adding this file to test already submitted receipt
@@ -106,7 +194,7 @@ func TestReceiptHandler_ProcessReceipt(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	_, err = db.Insert(rcpt, 10)
+	_, err = db.Insert(rcpt, receipt.Breakdown{Points: 10})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -147,7 +235,8 @@ func TestReceiptHandler_ProcessReceipt(t *testing.T) {
 
 			h := &ReceiptHandler{
 				store:     db,
-				validator: validator.New(validator.WithRequiredStructEnabled()),
+				validator: receipt.NewValidator(),
+				rules:     receipt.NewRegistry(receipt.DefaultRuleSet()),
 			}
 
 			w := httptest.NewRecorder()
@@ -160,3 +249,934 @@ func TestReceiptHandler_ProcessReceipt(t *testing.T) {
 		})
 	}
 }
+
+func TestReceiptHandler_Rescore(t *testing.T) {
+	db := database.NewInMemoryDatabase()
+	testFile, err := os.ReadFile("../examples/test-receipt.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var rcpt receipt.Receipt
+	err = json.Unmarshal(testFile, &rcpt)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	original, err := receipt.DefaultRuleSet().Score(rcpt)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	id, err := db.Insert(rcpt, original)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := receipt.DefaultConfig()
+	cfg.Version = "3"
+	cfg.Retailer.PointsPerCharacter = 3
+	v3, err := receipt.NewConfigRuleSet(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	registry := receipt.NewRegistry(receipt.DefaultRuleSet())
+	if err := registry.Register(v3); err != nil {
+		t.Fatal(err)
+	}
+
+	h := &ReceiptHandler{
+		store: db,
+		rules: registry,
+	}
+
+	tests := []struct {
+		name             string
+		body             string
+		expectRescored   int
+		expectStatusCode int
+	}{
+		{
+			name:             "unknown version",
+			body:             `{"version": "4"}`,
+			expectStatusCode: http.StatusNotFound,
+		},
+		{
+			name:             "missing version",
+			body:             `{}`,
+			expectStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:             "rescore with version 3",
+			body:             `{"version": "3"}`,
+			expectRescored:   1,
+			expectStatusCode: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodPost, "/admin/rescore", strings.NewReader(tt.body))
+
+			h.Rescore(w, r)
+
+			if w.Result().StatusCode != tt.expectStatusCode {
+				t.Fatalf("the response status code did not match. Got %d, want %d", w.Result().StatusCode, tt.expectStatusCode)
+			}
+
+			if tt.expectStatusCode != http.StatusOK {
+				return
+			}
+
+			var got rescoreResponse
+			if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+				t.Fatal(err)
+			}
+
+			if got.Rescored != tt.expectRescored {
+				t.Errorf("the rescored count did not match. Got %d, want %d", got.Rescored, tt.expectRescored)
+			}
+		})
+	}
+
+	points := []struct {
+		name             string
+		version          string
+		expectPoints     int
+		expectVersion    string
+		expectStatusCode int
+	}{
+		{
+			name:             "original points",
+			expectPoints:     original.Points,
+			expectVersion:    receipt.DefaultVersion,
+			expectStatusCode: http.StatusOK,
+		},
+		{
+			name:             "rescored points",
+			version:          "3",
+			expectPoints:     original.Points + 2*13,
+			expectVersion:    "3",
+			expectStatusCode: http.StatusOK,
+		},
+		{
+			name:             "version that was never scored",
+			version:          "4",
+			expectStatusCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range points {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/receipts/%s/points?version=%s", id, tt.version), nil)
+			r.SetPathValue("id", id)
+
+			h.GetPointsForID(w, r)
+
+			if w.Result().StatusCode != tt.expectStatusCode {
+				t.Fatalf("the response status code did not match. Got %d, want %d", w.Result().StatusCode, tt.expectStatusCode)
+			}
+
+			if tt.expectStatusCode != http.StatusOK {
+				return
+			}
+
+			var got getPointsResponse
+			if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+				t.Fatal(err)
+			}
+
+			if got.Points != tt.expectPoints || got.Version != tt.expectVersion {
+				t.Errorf("the response did not match. Got %+v, want %d points with version %s", got, tt.expectPoints, tt.expectVersion)
+			}
+		})
+	}
+}
+
+func TestReceiptHandler_RescoreFailure(t *testing.T) {
+	db := database.NewInMemoryDatabase()
+
+	target := receipt.Receipt{Retailer: "Target", Total: receipt.MustParseMoney("1.00")}
+	targetID, err := db.Insert(target, receipt.Breakdown{Version: receipt.DefaultVersion})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the rules have no exchange rates, so the receipt in euros
+	// cannot be rescored
+	euros := receipt.Receipt{Retailer: "Carrefour", Currency: "EUR", Total: receipt.MustParseMoney("1.00")}
+	eurosID, err := db.Insert(euros, receipt.Breakdown{Version: receipt.DefaultVersion})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := receipt.DefaultConfig()
+	cfg.Version = "3"
+	v3, err := receipt.NewConfigRuleSet(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	registry := receipt.NewDefaultRegistry()
+	if err := registry.Register(v3); err != nil {
+		t.Fatal(err)
+	}
+
+	h := New(db, WithRegistry(registry))
+
+	w := httptest.NewRecorder()
+	h.Rescore(w, httptest.NewRequest(http.MethodPost, "/admin/rescore", strings.NewReader(`{"version": "3"}`)))
+
+	if w.Result().StatusCode != http.StatusOK {
+		t.Fatalf("the response status code did not match. Got %d, want %d", w.Result().StatusCode, http.StatusOK)
+	}
+
+	var got rescoreResponse
+	if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+
+	if got.Rescored != 1 {
+		t.Errorf("the rescored count did not match. Got %d, want %d", got.Rescored, 1)
+	}
+
+	want := []receipt.FieldError{{Pointer: "/currency", Constraint: receipt.ConstraintExchangeRate, Value: "EUR"}}
+	if len(got.Failed) != 1 || got.Failed[0].ID != eurosID || !reflect.DeepEqual(got.Failed[0].Errors, want) {
+		t.Errorf("the failed receipts did not match. Got %+v, want %s with %+v", got.Failed, eurosID, want)
+	}
+
+	if _, err := db.GetBreakdown(targetID, "3"); err != nil {
+		t.Errorf("the other receipt was not rescored: %v", err)
+	}
+}
+
+func TestReceiptHandler_Admin(t *testing.T) {
+	tests := []struct {
+		name             string
+		token            string
+		authorization    string
+		expectStatusCode int
+	}{
+		{
+			name:             "no admin token configured",
+			authorization:    "Bearer secret",
+			expectStatusCode: http.StatusForbidden,
+		},
+		{
+			name:             "missing token",
+			token:            "secret",
+			expectStatusCode: http.StatusUnauthorized,
+		},
+		{
+			name:             "wrong token",
+			token:            "secret",
+			authorization:    "Bearer guess",
+			expectStatusCode: http.StatusUnauthorized,
+		},
+		{
+			name:             "not a bearer token",
+			token:            "secret",
+			authorization:    "secret",
+			expectStatusCode: http.StatusUnauthorized,
+		},
+		{
+			name:             "admin token",
+			token:            "secret",
+			authorization:    "Bearer secret",
+			expectStatusCode: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := New(database.NewInMemoryDatabase(), WithAdminToken(tt.token))
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodPost, "/admin/rescore", strings.NewReader(`{"version": "1"}`))
+			if tt.authorization != "" {
+				r.Header.Set("Authorization", tt.authorization)
+			}
+
+			h.Routes()["POST /admin/rescore"](w, r)
+
+			if w.Result().StatusCode != tt.expectStatusCode {
+				t.Errorf("the response status code did not match. Got %d, want %d", w.Result().StatusCode, tt.expectStatusCode)
+			}
+		})
+	}
+}
+
+func TestReceiptHandler_ProcessReceiptInvalidMoney(t *testing.T) {
+	h := New(database.NewInMemoryDatabase())
+
+	tests := []struct {
+		name             string
+		body             string
+		expectStatusCode int
+	}{
+		{
+			name:             "price with too many decimals",
+			body:             `{"retailer": "Target", "purchaseDate": "2022-01-02", "purchaseTime": "13:13", "total": "1.25", "items": [{"shortDescription": "Pepsi - 12-oz", "price": "1.2499"}]}`,
+			expectStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:             "total in scientific notation",
+			body:             `{"retailer": "Target", "purchaseDate": "2022-01-02", "purchaseTime": "13:13", "total": "1e5", "items": [{"shortDescription": "Pepsi - 12-oz", "price": "1.25"}]}`,
+			expectStatusCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodPost, "/receipts/process", strings.NewReader(tt.body))
+			h.ProcessReceipt(w, r)
+
+			if w.Result().StatusCode != tt.expectStatusCode {
+				t.Errorf("the response status code did not match. Got %d, want %d", w.Result().StatusCode, tt.expectStatusCode)
+			}
+		})
+	}
+}
+
+func TestReceiptHandler_GetReceiptForID(t *testing.T) {
+	db := database.NewInMemoryDatabase()
+
+	tests := []struct {
+		name             string
+		file             string
+		expectStatusCode int
+	}{
+		{
+			name:             "test receipt round trips",
+			file:             "../examples/test-receipt.json",
+			expectStatusCode: http.StatusOK,
+		},
+		{
+			name:             "morning receipt round trips",
+			file:             "../examples/morning-receipt.json",
+			expectStatusCode: http.StatusOK,
+		},
+		{
+			name:             "simple receipt round trips",
+			file:             "../examples/simple-receipt.json",
+			expectStatusCode: http.StatusOK,
+		},
+		{
+			name:             "id not found",
+			expectStatusCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &ReceiptHandler{
+				store: db,
+			}
+
+			id := "does-not-exist"
+			var want map[string]any
+			if tt.file != "" {
+				testFile, err := os.ReadFile(tt.file)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if err := json.Unmarshal(testFile, &want); err != nil {
+					t.Fatal(err)
+				}
+
+				var rcpt receipt.Receipt
+				if err := json.Unmarshal(testFile, &rcpt); err != nil {
+					t.Fatal(err)
+				}
+
+				id, err = db.Insert(rcpt, receipt.Breakdown{})
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/receipts/%s", id), nil)
+			r.SetPathValue("id", id)
+
+			h.GetReceiptForID(w, r)
+
+			if w.Result().StatusCode != tt.expectStatusCode {
+				t.Errorf("the response status code did not match. Got %d, want %d", w.Result().StatusCode, tt.expectStatusCode)
+			}
+
+			if want == nil {
+				return
+			}
+
+			if w.Header().Get("Last-Modified") == "" {
+				t.Error("the response is missing the Last-Modified header")
+			}
+
+			var got map[string]any
+			if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("the response receipt did not match. Got %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestReceiptHandler_ListReceipts(t *testing.T) {
+	db := database.NewInMemoryDatabase()
+	for _, file := range []string{"../examples/test-receipt.json", "../examples/morning-receipt.json", "../examples/simple-receipt.json"} {
+		testFile, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var rcpt receipt.Receipt
+		if err := json.Unmarshal(testFile, &rcpt); err != nil {
+			t.Fatal(err)
+		}
+
+		breakdown, err := rcpt.GetBreakdown()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := db.Insert(rcpt, breakdown); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name             string
+		query            string
+		expectCount      int
+		expectStatusCode int
+	}{
+		{
+			name:             "every receipt",
+			query:            "",
+			expectCount:      3,
+			expectStatusCode: http.StatusOK,
+		},
+		{
+			name:             "every receipt one page at a time",
+			query:            "limit=1",
+			expectCount:      3,
+			expectStatusCode: http.StatusOK,
+		},
+		{
+			name:             "filtered by retailer",
+			query:            "retailer=WALGREENS",
+			expectCount:      1,
+			expectStatusCode: http.StatusOK,
+		},
+		{
+			name:             "filtered by purchase date sorted by points",
+			query:            "purchaseDateFrom=2022-01-02&purchaseDateTo=2022-01-02&sort=points&order=desc&limit=2",
+			expectCount:      3,
+			expectStatusCode: http.StatusOK,
+		},
+		{
+			name:             "filtered by points",
+			query:            "minPoints=1000",
+			expectCount:      0,
+			expectStatusCode: http.StatusOK,
+		},
+		{
+			name:             "invalid date",
+			query:            "purchaseDateFrom=01/02/2022",
+			expectStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:             "invalid sort",
+			query:            "sort=retailer",
+			expectStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:             "invalid cursor",
+			query:            "cursor=abc",
+			expectStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:             "limit too large",
+			query:            "limit=1000",
+			expectStatusCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &ReceiptHandler{
+				store: db,
+			}
+
+			query := tt.query
+			var got []string
+			for {
+				w := httptest.NewRecorder()
+				r := httptest.NewRequest(http.MethodGet, "/receipts?"+query, nil)
+
+				h.ListReceipts(w, r)
+
+				if w.Result().StatusCode != tt.expectStatusCode {
+					t.Fatalf("the response status code did not match. Got %d, want %d", w.Result().StatusCode, tt.expectStatusCode)
+				}
+
+				if tt.expectStatusCode != http.StatusOK {
+					return
+				}
+
+				var resp listReceiptsResponse
+				if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+					t.Fatal(err)
+				}
+
+				for _, l := range resp.Receipts {
+					got = append(got, l.ID)
+				}
+
+				if resp.NextCursor == "" {
+					break
+				}
+				query = tt.query + "&cursor=" + resp.NextCursor
+			}
+
+			if len(got) != tt.expectCount {
+				t.Errorf("the number of receipts did not match. Got %d, want %d", len(got), tt.expectCount)
+			}
+		})
+	}
+}
+
+func TestReceiptHandler_ProcessBatch(t *testing.T) {
+	db := database.NewInMemoryDatabase()
+	testFile, err := os.ReadFile("../examples/test-receipt.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var rcpt receipt.Receipt
+	if err := json.Unmarshal(testFile, &rcpt); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := db.Insert(rcpt, receipt.Breakdown{Points: 10}); err != nil {
+		t.Fatal(err)
+	}
+
+	morningFile, err := os.ReadFile("../examples/morning-receipt.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	missingRetailer := `{"purchaseDate": "2022-01-02", "purchaseTime": "13:13", "total": "1.25",
+		"items": [{"shortDescription": "Pepsi - 12-oz", "price": "1.25"}]}`
+	invalidTotal := `{"retailer": "Target", "purchaseDate": "2022-01-02", "purchaseTime": "13:13", "total": "1.2",
+		"items": [{"shortDescription": "Pepsi - 12-oz", "price": "1.25"}]}`
+
+	tests := []struct {
+		name             string
+		body             string
+		expectResults    []batchResult
+		expectStatusCode int
+	}{
+		{
+			name: "every receipt is processed on its own",
+			body: fmt.Sprintf("[%s, %s, %s, %s, %s]", morningFile, morningFile, missingRetailer, invalidTotal, testFile),
+			expectResults: []batchResult{
+				{Index: 0},
+				{Index: 1, receiptResult: receiptResult{Error: "receipt has already been submitted", Duplicate: true}},
+				{Index: 2, receiptResult: receiptResult{Errors: []receipt.FieldError{{Pointer: "/retailer", Constraint: "required"}}}},
+				{Index: 3, receiptResult: receiptResult{Error: `invalid amount: "1.2"`, Errors: []receipt.FieldError{{Pointer: "/total", Constraint: "amount", Value: "1.2"}}}},
+				{Index: 4, receiptResult: receiptResult{Error: "receipt has already been submitted", Duplicate: true}},
+			},
+			expectStatusCode: http.StatusOK,
+		},
+		{
+			name:             "empty batch",
+			body:             "[]",
+			expectStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:             "not an array",
+			body:             string(morningFile),
+			expectStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:             "too many receipts",
+			body:             "[" + strings.Repeat(string(morningFile)+",", maxBatchSize) + string(morningFile) + "]",
+			expectStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:             "body too large",
+			body:             "[" + strings.Repeat(" ", maxBatchBytes) + "]",
+			expectStatusCode: http.StatusRequestEntityTooLarge,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &ReceiptHandler{
+				store:     db,
+				validator: receipt.NewValidator(),
+				rules:     receipt.NewRegistry(receipt.DefaultRuleSet()),
+			}
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodPost, "/receipts/batch", strings.NewReader(tt.body))
+			h.ProcessBatch(w, r)
+
+			if w.Result().StatusCode != tt.expectStatusCode {
+				t.Fatalf("the response status code did not match. Got %d, want %d", w.Result().StatusCode, tt.expectStatusCode)
+			}
+
+			if tt.expectStatusCode != http.StatusOK {
+				return
+			}
+
+			var resp batchResponse
+			if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+				t.Fatal(err)
+			}
+
+			if len(resp.Results) != len(tt.expectResults) {
+				t.Fatalf("the number of results did not match. Got %d, want %d", len(resp.Results), len(tt.expectResults))
+			}
+
+			for i, want := range tt.expectResults {
+				got := resp.Results[i]
+				if (got.ID != "") != (want.Error == "" && want.Errors == nil) {
+					t.Errorf("result %d id did not match. Got %q", i, got.ID)
+				}
+
+				if got.Index != want.Index || got.Duplicate != want.Duplicate || !reflect.DeepEqual(got.Errors, want.Errors) {
+					t.Errorf("result %d did not match. Got %+v, want %+v", i, got, want)
+				}
+
+				if want.Error != "" && got.Error != want.Error {
+					t.Errorf("result %d error did not match. Got %q, want %q", i, got.Error, want.Error)
+				}
+			}
+		})
+	}
+}
+
+func TestReceiptHandler_ProcessReceiptProblem(t *testing.T) {
+	h := New(database.NewInMemoryDatabase())
+
+	tests := []struct {
+		name             string
+		body             string
+		expectErrors     []receipt.FieldError
+		expectStatusCode int
+	}{
+		{
+			name: "missing fields",
+			body: `{"retailer": "Target", "purchaseDate": "2022-01-02", "purchaseTime": "13:13", "total": "1.25",
+				"items": [{"shortDescription": "Pepsi - 12-oz", "price": "1.25"}, {"price": "1.25"}]}`,
+			expectErrors:     []receipt.FieldError{{Pointer: "/items/1/shortDescription", Constraint: "required"}},
+			expectStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:             "invalid purchase time",
+			body:             `{"retailer": "Target", "purchaseDate": "2022-01-02", "purchaseTime": "25:13"}`,
+			expectErrors:     []receipt.FieldError{{Pointer: "/purchaseTime", Constraint: "time", Value: "25:13"}},
+			expectStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:             "invalid price",
+			body:             `{"retailer": "Target", "items": [{"shortDescription": "Pepsi - 12-oz", "price": "1.2499"}]}`,
+			expectErrors:     []receipt.FieldError{{Pointer: "/items/0/price", Constraint: "amount", Value: "1.2499"}},
+			expectStatusCode: http.StatusBadRequest,
+		},
+		{
+			name: "subtotal that does not add up",
+			body: `{"retailer": "Target", "purchaseDate": "2022-01-02", "purchaseTime": "13:13", "total": "1.35",
+				"subtotal": "1.25", "tax": "0.15", "items": [{"shortDescription": "Pepsi - 12-oz", "price": "1.25"}]}`,
+			expectErrors:     []receipt.FieldError{{Pointer: "/total", Constraint: receipt.ConstraintSum, Value: "1.35"}},
+			expectStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:             "malformed JSON",
+			body:             `{"retailer": "Target",`,
+			expectStatusCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodPost, "/receipts/process", strings.NewReader(tt.body))
+			h.ProcessReceipt(w, r)
+
+			if w.Result().StatusCode != tt.expectStatusCode {
+				t.Errorf("the response status code did not match. Got %d, want %d", w.Result().StatusCode, tt.expectStatusCode)
+			}
+
+			if got := w.Header().Get("Content-Type"); got != problem.ContentType {
+				t.Errorf("the response content type did not match. Got %s, want %s", got, problem.ContentType)
+			}
+
+			var got problem.Problem
+			if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+				t.Fatal(err)
+			}
+
+			if got.Status != tt.expectStatusCode || got.Title != http.StatusText(tt.expectStatusCode) || got.Detail == "" {
+				t.Errorf("the response problem did not match. Got %+v", got)
+			}
+
+			if !reflect.DeepEqual(got.Errors, tt.expectErrors) {
+				t.Errorf("the response errors did not match. Got %+v, want %+v", got.Errors, tt.expectErrors)
+			}
+		})
+	}
+}
+
+func TestReceiptHandler_ProcessReceiptInconsistent(t *testing.T) {
+	body := `{"retailer": "Target", "purchaseDate": "2022-01-02", "purchaseTime": "13:13", "total": "9.00",
+		"items": [{"shortDescription": "Pepsi - 12-oz", "price": "1.25"}]}`
+
+	tests := []struct {
+		name             string
+		policy           receipt.InconsistentPolicy
+		expectStatusCode int
+		expectErrors     []receipt.FieldError
+		expectZero       bool
+	}{
+		{
+			name:             "rejected",
+			policy:           receipt.PolicyReject,
+			expectStatusCode: http.StatusBadRequest,
+			expectErrors:     []receipt.FieldError{{Pointer: "/total", Constraint: receipt.ConstraintReconciliation, Value: "9.00"}},
+		},
+		{
+			name:             "flagged",
+			policy:           receipt.PolicyFlag,
+			expectStatusCode: http.StatusCreated,
+		},
+		{
+			name:             "scored at zero",
+			policy:           receipt.PolicyZero,
+			expectStatusCode: http.StatusCreated,
+			expectZero:       true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := receipt.DefaultConfig()
+			cfg.Reconciliation.Policy = tt.policy
+			rules, err := receipt.NewConfigRuleSet(cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			db := database.NewInMemoryDatabase()
+			h := New(db, WithRuleSet(rules))
+
+			w := httptest.NewRecorder()
+			h.ProcessReceipt(w, httptest.NewRequest(http.MethodPost, "/receipts/process", strings.NewReader(body)))
+
+			if w.Result().StatusCode != tt.expectStatusCode {
+				t.Fatalf("the response status code did not match. Got %d, want %d", w.Result().StatusCode, tt.expectStatusCode)
+			}
+
+			if tt.expectStatusCode != http.StatusCreated {
+				var got problem.Problem
+				if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+					t.Fatal(err)
+				}
+
+				if !reflect.DeepEqual(got.Errors, tt.expectErrors) {
+					t.Errorf("the response errors did not match. Got %+v, want %+v", got.Errors, tt.expectErrors)
+				}
+				return
+			}
+
+			var processed processReceiptResponse
+			if err := json.NewDecoder(w.Body).Decode(&processed); err != nil {
+				t.Fatal(err)
+			}
+
+			breakdown, err := db.GetBreakdown(processed.Id, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			rec := breakdown.Reconciliation
+			if rec == nil || rec.Status != receipt.ReconciliationInconsistent || rec.Policy != tt.policy {
+				t.Errorf("the stored reconciliation did not match. Got %+v, want %s with policy %s", rec, receipt.ReconciliationInconsistent, tt.policy)
+			}
+
+			if (breakdown.Points == 0) != tt.expectZero {
+				t.Errorf("the stored points did not match. Got %d", breakdown.Points)
+			}
+		})
+	}
+}
+
+func TestReceiptHandler_ProcessReceiptCurrency(t *testing.T) {
+	body := `{"retailer": "Target", "purchaseDate": "2022-01-02", "purchaseTime": "13:13", "total": "1.25",
+		"currency": "CAD", "items": [{"shortDescription": "Pepsi - 12-oz", "price": "1.25"}]}`
+
+	h := New(database.NewInMemoryDatabase())
+
+	w := httptest.NewRecorder()
+	h.ProcessReceipt(w, httptest.NewRequest(http.MethodPost, "/receipts/process", strings.NewReader(body)))
+
+	if w.Result().StatusCode != http.StatusBadRequest {
+		t.Fatalf("the response status code did not match. Got %d, want %d", w.Result().StatusCode, http.StatusBadRequest)
+	}
+
+	var got problem.Problem
+	if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []receipt.FieldError{{Pointer: "/currency", Constraint: receipt.ConstraintExchangeRate, Value: "CAD"}}
+	if !reflect.DeepEqual(got.Errors, want) {
+		t.Errorf("the response errors did not match. Got %+v, want %+v", got.Errors, want)
+	}
+}
+
+func TestReceiptHandler_ProcessReceiptDateFormats(t *testing.T) {
+	formats := receipt.DateFormats{
+		DateLayouts: []string{"01/02/2006", "02/01/2006"},
+		TimeLayouts: []string{"3:04 PM"},
+	}
+
+	tests := []struct {
+		name        string
+		date        string
+		time        string
+		wantStatus  int
+		wantPointer string
+		wantStored  string
+	}{
+		{name: "format of api.yml", date: "2022-12-25", time: "13:13", wantStatus: http.StatusCreated, wantStored: `"2022-12-25","purchaseTime":"13:13"`},
+		{name: "other layouts", date: "12/25/2022", time: "1:13 PM", wantStatus: http.StatusCreated, wantStored: `"2022-12-25","purchaseTime":"13:13"`},
+		{name: "ambiguous date", date: "01/02/2022", time: "13:13", wantStatus: http.StatusBadRequest, wantPointer: "/purchaseDate"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := `{"retailer": "Target", "purchaseDate": "` + tt.date + `", "purchaseTime": "` + tt.time + `", "total": "1.25",
+				"items": [{"shortDescription": "Pepsi - 12-oz", "price": "1.25"}]}`
+
+			db := database.NewInMemoryDatabase()
+			h := New(db, WithDateFormats(formats))
+
+			w := httptest.NewRecorder()
+			h.ProcessReceipt(w, httptest.NewRequest(http.MethodPost, "/receipts/process", strings.NewReader(body)))
+
+			if w.Result().StatusCode != tt.wantStatus {
+				t.Fatalf("the response status code did not match. Got %d, want %d", w.Result().StatusCode, tt.wantStatus)
+			}
+
+			if tt.wantPointer != "" {
+				var got problem.Problem
+				if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+					t.Fatal(err)
+				}
+
+				if len(got.Errors) != 1 || got.Errors[0].Pointer != tt.wantPointer {
+					t.Errorf("the response errors did not match. Got %+v, want %s", got.Errors, tt.wantPointer)
+				}
+				return
+			}
+
+			var got processReceiptResponse
+			if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+				t.Fatal(err)
+			}
+
+			// the receipt is stored in the formats of api.yml
+			stored, err := db.GetReceipt(got.Id)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			b, err := json.Marshal(stored.Receipt)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !strings.Contains(string(b), `"purchaseDate":`+tt.wantStored) {
+				t.Errorf("the stored receipt did not match. Got %s, want purchaseDate %s", b, tt.wantStored)
+			}
+		})
+	}
+}
+
+func TestReceiptHandler_ProcessReceiptScoringError(t *testing.T) {
+	body := `{"retailer": "Target", "purchaseDate": "2022-01-02", "purchaseTime": "13:13", "total": "1.25",
+		"items": [{"shortDescription": "Pepsi - 12-oz", "price": "1.25"}]}`
+
+	rules, err := receipt.NewRuleSet("2", receipt.NewRule("pepsi", func(r receipt.Receipt) ([]receipt.RuleResult, error) {
+		return nil, &receipt.ScoringError{Pointer: "/items/0/shortDescription", Item: 0, Err: errors.New("unknown product")}
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h := New(database.NewInMemoryDatabase(), WithRuleSet(rules))
+
+	w := httptest.NewRecorder()
+	h.ProcessReceipt(w, httptest.NewRequest(http.MethodPost, "/receipts/process", strings.NewReader(body)))
+
+	if w.Result().StatusCode != http.StatusUnprocessableEntity {
+		t.Fatalf("the response status code did not match. Got %d, want %d", w.Result().StatusCode, http.StatusUnprocessableEntity)
+	}
+
+	var got problem.Problem
+	if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []receipt.FieldError{{Pointer: "/items/0/shortDescription", Constraint: receipt.ConstraintScore}}
+	if !reflect.DeepEqual(got.Errors, want) {
+		t.Errorf("the response errors did not match. Got %+v, want %+v", got.Errors, want)
+	}
+
+	if wantDetail := "the receipt cannot be scored, rule pepsi: /items/0/shortDescription: unknown product"; got.Detail != wantDetail {
+		t.Errorf("the response detail did not match. Got %q, want %q", got.Detail, wantDetail)
+	}
+}
+
+func TestReceiptHandler_ProcessReceiptRuleError(t *testing.T) {
+	body := `{"retailer": "Target", "purchaseDate": "2022-01-02", "purchaseTime": "13:13", "total": "1.25",
+		"items": [{"shortDescription": "Pepsi - 12-oz", "price": "1.25"}]}`
+
+	rules, err := receipt.NewRuleSet("2", receipt.NewRule("prices", func(r receipt.Receipt) ([]receipt.RuleResult, error) {
+		return nil, errors.New("price service unavailable at 10.0.0.5")
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h := New(database.NewInMemoryDatabase(), WithRuleSet(rules))
+
+	w := httptest.NewRecorder()
+	h.ProcessReceipt(w, httptest.NewRequest(http.MethodPost, "/receipts/process", strings.NewReader(body)))
+
+	if w.Result().StatusCode != http.StatusInternalServerError {
+		t.Fatalf("the response status code did not match. Got %d, want %d", w.Result().StatusCode, http.StatusInternalServerError)
+	}
+
+	var got problem.Problem
+	if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Contains(got.Detail, "price service") {
+		t.Errorf("the response detail exposes the rule error: %q", got.Detail)
+	}
+}
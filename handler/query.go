@@ -0,0 +1,95 @@
+package handler
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/afranco07/receipt-processor/database"
+)
+
+// parseListQuery reads the filters, order and page of GET /receipts
+// from the query string
+func parseListQuery(values url.Values) (database.ListQuery, error) {
+	q := database.ListQuery{
+		Retailer: values.Get("retailer"),
+		Sort:     database.SortField(values.Get("sort")),
+		Cursor:   values.Get("cursor"),
+	}
+
+	var err error
+	if q.PurchasedFrom, err = parseTime(values, "purchaseDateFrom", time.DateOnly); err != nil {
+		return q, err
+	}
+
+	if q.PurchasedTo, err = parseTime(values, "purchaseDateTo", time.DateOnly); err != nil {
+		return q, err
+	}
+
+	if q.ReceivedFrom, err = parseTime(values, "receivedFrom", time.RFC3339); err != nil {
+		return q, err
+	}
+
+	if q.ReceivedTo, err = parseTime(values, "receivedTo", time.RFC3339); err != nil {
+		return q, err
+	}
+
+	if q.MinPoints, err = parseInt(values, "minPoints"); err != nil {
+		return q, err
+	}
+
+	if q.MaxPoints, err = parseInt(values, "maxPoints"); err != nil {
+		return q, err
+	}
+
+	limit, err := parseInt(values, "limit")
+	if err != nil {
+		return q, err
+	}
+
+	if limit != nil {
+		if *limit < 1 || *limit > database.MaxListLimit {
+			return q, fmt.Errorf("limit must be between 1 and %d", database.MaxListLimit)
+		}
+		q.Limit = *limit
+	}
+
+	switch order := values.Get("order"); order {
+	case "", "asc":
+	case "desc":
+		q.Descending = true
+	default:
+		return q, fmt.Errorf("%s is not a valid value for order", order)
+	}
+
+	return q, nil
+}
+
+func parseTime(values url.Values, name, layout string) (time.Time, error) {
+	v := values.Get(name)
+	if v == "" {
+		return time.Time{}, nil
+	}
+
+	t, err := time.Parse(layout, v)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%s is not a valid value for %s", v, name)
+	}
+
+	return t, nil
+}
+
+func parseInt(values url.Values, name string) (*int, error) {
+	v := values.Get(name)
+	if v == "" {
+		return nil, nil
+	}
+
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return nil, fmt.Errorf("%s is not a valid value for %s", v, name)
+	}
+
+	return &n, nil
+}
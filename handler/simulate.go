@@ -0,0 +1,119 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/afranco07/receipt-processor/database"
+	"github.com/afranco07/receipt-processor/receipt"
+)
+
+// simulateRequest is a receipt, or the id of a stored receipt, and
+// the values of the rules to override
+type simulateRequest struct {
+	ID      string          `json:"id"`
+	Receipt json.RawMessage `json:"receipt"`
+	Rules   json.RawMessage `json:"rules"`
+}
+
+type simulateResponse struct {
+	Current    receipt.Breakdown `json:"current"`
+	Proposed   receipt.Breakdown `json:"proposed"`
+	Difference int               `json:"difference"`
+}
+
+// Simulate scores a receipt with the current rules and with the
+// current rules overridden by the values in the request, to preview
+// a change to the rules. A receipt the proposed rules would refuse
+// to store is refused. Nothing is stored, so the receipt can still
+// be processed afterwards
+func (h *ReceiptHandler) Simulate(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+
+	var req simulateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		log.Printf("invalid simulate request: %v", err)
+		writeProblem(w, &processError{status: http.StatusBadRequest, message: "the body must be an object with a receipt or id and the rules"})
+		return
+	}
+
+	if (req.ID == "") == (len(req.Receipt) == 0) {
+		log.Println("simulate request without exactly one of id and receipt")
+		writeProblem(w, &processError{status: http.StatusBadRequest, message: "exactly one of id and receipt is required"})
+		return
+	}
+
+	if len(req.Rules) == 0 {
+		log.Println("simulate request without rules")
+		writeProblem(w, &processError{status: http.StatusBadRequest, message: "rules is required"})
+		return
+	}
+
+	current := h.rules.Current()
+	proposed, err := current.Override(req.Rules)
+	if err != nil {
+		log.Printf("invalid simulate rules: %v", err)
+		writeProblem(w, &processError{status: http.StatusBadRequest, message: err.Error()})
+		return
+	}
+
+	rcpt, perr := h.simulatedReceipt(req)
+	if perr != nil {
+		writeProblem(w, perr)
+		return
+	}
+
+	resp := simulateResponse{}
+	resp.Current, perr = h.scoreReceipt(current, rcpt)
+	if perr != nil {
+		writeProblem(w, perr)
+		return
+	}
+
+	resp.Proposed, perr = h.scoreReceipt(proposed, rcpt)
+	if perr != nil {
+		writeProblem(w, perr)
+		return
+	}
+
+	// a receipt the proposed rules would refuse to store gets no
+	// score under them, the same way it would be processed
+	if perr := reconciliationError(resp.Proposed); perr != nil {
+		perr.message = "under the proposed rules " + perr.message
+		writeProblem(w, perr)
+		return
+	}
+	resp.Difference = resp.Proposed.Points - resp.Current.Points
+
+	w.WriteHeader(http.StatusOK)
+	_ = enc.Encode(resp)
+}
+
+// simulatedReceipt returns the receipt in the request, or the
+// stored receipt with its id
+func (h *ReceiptHandler) simulatedReceipt(req simulateRequest) (receipt.Receipt, *processError) {
+	if req.ID == "" {
+		var rcpt receipt.Receipt
+		if err := h.dates.Unmarshal(req.Receipt, &rcpt); err != nil {
+			log.Printf("error marshalling receipt: %v", err)
+			return receipt.Receipt{}, decodeError(err)
+		}
+
+		return rcpt, nil
+	}
+
+	stored, err := h.store.GetReceipt(req.ID)
+	if errors.Is(err, database.ErrNotFound) {
+		log.Printf("receipt with ID '%s' not found", req.ID)
+		return receipt.Receipt{}, &processError{status: http.StatusNotFound, message: fmt.Sprintf("receipt with ID '%s' not found", req.ID)}
+	} else if err != nil {
+		log.Printf("error getting receipt with id %s: %v", req.ID, err)
+		return receipt.Receipt{}, &processError{status: http.StatusInternalServerError, message: "something went wrong"}
+	}
+
+	return stored.Receipt, nil
+}
@@ -0,0 +1,203 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/afranco07/receipt-processor/database"
+	"github.com/afranco07/receipt-processor/problem"
+	"github.com/afranco07/receipt-processor/receipt"
+)
+
+func TestReceiptHandler_Simulate(t *testing.T) {
+	db := database.NewInMemoryDatabase()
+
+	testFile, err := os.ReadFile("../examples/test-receipt.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var stored receipt.Receipt
+	if err := json.Unmarshal(testFile, &stored); err != nil {
+		t.Fatal(err)
+	}
+
+	original, err := receipt.DefaultRuleSet().Score(stored)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	id, err := db.Insert(stored, original)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	simple := compactFile(t, "../examples/simple-receipt.json")
+	var inline receipt.Receipt
+	if err := json.Unmarshal([]byte(simple), &inline); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := receipt.DefaultConfig()
+	cfg.Version = "3"
+	cfg.Retailer.PointsPerCharacter = 3
+	proposed, err := receipt.NewConfigRuleSet(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// without a version the proposed rules are labeled as simulated
+	cfg.Version = receipt.SimulatedVersion
+	simulated, err := receipt.NewConfigRuleSet(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedWith := func(proposed *receipt.RuleSet, rcpt receipt.Receipt) simulateResponse {
+		current, err := receipt.DefaultRuleSet().Score(rcpt)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		next, err := proposed.Score(rcpt)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return simulateResponse{Current: current, Proposed: next, Difference: next.Points - current.Points}
+	}
+	expected := func(rcpt receipt.Receipt) simulateResponse {
+		return expectedWith(proposed, rcpt)
+	}
+
+	inconsistent := `{"retailer": "Target", "purchaseDate": "2022-01-02", "purchaseTime": "13:13", "total": "9.00",
+		"items": [{"shortDescription": "Pepsi - 12-oz", "price": "1.25"}]}`
+
+	rules := `"rules": {"version": "3", "retailer": {"pointsPerCharacter": 3}}`
+
+	tests := []struct {
+		name             string
+		body             string
+		expectResponse   simulateResponse
+		expectFields     []receipt.FieldError
+		expectStatusCode int
+	}{
+		{
+			name:             "receipt in the request",
+			body:             `{"receipt": ` + simple + `, ` + rules + `}`,
+			expectResponse:   expected(inline),
+			expectStatusCode: http.StatusOK,
+		},
+		{
+			name:             "stored receipt",
+			body:             `{"id": "` + id + `", ` + rules + `}`,
+			expectResponse:   expected(stored),
+			expectStatusCode: http.StatusOK,
+		},
+		{
+			name:             "rules without a version",
+			body:             `{"id": "` + id + `", "rules": {"retailer": {"pointsPerCharacter": 3}}}`,
+			expectResponse:   expectedWith(simulated, stored),
+			expectStatusCode: http.StatusOK,
+		},
+		{
+			name:             "receipt rejected under the proposed rules",
+			body:             `{"receipt": ` + inconsistent + `, "rules": {"version": "3", "reconciliation": {"policy": "reject"}}}`,
+			expectFields:     []receipt.FieldError{{Pointer: "/total", Constraint: receipt.ConstraintReconciliation, Value: "9.00"}},
+			expectStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:             "unknown id",
+			body:             `{"id": "abc", ` + rules + `}`,
+			expectStatusCode: http.StatusNotFound,
+		},
+		{
+			name:             "id and receipt",
+			body:             `{"id": "` + id + `", "receipt": ` + simple + `, ` + rules + `}`,
+			expectStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:             "no id or receipt",
+			body:             `{` + rules + `}`,
+			expectStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:             "missing rules",
+			body:             `{"id": "` + id + `"}`,
+			expectStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:             "invalid rules",
+			body:             `{"id": "` + id + `", "rules": {"total": {"basis": "tip"}}}`,
+			expectStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:             "invalid receipt",
+			body:             `{"receipt": {"retailer": "Target", "purchaseDate": "2022-01-02", "purchaseTime": "13:13", "total": "1.25", "items": []}, ` + rules + `}`,
+			expectFields:     []receipt.FieldError{{Pointer: "/items", Constraint: "gt", Value: []any{}}},
+			expectStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:             "not an object",
+			body:             `[]`,
+			expectStatusCode: http.StatusBadRequest,
+		},
+	}
+
+	h := New(db)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodPost, "/receipts/simulate", strings.NewReader(tt.body))
+
+			h.Simulate(w, r)
+
+			if w.Result().StatusCode != tt.expectStatusCode {
+				t.Fatalf("the response status code did not match. Got %d, want %d", w.Result().StatusCode, tt.expectStatusCode)
+			}
+
+			if tt.expectStatusCode != http.StatusOK {
+				var got problem.Problem
+				if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+					t.Fatal(err)
+				}
+
+				if tt.expectFields != nil && !reflect.DeepEqual(got.Errors, tt.expectFields) {
+					t.Errorf("the response errors did not match. Got %+v, want %+v", got.Errors, tt.expectFields)
+				}
+				return
+			}
+
+			var got simulateResponse
+			if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(got, tt.expectResponse) {
+				t.Errorf("the response did not match. Got %+v, want %+v", got, tt.expectResponse)
+			}
+		})
+	}
+
+	// nothing is stored, so the simulated receipt can still be processed
+	page, err := db.List(database.ListQuery{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(page.Receipts) != 1 {
+		t.Errorf("the number of stored receipts did not match. Got %d, want %d", len(page.Receipts), 1)
+	}
+
+	w := httptest.NewRecorder()
+	h.ProcessReceipt(w, httptest.NewRequest(http.MethodPost, "/receipts/process", strings.NewReader(simple)))
+	if w.Result().StatusCode != http.StatusCreated {
+		t.Errorf("the response status code did not match. Got %d, want %d", w.Result().StatusCode, http.StatusCreated)
+	}
+}
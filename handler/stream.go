@@ -0,0 +1,105 @@
+package handler
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+)
+
+const ndjsonContentType = "application/x-ndjson"
+
+// maxLineSize is the longest line ProcessStream reads, which
+// bounds the memory used by a stream
+const maxLineSize = 1 << 20
+
+var errLineTooLong = errors.New("line is too long")
+
+type streamResult struct {
+	Line int `json:"line"`
+	receiptResult
+}
+
+// ProcessStream processes a body of newline delimited receipts.
+// Every line is scored and stored as it is read and its result is
+// written back as a line of its own, so the body can be of any
+// size. Lines that cannot be processed are reported and skipped
+func (h *ReceiptHandler) ProcessStream(w http.ResponseWriter, r *http.Request) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != ndjsonContentType {
+		log.Printf("stream rejected with content type %q", r.Header.Get("Content-Type"))
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnsupportedMediaType)
+		_ = json.NewEncoder(w).Encode(errorMessage{Message: "the body must be " + ndjsonContentType})
+		return
+	}
+
+	// results are written while the body is still being read
+	rc := http.NewResponseController(w)
+	_ = rc.EnableFullDuplex()
+
+	w.Header().Set("Content-Type", ndjsonContentType)
+	w.WriteHeader(http.StatusOK)
+	enc := json.NewEncoder(w)
+
+	br := bufio.NewReaderSize(r.Body, maxLineSize)
+	processed := 0
+	for lineNumber := 1; ; lineNumber++ {
+		line, err := readLine(br)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		var result streamResult
+		switch {
+		case errors.Is(err, errLineTooLong):
+			log.Printf("line %d of stream is too long", lineNumber)
+			result = streamResult{Line: lineNumber, receiptResult: receiptResult{Error: err.Error()}}
+		case err != nil:
+			log.Printf("error reading stream after %d lines: %v", processed, err)
+			return
+		case len(line) == 0:
+			continue
+		default:
+			id, perr := h.processReceipt(json.NewDecoder(bytes.NewReader(line)))
+			result = streamResult{Line: lineNumber, receiptResult: newReceiptResult(id, perr)}
+		}
+
+		if err := enc.Encode(result); err != nil {
+			log.Printf("error writing stream result: %v", err)
+			return
+		}
+		_ = rc.Flush()
+		processed++
+	}
+
+	log.Printf("processed %d lines from stream", processed)
+}
+
+// readLine reads the next line without surrounding spaces. A line
+// that does not fit in the reader's buffer is skipped and returns
+// errLineTooLong. The line is only valid until the next read
+func readLine(r *bufio.Reader) ([]byte, error) {
+	line, err := r.ReadSlice('\n')
+	if errors.Is(err, bufio.ErrBufferFull) {
+		for errors.Is(err, bufio.ErrBufferFull) {
+			_, err = r.ReadSlice('\n')
+		}
+
+		if err != nil && !errors.Is(err, io.EOF) {
+			return nil, err
+		}
+		return nil, errLineTooLong
+	}
+
+	// the last line does not need to end with a newline
+	if err != nil && (!errors.Is(err, io.EOF) || len(line) == 0) {
+		return nil, err
+	}
+
+	return bytes.TrimSpace(line), nil
+}
@@ -0,0 +1,124 @@
+package handler
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/afranco07/receipt-processor/database"
+	"github.com/afranco07/receipt-processor/receipt"
+)
+
+// compactFile reads an example receipt as a single line
+func compactFile(t *testing.T, path string) string {
+	t.Helper()
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var v any
+	if err := json.Unmarshal(b, &v); err != nil {
+		t.Fatal(err)
+	}
+
+	line, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(line)
+}
+
+func TestReceiptHandler_ProcessStream(t *testing.T) {
+	morning := compactFile(t, "../examples/morning-receipt.json")
+	simple := compactFile(t, "../examples/simple-receipt.json")
+	tooLong := `{"retailer": "` + strings.Repeat("a", maxLineSize) + `"}`
+
+	tests := []struct {
+		name             string
+		contentType      string
+		body             string
+		expectResults    []streamResult
+		expectStatusCode int
+	}{
+		{
+			name:        "every line is processed and bad lines are skipped",
+			contentType: "application/x-ndjson",
+			body:        morning + "\n" + morning + "\n\n{not json\n" + tooLong + "\n" + simple,
+			expectResults: []streamResult{
+				{Line: 1},
+				{Line: 2, receiptResult: receiptResult{Error: "receipt has already been submitted", Duplicate: true}},
+				{Line: 4, receiptResult: receiptResult{Error: "the body is not valid JSON"}},
+				{Line: 5, receiptResult: receiptResult{Error: errLineTooLong.Error()}},
+				{Line: 6},
+			},
+			expectStatusCode: http.StatusOK,
+		},
+		{
+			name:             "content type with parameters",
+			contentType:      "application/x-ndjson; charset=utf-8",
+			body:             `{"retailer": "Target", "purchaseDate": "2022-13-01"}` + "\n",
+			expectResults:    []streamResult{{Line: 1, receiptResult: receiptResult{Error: "2022-13-01 is not a valid value"}}},
+			expectStatusCode: http.StatusOK,
+		},
+		{
+			name:             "wrong content type",
+			contentType:      "application/json",
+			body:             morning,
+			expectStatusCode: http.StatusUnsupportedMediaType,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &ReceiptHandler{
+				store:     database.NewInMemoryDatabase(),
+				validator: receipt.NewValidator(),
+				rules:     receipt.NewRegistry(receipt.DefaultRuleSet()),
+			}
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodPost, "/receipts/stream", strings.NewReader(tt.body))
+			r.Header.Set("Content-Type", tt.contentType)
+			h.ProcessStream(w, r)
+
+			if w.Result().StatusCode != tt.expectStatusCode {
+				t.Fatalf("the response status code did not match. Got %d, want %d", w.Result().StatusCode, tt.expectStatusCode)
+			}
+
+			if tt.expectStatusCode != http.StatusOK {
+				return
+			}
+
+			var got []streamResult
+			scanner := bufio.NewScanner(w.Body)
+			for scanner.Scan() {
+				var result streamResult
+				if err := json.Unmarshal(scanner.Bytes(), &result); err != nil {
+					t.Fatalf("result %q is not a JSON line: %v", scanner.Text(), err)
+				}
+				got = append(got, result)
+			}
+
+			if len(got) != len(tt.expectResults) {
+				t.Fatalf("the number of results did not match. Got %d, want %d", len(got), len(tt.expectResults))
+			}
+
+			for i, want := range tt.expectResults {
+				if got[i].Line != want.Line || got[i].Error != want.Error || got[i].Duplicate != want.Duplicate {
+					t.Errorf("result %d did not match. Got %+v, want %+v", i, got[i], want)
+				}
+
+				if (got[i].ID != "") != (want.Error == "") {
+					t.Errorf("result %d id did not match. Got %q", i, got[i].ID)
+				}
+			}
+		})
+	}
+}
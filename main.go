@@ -1,22 +1,206 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
+	"io"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
 
 	"github.com/afranco07/receipt-processor/database"
 	"github.com/afranco07/receipt-processor/handler"
+	"github.com/afranco07/receipt-processor/openapi"
+	"github.com/afranco07/receipt-processor/receipt"
 )
 
+// stringList is a flag that can be repeated
+type stringList []string
+
+func (s *stringList) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringList) Set(v string) error {
+	*s = append(*s, v)
+	return nil
+}
+
+// options are the flags shared by the server and the subcommands
+type options struct {
+	rulesPaths     stringList
+	currentVersion string
+	dataDir        string
+	sqlitePath     string
+	dateMode       string
+	dateLayouts    stringList
+	timeLayouts    stringList
+	adminToken     string
+}
+
+func (o *options) register(fs *flag.FlagSet) {
+	fs.Var(&o.rulesPaths, "rules", "path to a YAML or JSON file configuring a version of the scoring rules, can be repeated")
+	fs.StringVar(&o.currentVersion, "rules-version", "", "version of the rules used to score new receipts, defaults to the last rules file")
+	fs.StringVar(&o.dataDir, "data-dir", "", "directory to persist receipts in, receipts are only kept in memory if not set")
+	fs.StringVar(&o.sqlitePath, "sqlite", "", "path of a SQLite database to store receipts in")
+	fs.StringVar(&o.dateMode, "date-mode", string(receipt.DateModeStrict), "how purchase dates and times must match a layout: strict or lenient")
+	fs.Var(&o.dateLayouts, "date-layout", "Go layout purchase dates are also accepted in, e.g. 01/02/2006, can be repeated and is tried in order")
+	fs.StringVar(&o.adminToken, "admin-token", os.Getenv("ADMIN_TOKEN"), "bearer token of the admin endpoints, which are disabled without it, defaults to $ADMIN_TOKEN")
+	fs.Var(&o.timeLayouts, "time-layout", "Go layout purchase times are also accepted in, e.g. \"3:04 PM\", can be repeated and is tried in order")
+}
+
+// dateFormats returns the formats set by the date flags
+func (o *options) dateFormats() (receipt.DateFormats, error) {
+	mode, err := receipt.ParseDateMode(o.dateMode)
+	if err != nil {
+		return receipt.DateFormats{}, err
+	}
+
+	formats := receipt.DateFormats{Mode: mode, DateLayouts: o.dateLayouts, TimeLayouts: o.timeLayouts}
+	if err := formats.Validate(); err != nil {
+		return receipt.DateFormats{}, err
+	}
+
+	return formats, nil
+}
+
+// registry loads every version of the rules from the rules files
+func (o *options) registry() (*receipt.Registry, error) {
+	registry := receipt.NewDefaultRegistry()
+	for _, path := range o.rulesPaths {
+		rules, err := registry.Load(path)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := registry.SetCurrent(rules.Version()); err != nil {
+			return nil, err
+		}
+		log.Printf("Loaded scoring rules version %s from %s", rules.Version(), path)
+	}
+
+	if o.currentVersion != "" {
+		if err := registry.SetCurrent(o.currentVersion); err != nil {
+			return nil, err
+		}
+	}
+	log.Printf("Scoring new receipts with rules version %s", registry.Current().Version())
+
+	return registry, nil
+}
+
+// handler opens the store selected by the flags and returns a
+// handler using it. The closer is nil if the store is in memory
+func (o *options) handler() (handler.ReceiptHandler, io.Closer, error) {
+	registry, err := o.registry()
+	if err != nil {
+		return handler.ReceiptHandler{}, nil, err
+	}
+
+	formats, err := o.dateFormats()
+	if err != nil {
+		return handler.ReceiptHandler{}, nil, err
+	}
+	handlerOpts := []handler.Option{handler.WithRegistry(registry), handler.WithDateFormats(formats), handler.WithAdminToken(o.adminToken)}
+
+	switch {
+	case o.dataDir != "" && o.sqlitePath != "":
+		return handler.ReceiptHandler{}, nil, errors.New("-data-dir and -sqlite cannot be used together")
+	case o.dataDir != "":
+		db, err := database.NewFileDatabase(o.dataDir)
+		if err != nil {
+			return handler.ReceiptHandler{}, nil, err
+		}
+		log.Printf("Persisting receipts in %s", o.dataDir)
+
+		return handler.New(db, handlerOpts...), db, nil
+	case o.sqlitePath != "":
+		db, err := database.NewSQLiteDatabase(o.sqlitePath)
+		if err != nil {
+			return handler.ReceiptHandler{}, nil, err
+		}
+		log.Printf("Storing receipts in SQLite database %s", o.sqlitePath)
+
+		return handler.New(db, handlerOpts...), db, nil
+	default:
+		return handler.New(database.NewInMemoryDatabase(), handlerOpts...), nil, nil
+	}
+}
+
 func main() {
-	db := database.NewInMemoryDatabase()
-	receiptHandler := handler.New(db)
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "import":
+			runImport(os.Args[2:])
+			return
+		case "export":
+			runExport(os.Args[2:])
+			return
+		}
+	}
+
+	var opts options
+	opts.register(flag.CommandLine)
+	contractMode := flag.String("contract", string(openapi.ModeLog), "what to do with requests and responses that do not conform to openapi/api.yml: off, log or reject")
+	flag.Parse()
+
+	mode, err := openapi.ParseMode(*contractMode)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	contract, err := openapi.Load()
+	if err != nil {
+		log.Fatal(err)
+	}
 
-	http.HandleFunc("GET /receipts/{id}/points", receiptHandler.GetPointsForID)
-	http.HandleFunc("POST /receipts/process", receiptHandler.ProcessReceipt)
+	// the contract accepts the dates and times the handler does
+	formats, err := opts.dateFormats()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	receiptHandler, db, err := opts.handler()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if db != nil {
+		defer closeStore(db)
+	}
+
+	for pattern, handle := range receiptHandler.Routes() {
+		http.HandleFunc(pattern, handle)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	server := &http.Server{Addr: ":8080", Handler: contract.Middleware(mode, http.DefaultServeMux, openapi.WithDateFormats(formats))}
+	shutdown := make(chan struct{})
+	go func() {
+		defer close(shutdown)
+		<-ctx.Done()
+		log.Println("Shutting down server…")
+		if err := server.Shutdown(context.Background()); err != nil {
+			log.Printf("error shutting down server: %v", err)
+		}
+	}()
 
 	log.Println("Starting server on port :8080…")
-	if err := http.ListenAndServe(":8080", nil); err != nil {
+	if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
 		panic(err)
 	}
+
+	// wait for in-flight requests before the database is closed
+	<-shutdown
+}
+
+func closeStore(db io.Closer) {
+	if err := db.Close(); err != nil {
+		log.Printf("error closing database: %v", err)
+	}
 }
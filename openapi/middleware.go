@@ -0,0 +1,342 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/afranco07/receipt-processor/problem"
+	"github.com/afranco07/receipt-processor/receipt"
+)
+
+// Mode is what the middleware does with a request or response that
+// does not conform to the contract
+type Mode string
+
+const (
+	// ModeOff does not check requests or responses
+	ModeOff Mode = "off"
+	// ModeLog logs what does not conform and lets it through
+	ModeLog Mode = "log"
+	// ModeReject logs what does not conform, answers a request with
+	// 400 Bad Request and replaces a response with 500 Internal
+	// Server Error
+	ModeReject Mode = "reject"
+)
+
+// ParseMode parses the name of a mode
+func ParseMode(s string) (Mode, error) {
+	switch m := Mode(s); m {
+	case ModeOff, ModeLog, ModeReject:
+		return m, nil
+	}
+
+	return "", fmt.Errorf("invalid contract mode %q, want off, log or reject", s)
+}
+
+// middlewareOptions are the settings of Middleware
+type middlewareOptions struct {
+	dates receipt.DateFormats
+}
+
+// MiddlewareOption configures Middleware
+type MiddlewareOption func(*middlewareOptions)
+
+// WithDateFormats sets the formats the dates and times of request
+// bodies are accepted in, so a receipt the handler decodes in them
+// conforms. Only the formats of api.yml are accepted otherwise
+func WithDateFormats(f receipt.DateFormats) MiddlewareOption {
+	return func(o *middlewareOptions) {
+		o.dates = f
+	}
+}
+
+// Middleware checks the requests to the operations of the contract,
+// and their responses, against it. Requests that are not part of
+// the contract are passed to next as they are
+func (c *Contract) Middleware(mode Mode, next http.Handler, opts ...MiddlewareOption) http.Handler {
+	if mode == ModeOff {
+		return next
+	}
+
+	var o middlewareOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rt, params := c.find(r.Method, r.URL.Path)
+		if rt == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		violations, err := c.validateRequest(rt, params, r, o.dates)
+		if err != nil {
+			log.Printf("error reading request to %s %s: %v", rt.method, rt.template, err)
+			problem.Write(w, http.StatusBadRequest, "the request body could not be read", nil)
+			return
+		}
+
+		if len(violations) > 0 {
+			log.Printf("request to %s %s does not conform to the contract: %s", rt.method, rt.template, describe(violations))
+			if mode == ModeReject {
+				problem.Write(w, http.StatusBadRequest, "the request does not conform to the API contract", violations)
+				return
+			}
+		}
+
+		rec := &recorder{w: w, contract: c, route: rt, mode: mode, header: make(http.Header)}
+		next.ServeHTTP(rec, r)
+		rec.finish()
+	})
+}
+
+// validateRequest checks the parameters and the body of the request.
+// A body with a schema is read and replaced so next can read it
+// again, any other body, e.g. a CSV, is only checked by its content
+// type and is left to next to read as it is streamed. The dates and
+// times of the body are checked in the formats
+func (c *Contract) validateRequest(rt *route, params map[string]string, r *http.Request, dates receipt.DateFormats) ([]receipt.FieldError, error) {
+	var violations []receipt.FieldError
+	query := r.URL.Query()
+	for _, p := range rt.operation.Parameters {
+		switch p.In {
+		case "path":
+			violations = append(violations, c.Validate(p.Schema, params[p.Name], "/"+p.Name)...)
+		case "query":
+			if !query.Has(p.Name) {
+				if p.Required {
+					violations = append(violations, receipt.FieldError{Pointer: "/" + p.Name, Constraint: "required"})
+				}
+				continue
+			}
+
+			violations = append(violations, c.Validate(p.Schema, c.parameterValue(p.Schema, query.Get(p.Name)), "/"+p.Name)...)
+		}
+	}
+
+	body := rt.operation.RequestBody
+	if body == nil {
+		return violations, nil
+	}
+
+	media, ok := body.Content[mediaTypeOf(r.Header.Get("Content-Type"))]
+	if !ok {
+		return append(violations, receipt.FieldError{Pointer: "", Constraint: "contentType", Value: r.Header.Get("Content-Type")}), nil
+	}
+
+	if media.Schema == nil {
+		return violations, nil
+	}
+
+	b, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+	r.Body = io.NopCloser(bytes.NewReader(b))
+
+	if len(b) == 0 {
+		if body.Required {
+			violations = append(violations, receipt.FieldError{Pointer: "", Constraint: "required"})
+		}
+		return violations, nil
+	}
+
+	return append(violations, c.validateJSON(media.Schema, b, dates)...), nil
+}
+
+// parameterValue converts a query parameter to the type of its
+// schema, so it is checked like a value decoded from JSON. A value
+// that cannot be converted is left a string and fails the type
+func (c *Contract) parameterValue(s *Schema, v string) any {
+	s = c.resolve(s)
+	if s == nil {
+		return v
+	}
+
+	switch s.Type {
+	case "integer", "number":
+		if n, err := strconv.ParseFloat(v, 64); err == nil {
+			return n
+		}
+	case "boolean":
+		if b, err := strconv.ParseBool(v); err == nil {
+			return b
+		}
+	}
+
+	return v
+}
+
+// response returns the documented response for the status code,
+// along with the media type of its content type. The media type is
+// nil if the response has no content
+func (rt *route) response(status int, contentType string) (*mediaType, []receipt.FieldError) {
+	resp, ok := rt.operation.Responses[strconv.Itoa(status)]
+	if !ok {
+		resp, ok = rt.operation.Responses["default"]
+	}
+	if !ok {
+		return nil, []receipt.FieldError{{Pointer: "", Constraint: "status", Value: status}}
+	}
+
+	if len(resp.Content) == 0 {
+		return nil, nil
+	}
+
+	media, ok := resp.Content[mediaTypeOf(contentType)]
+	if !ok {
+		return nil, []receipt.FieldError{{Pointer: "", Constraint: "contentType", Value: contentType}}
+	}
+
+	return &media, nil
+}
+
+// validateJSON checks a JSON body against the schema, with its
+// dates and times in the formats
+func (c *Contract) validateJSON(s *Schema, b []byte, dates receipt.DateFormats) []receipt.FieldError {
+	var v any
+	if err := json.Unmarshal(b, &v); err != nil {
+		return []receipt.FieldError{{Pointer: "", Constraint: "type"}}
+	}
+
+	return c.validate(s, v, "", dates)
+}
+
+func mediaTypeOf(contentType string) string {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	return mediaType
+}
+
+func describe(violations []receipt.FieldError) string {
+	var buf bytes.Buffer
+	for i, v := range violations {
+		if i > 0 {
+			buf.WriteString(", ")
+		}
+		fmt.Fprintf(&buf, "%q %s", v.Pointer, v.Constraint)
+	}
+
+	return buf.String()
+}
+
+// recorder checks a response against the contract as it is written.
+// A response with a schema is kept until it is complete, so it can
+// be checked and replaced. Any other response, e.g. a stream or a
+// CSV, is checked by its status code and content type and is then
+// written through as it is written
+type recorder struct {
+	w        http.ResponseWriter
+	contract *Contract
+	route    *route
+	mode     Mode
+
+	header      http.Header
+	status      int
+	wroteHeader bool
+	// buffered is set when the body is kept to be checked, and
+	// replaced when a violation was answered instead of the response
+	buffered bool
+	replaced bool
+	media    *mediaType
+	body     bytes.Buffer
+}
+
+func (rec *recorder) Header() http.Header {
+	return rec.header
+}
+
+func (rec *recorder) WriteHeader(status int) {
+	if rec.wroteHeader {
+		return
+	}
+	rec.wroteHeader = true
+	rec.status = status
+
+	media, violations := rec.route.response(status, rec.header.Get("Content-Type"))
+	if len(violations) == 0 && media != nil && media.Schema != nil {
+		rec.buffered = true
+		rec.media = media
+		return
+	}
+
+	if rec.reject(violations) {
+		return
+	}
+
+	rec.writeHeader()
+}
+
+func (rec *recorder) Write(b []byte) (int, error) {
+	rec.WriteHeader(http.StatusOK)
+	switch {
+	case rec.replaced:
+		return len(b), nil
+	case rec.buffered:
+		return rec.body.Write(b)
+	default:
+		return rec.w.Write(b)
+	}
+}
+
+// Flush sends what has been written of a response that is written
+// through
+func (rec *recorder) Flush() {
+	if rec.wroteHeader && !rec.buffered && !rec.replaced {
+		_ = http.NewResponseController(rec.w).Flush()
+	}
+}
+
+// Unwrap lets http.ResponseController reach the response writer,
+// e.g. to read the request while a stream is written
+func (rec *recorder) Unwrap() http.ResponseWriter {
+	return rec.w
+}
+
+// finish checks and sends a kept response once next has returned
+func (rec *recorder) finish() {
+	rec.WriteHeader(http.StatusOK)
+	if !rec.buffered {
+		return
+	}
+
+	// responses always have the dates and times of api.yml
+	if rec.reject(rec.contract.validateJSON(rec.media.Schema, rec.body.Bytes(), receipt.DateFormats{})) {
+		return
+	}
+
+	rec.writeHeader()
+	_, _ = rec.w.Write(rec.body.Bytes())
+}
+
+// reject logs the violations of the response and, in reject mode,
+// answers with a problem instead. It reports whether the response
+// was replaced
+func (rec *recorder) reject(violations []receipt.FieldError) bool {
+	if len(violations) == 0 {
+		return false
+	}
+
+	log.Printf("response %d of %s %s does not conform to the contract: %s", rec.status, rec.route.method, rec.route.template, describe(violations))
+	if rec.mode != ModeReject {
+		return false
+	}
+
+	rec.replaced = true
+	problem.Write(rec.w, http.StatusInternalServerError, "the response does not conform to the API contract", nil)
+
+	return true
+}
+
+func (rec *recorder) writeHeader() {
+	for name, values := range rec.header {
+		rec.w.Header()[name] = values
+	}
+	rec.w.WriteHeader(rec.status)
+}
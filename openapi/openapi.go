@@ -0,0 +1,379 @@
+// Package openapi embeds the API contract in api.yml, and checks
+// requests and responses against it
+package openapi
+
+import (
+	_ "embed"
+	"fmt"
+	"net/http"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/afranco07/receipt-processor/receipt"
+	"gopkg.in/yaml.v3"
+)
+
+//go:embed api.yml
+var spec []byte
+
+// Schema is the part of a schema object the contract is checked
+// against
+type Schema struct {
+	Ref        string             `yaml:"$ref"`
+	Type       string             `yaml:"type"`
+	Format     string             `yaml:"format"`
+	Pattern    string             `yaml:"pattern"`
+	Enum       []string           `yaml:"enum"`
+	Required   []string           `yaml:"required"`
+	Properties map[string]*Schema `yaml:"properties"`
+	Items      *Schema            `yaml:"items"`
+	MinItems   *int               `yaml:"minItems"`
+	Minimum    *float64           `yaml:"minimum"`
+	Maximum    *float64           `yaml:"maximum"`
+	// AnyOf are schemas the value must also conform to at least one
+	// of, e.g. alternative sets of required properties
+	AnyOf []*Schema `yaml:"anyOf"`
+}
+
+type mediaType struct {
+	Schema *Schema `yaml:"schema"`
+}
+
+type requestBody struct {
+	Required bool                 `yaml:"required"`
+	Content  map[string]mediaType `yaml:"content"`
+}
+
+type response struct {
+	Content map[string]mediaType `yaml:"content"`
+}
+
+type parameter struct {
+	Ref      string  `yaml:"$ref"`
+	Name     string  `yaml:"name"`
+	In       string  `yaml:"in"`
+	Required bool    `yaml:"required"`
+	Schema   *Schema `yaml:"schema"`
+}
+
+type operation struct {
+	Parameters  []parameter         `yaml:"parameters"`
+	RequestBody *requestBody        `yaml:"requestBody"`
+	Responses   map[string]response `yaml:"responses"`
+}
+
+type document struct {
+	Paths      map[string]map[string]*operation `yaml:"paths"`
+	Components struct {
+		Schemas    map[string]*Schema   `yaml:"schemas"`
+		Parameters map[string]parameter `yaml:"parameters"`
+	} `yaml:"components"`
+}
+
+// route is an operation along with the segments of its path, where
+// a {name} segment is a path parameter
+type route struct {
+	method    string
+	template  string
+	segments  []string
+	operation *operation
+}
+
+// Contract is the parsed API contract
+type Contract struct {
+	routes   []route
+	schemas  map[string]*Schema
+	patterns sync.Map
+}
+
+// Spec returns the API contract as it is embedded
+func Spec() []byte {
+	return spec
+}
+
+// Load parses the embedded API contract
+func Load() (*Contract, error) {
+	return Parse(spec)
+}
+
+// Parse parses an API contract in the format of api.yml
+func Parse(b []byte) (*Contract, error) {
+	var doc document
+	if err := yaml.Unmarshal(b, &doc); err != nil {
+		return nil, fmt.Errorf("invalid api contract: %w", err)
+	}
+
+	c := &Contract{schemas: doc.Components.Schemas}
+	for template, operations := range doc.Paths {
+		for method, op := range operations {
+			for i, p := range op.Parameters {
+				if p.Ref == "" {
+					continue
+				}
+
+				resolved, ok := doc.Components.Parameters[strings.TrimPrefix(p.Ref, "#/components/parameters/")]
+				if !ok {
+					return nil, fmt.Errorf("invalid api contract: unknown parameter %s of %s %s", p.Ref, method, template)
+				}
+				op.Parameters[i] = resolved
+			}
+
+			c.routes = append(c.routes, route{
+				method:    strings.ToUpper(method),
+				template:  template,
+				segments:  strings.Split(template, "/"),
+				operation: op,
+			})
+		}
+	}
+
+	// a literal segment takes precedence over a path parameter, e.g.
+	// /receipts/export over /receipts/{id}
+	sort.Slice(c.routes, func(i, j int) bool {
+		a, b := c.routes[i], c.routes[j]
+		if pa, pb := a.parameters(), b.parameters(); pa != pb {
+			return pa < pb
+		}
+		if a.template != b.template {
+			return a.template < b.template
+		}
+		return a.method < b.method
+	})
+
+	return c, nil
+}
+
+// parameters returns the number of path parameters of the route
+func (rt route) parameters() int {
+	n := 0
+	for _, s := range rt.segments {
+		if strings.HasPrefix(s, "{") {
+			n++
+		}
+	}
+
+	return n
+}
+
+// Documents reports whether the contract has the operation with the
+// method and path template, e.g. GET /receipts/{id}
+func (c *Contract) Documents(method, template string) bool {
+	for _, rt := range c.routes {
+		if rt.method == method && rt.template == template {
+			return true
+		}
+	}
+
+	return false
+}
+
+// find returns the route of the request along with its path
+// parameters, or nil if the request is not part of the contract
+func (c *Contract) find(method, path string) (*route, map[string]string) {
+	segments := strings.Split(path, "/")
+
+next:
+	for i := range c.routes {
+		rt := &c.routes[i]
+		if rt.method != method || len(rt.segments) != len(segments) {
+			continue
+		}
+
+		params := make(map[string]string)
+		for j, s := range rt.segments {
+			if strings.HasPrefix(s, "{") && strings.HasSuffix(s, "}") {
+				params[strings.Trim(s, "{}")] = segments[j]
+			} else if s != segments[j] {
+				continue next
+			}
+		}
+
+		return rt, params
+	}
+
+	return nil, nil
+}
+
+// resolve follows the reference of a schema to the components
+func (c *Contract) resolve(s *Schema) *Schema {
+	for s != nil && s.Ref != "" {
+		s = c.schemas[strings.TrimPrefix(s.Ref, "#/components/schemas/")]
+	}
+
+	return s
+}
+
+func (c *Contract) pattern(p string) (*regexp.Regexp, error) {
+	if re, ok := c.patterns.Load(p); ok {
+		return re.(*regexp.Regexp), nil
+	}
+
+	re, err := regexp.Compile(p)
+	if err != nil {
+		return nil, err
+	}
+	c.patterns.Store(p, re)
+
+	return re, nil
+}
+
+// Validate returns every value in v, decoded from JSON, that does
+// not conform to the schema. Violations are reported the same way
+// as invalid receipt fields, with a JSON pointer below pointer
+func (c *Contract) Validate(s *Schema, v any, pointer string) []receipt.FieldError {
+	return c.validate(s, v, pointer, receipt.DateFormats{})
+}
+
+// validate is Validate with dates and times in the formats instead
+// of only the formats of api.yml
+func (c *Contract) validate(s *Schema, v any, pointer string, dates receipt.DateFormats) []receipt.FieldError {
+	s = c.resolve(s)
+	if s == nil {
+		return nil
+	}
+
+	violation := func(constraint string) []receipt.FieldError {
+		return []receipt.FieldError{{Pointer: pointer, Constraint: constraint, Value: v}}
+	}
+
+	switch s.Type {
+	case "object":
+		obj, ok := v.(map[string]any)
+		if !ok {
+			return violation("type")
+		}
+
+		var violations []receipt.FieldError
+		for _, name := range s.Required {
+			if _, ok := obj[name]; !ok {
+				violations = append(violations, receipt.FieldError{Pointer: pointer + "/" + escape(name), Constraint: "required"})
+			}
+		}
+
+		// a value that conforms to none of the schemas is reported
+		// with the violations of the first
+		var first []receipt.FieldError
+		for i, alternative := range s.AnyOf {
+			altViolations := c.validate(alternative, v, pointer, dates)
+			if len(altViolations) == 0 {
+				first = nil
+				break
+			}
+			if i == 0 {
+				first = altViolations
+			}
+		}
+		violations = append(violations, first...)
+
+		for name, property := range s.Properties {
+			if value, ok := obj[name]; ok {
+				violations = append(violations, c.validate(property, value, pointer+"/"+escape(name), dates)...)
+			}
+		}
+
+		return violations
+	case "array":
+		arr, ok := v.([]any)
+		if !ok {
+			return violation("type")
+		}
+
+		if s.MinItems != nil && len(arr) < *s.MinItems {
+			return violation("minItems")
+		}
+
+		var violations []receipt.FieldError
+		for i, item := range arr {
+			violations = append(violations, c.validate(s.Items, item, pointer+"/"+strconv.Itoa(i), dates)...)
+		}
+
+		return violations
+	case "string":
+		str, ok := v.(string)
+		if !ok {
+			return violation("type")
+		}
+
+		if s.Pattern != "" {
+			re, err := c.pattern(s.Pattern)
+			if err != nil || !re.MatchString(str) {
+				return violation("pattern")
+			}
+		}
+
+		if len(s.Enum) > 0 && !slices.Contains(s.Enum, str) {
+			return violation("enum")
+		}
+
+		if !validFormat(s.Format, str, dates) {
+			return violation(s.Format)
+		}
+	case "integer":
+		n, ok := v.(float64)
+		if !ok || n != float64(int64(n)) {
+			return violation("type")
+		}
+
+		if s.Minimum != nil && n < *s.Minimum {
+			return violation("minimum")
+		}
+
+		if s.Maximum != nil && n > *s.Maximum {
+			return violation("maximum")
+		}
+	case "number":
+		n, ok := v.(float64)
+		if !ok {
+			return violation("type")
+		}
+
+		if s.Minimum != nil && n < *s.Minimum {
+			return violation("minimum")
+		}
+
+		if s.Maximum != nil && n > *s.Maximum {
+			return violation("maximum")
+		}
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			return violation("type")
+		}
+	}
+
+	return nil
+}
+
+// validFormat reports if the string is in the format. Dates and
+// times are in the formats, which always have the YYYY-MM-DD dates
+// and 24-hour HH:MM times api.yml describes, and date-times are
+// RFC 3339 times
+func validFormat(format, s string, dates receipt.DateFormats) bool {
+	switch format {
+	case "date":
+		return dates.ValidDate(s)
+	case "time":
+		return dates.ValidTime(s)
+	case "date-time":
+		_, err := time.Parse(time.RFC3339Nano, s)
+		return err == nil
+	default:
+		return true
+	}
+}
+
+// escape escapes a property name as a JSON pointer segment
+func escape(name string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(name)
+}
+
+// ServeSpec serves the embedded API contract
+func ServeSpec(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/yaml")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(spec)
+}
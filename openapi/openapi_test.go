@@ -0,0 +1,367 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/afranco07/receipt-processor/receipt"
+)
+
+func loadContract(t *testing.T) *Contract {
+	t.Helper()
+
+	c, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return c
+}
+
+func TestContract_Validate(t *testing.T) {
+	c := loadContract(t)
+	receiptSchema := &Schema{Ref: "#/components/schemas/Receipt"}
+
+	tests := []struct {
+		name string
+		body string
+		want []receipt.FieldError
+	}{
+		{
+			name: "valid receipt",
+			body: `{"retailer": "Target", "purchaseDate": "2022-01-01", "purchaseTime": "13:01", "total": "6.49",
+				"items": [{"shortDescription": "Mountain Dew 12PK", "price": "6.49"}]}`,
+		},
+		{
+			name: "missing field",
+			body: `{"retailer": "Target", "purchaseDate": "2022-01-01", "purchaseTime": "13:01",
+				"items": [{"shortDescription": "Mountain Dew 12PK", "price": "6.49"}]}`,
+			want: []receipt.FieldError{{Pointer: "/total", Constraint: "required"}},
+		},
+		{
+			name: "purchasedAt in place of the purchase date and time",
+			body: `{"retailer": "Target", "purchasedAt": "2022-01-01T13:01:00Z", "total": "6.49",
+				"items": [{"shortDescription": "Mountain Dew 12PK", "price": "6.49"}]}`,
+		},
+		{
+			name: "no purchase date, time or purchasedAt",
+			body: `{"retailer": "Target", "total": "6.49",
+				"items": [{"shortDescription": "Mountain Dew 12PK", "price": "6.49"}]}`,
+			want: []receipt.FieldError{
+				{Pointer: "/purchaseDate", Constraint: "required"},
+				{Pointer: "/purchaseTime", Constraint: "required"},
+			},
+		},
+		{
+			name: "pattern of an item",
+			body: `{"retailer": "Target", "purchaseDate": "2022-01-01", "purchaseTime": "13:01", "total": "6.49",
+				"items": [{"shortDescription": "Mountain Dew 12PK", "price": "1e5"}]}`,
+			want: []receipt.FieldError{{Pointer: "/items/0/price", Constraint: "pattern", Value: "1e5"}},
+		},
+		{
+			name: "no items",
+			body: `{"retailer": "Target", "purchaseDate": "2022-01-01", "purchaseTime": "13:01", "total": "6.49", "items": []}`,
+			want: []receipt.FieldError{{Pointer: "/items", Constraint: "minItems", Value: []any{}}},
+		},
+		{
+			name: "formats",
+			body: `{"retailer": "Target", "purchaseDate": "2022-02-30", "purchaseTime": "1:01", "total": "6.49",
+				"items": [{"shortDescription": "Mountain Dew 12PK", "price": "6.49"}]}`,
+			want: []receipt.FieldError{
+				{Pointer: "/purchaseDate", Constraint: "date", Value: "2022-02-30"},
+				{Pointer: "/purchaseTime", Constraint: "time", Value: "1:01"},
+			},
+		},
+		{
+			name: "type",
+			body: `{"retailer": 5, "purchaseDate": "2022-01-01", "purchaseTime": "13:01", "total": "6.49", "items": {}}`,
+			want: []receipt.FieldError{
+				{Pointer: "/items", Constraint: "type", Value: map[string]any{}},
+				{Pointer: "/retailer", Constraint: "type", Value: float64(5)},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var v any
+			if err := json.Unmarshal([]byte(tt.body), &v); err != nil {
+				t.Fatal(err)
+			}
+
+			got := c.Validate(receiptSchema, v, "")
+			slices.SortFunc(got, func(a, b receipt.FieldError) int { return strings.Compare(a.Pointer, b.Pointer) })
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Validate() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestContract_Middleware(t *testing.T) {
+	c := loadContract(t)
+
+	validReceipt := `{"retailer": "Target", "purchaseDate": "2022-01-01", "purchaseTime": "13:01", "total": "6.49",
+		"items": [{"shortDescription": "Mountain Dew 12PK", "price": "6.49"}]}`
+
+	customDate := `{"retailer": "Target", "purchaseDate": "01/13/2022", "purchaseTime": "1:01 PM", "total": "6.49",
+		"items": [{"shortDescription": "Mountain Dew 12PK", "price": "6.49"}]}`
+	layouts := WithDateFormats(receipt.DateFormats{DateLayouts: []string{"01/02/2006"}, TimeLayouts: []string{"3:04 PM"}})
+
+	// respondWith answers every request the same way
+	respondWith := func(status int, contentType, body string) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", contentType)
+			w.WriteHeader(status)
+			_, _ = w.Write([]byte(body))
+		})
+	}
+	respond := func(status int, body string) http.Handler {
+		return respondWith(status, "application/json", body)
+	}
+
+	tests := []struct {
+		name           string
+		mode           Mode
+		method         string
+		path           string
+		contentType    string
+		body           string
+		opts           []MiddlewareOption
+		next           http.Handler
+		wantStatusCode int
+		wantBody       string
+	}{
+		{
+			name:           "conforming request and response",
+			mode:           ModeReject,
+			method:         http.MethodPost,
+			path:           "/receipts/process",
+			body:           validReceipt,
+			next:           respond(http.StatusCreated, `{"id": "abc"}`),
+			wantStatusCode: http.StatusCreated,
+			wantBody:       `{"id": "abc"}`,
+		},
+		{
+			name:           "undocumented status code is rejected",
+			mode:           ModeReject,
+			method:         http.MethodPost,
+			path:           "/receipts/process",
+			body:           validReceipt,
+			next:           respond(http.StatusOK, `{"id": "abc"}`),
+			wantStatusCode: http.StatusInternalServerError,
+		},
+		{
+			name:           "response body is rejected",
+			mode:           ModeReject,
+			method:         http.MethodGet,
+			path:           "/receipts/abc/points",
+			next:           respond(http.StatusOK, `{"points": "28"}`),
+			wantStatusCode: http.StatusInternalServerError,
+		},
+		{
+			name:           "request is rejected",
+			mode:           ModeReject,
+			method:         http.MethodPost,
+			path:           "/receipts/process",
+			body:           strings.Replace(validReceipt, `"6.49"}]`, `"-3"}]`, 1),
+			next:           respond(http.StatusCreated, `{"id": "abc"}`),
+			wantStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:           "log mode lets the response through",
+			mode:           ModeLog,
+			method:         http.MethodPost,
+			path:           "/receipts/process",
+			body:           strings.Replace(validReceipt, `"6.49"}]`, `"-3"}]`, 1),
+			next:           respond(http.StatusOK, `{"id": "abc"}`),
+			wantStatusCode: http.StatusOK,
+			wantBody:       `{"id": "abc"}`,
+		},
+		{
+			name:           "requests outside the contract are not checked",
+			mode:           ModeReject,
+			method:         http.MethodGet,
+			path:           "/health",
+			next:           respond(http.StatusTeapot, `not json`),
+			wantStatusCode: http.StatusTeapot,
+			wantBody:       `not json`,
+		},
+		{
+			name:           "query parameter is rejected",
+			mode:           ModeReject,
+			method:         http.MethodGet,
+			path:           "/receipts?sort=retailer&limit=10",
+			next:           respond(http.StatusOK, `{"receipts": []}`),
+			wantStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:           "integer query parameter is rejected",
+			mode:           ModeReject,
+			method:         http.MethodGet,
+			path:           "/receipts?limit=ten",
+			next:           respond(http.StatusOK, `{"receipts": []}`),
+			wantStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:           "conforming query parameters",
+			mode:           ModeReject,
+			method:         http.MethodGet,
+			path:           "/receipts?sort=points&order=desc&limit=10&purchaseDateFrom=2022-01-01",
+			next:           respond(http.StatusOK, `{"receipts": []}`),
+			wantStatusCode: http.StatusOK,
+			wantBody:       `{"receipts": []}`,
+		},
+		{
+			name:           "literal path segment takes precedence over a parameter",
+			mode:           ModeReject,
+			method:         http.MethodGet,
+			path:           "/receipts/export",
+			next:           respondWith(http.StatusOK, "text/csv", "receipt,retailer\n"),
+			wantStatusCode: http.StatusOK,
+			wantBody:       "receipt,retailer\n",
+		},
+		{
+			name:           "body without a schema is not read",
+			mode:           ModeReject,
+			method:         http.MethodPost,
+			path:           "/receipts/stream",
+			contentType:    "application/x-ndjson",
+			body:           "not json\n",
+			next:           respondWith(http.StatusOK, "application/x-ndjson", `{"line": 1}`),
+			wantStatusCode: http.StatusOK,
+			wantBody:       `{"line": 1}`,
+		},
+		{
+			name:           "undocumented content type is rejected",
+			mode:           ModeReject,
+			method:         http.MethodPost,
+			path:           "/receipts/stream",
+			contentType:    "text/plain",
+			body:           "not json\n",
+			next:           respondWith(http.StatusOK, "application/x-ndjson", `{"line": 1}`),
+			wantStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:           "date and time in the configured layouts",
+			mode:           ModeReject,
+			method:         http.MethodPost,
+			path:           "/receipts/process",
+			body:           customDate,
+			opts:           []MiddlewareOption{layouts},
+			next:           respond(http.StatusCreated, `{"id": "abc"}`),
+			wantStatusCode: http.StatusCreated,
+		},
+		{
+			name:           "date and time in layouts that are not configured",
+			mode:           ModeReject,
+			method:         http.MethodPost,
+			path:           "/receipts/process",
+			body:           customDate,
+			next:           respond(http.StatusCreated, `{"id": "abc"}`),
+			wantStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:   "date in none of the configured layouts",
+			mode:   ModeReject,
+			method: http.MethodPost,
+			path:   "/receipts/process",
+			body: `{"retailer": "Target", "purchaseDate": "13/01/2022", "purchaseTime": "13:01", "total": "6.49",
+				"items": [{"shortDescription": "Mountain Dew 12PK", "price": "6.49"}]}`,
+			opts:           []MiddlewareOption{layouts},
+			next:           respond(http.StatusCreated, `{"id": "abc"}`),
+			wantStatusCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// the body must still be readable after it is checked
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var buf bytes.Buffer
+				if _, err := buf.ReadFrom(r.Body); err != nil || buf.String() != tt.body {
+					t.Errorf("request body = %q, %v, want %q", buf.String(), err, tt.body)
+				}
+				tt.next.ServeHTTP(w, r)
+			})
+
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			contentType := tt.contentType
+			if contentType == "" {
+				contentType = "application/json"
+			}
+			req.Header.Set("Content-Type", contentType)
+			w := httptest.NewRecorder()
+
+			c.Middleware(tt.mode, next, tt.opts...).ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatusCode {
+				t.Errorf("the response status code did not match. Got %d, want %d", w.Code, tt.wantStatusCode)
+			}
+
+			if tt.wantBody != "" && w.Body.String() != tt.wantBody {
+				t.Errorf("the response body did not match. Got %q, want %q", w.Body.String(), tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestContract_MiddlewareStream(t *testing.T) {
+	c := loadContract(t)
+
+	w := httptest.NewRecorder()
+	next := http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.Header().Set("Content-Type", "application/x-ndjson")
+		rw.WriteHeader(http.StatusOK)
+		_, _ = rw.Write([]byte(`{"line": 1}` + "\n"))
+
+		if err := http.NewResponseController(rw).Flush(); err != nil {
+			t.Fatal(err)
+		}
+
+		// a response without a schema is written through as it is
+		// written, so a stream reaches the client line by line
+		if !w.Flushed || w.Body.String() != `{"line": 1}`+"\n" {
+			t.Errorf("the stream was not written through. Got %q, flushed %v", w.Body.String(), w.Flushed)
+		}
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/receipts/stream", strings.NewReader("{}\n"))
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	c.Middleware(ModeReject, next).ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("the response status code did not match. Got %d, want %d", w.Code, http.StatusOK)
+	}
+}
+
+func TestParseMode(t *testing.T) {
+	for _, s := range []string{"off", "log", "reject"} {
+		if m, err := ParseMode(s); err != nil || string(m) != s {
+			t.Errorf("ParseMode(%q) = %q, %v", s, m, err)
+		}
+	}
+
+	if _, err := ParseMode("warn"); err == nil {
+		t.Error("ParseMode(\"warn\") did not return an error")
+	}
+}
+
+func TestServeSpec(t *testing.T) {
+	w := httptest.NewRecorder()
+	ServeSpec(w, httptest.NewRequest(http.MethodGet, "/openapi.yml", nil))
+
+	if w.Code != http.StatusOK {
+		t.Errorf("the response status code did not match. Got %d, want %d", w.Code, http.StatusOK)
+	}
+
+	if !bytes.Equal(w.Body.Bytes(), Spec()) {
+		t.Error("the response body is not the embedded spec")
+	}
+}
@@ -0,0 +1,36 @@
+// Package problem writes error responses in the format of RFC 7807,
+// so the handlers and the API contract report errors the same way
+package problem
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/afranco07/receipt-processor/receipt"
+)
+
+// ContentType is the media type of a problem
+const ContentType = "application/problem+json"
+
+// Problem is an error response in the format of RFC 7807
+type Problem struct {
+	Type   string               `json:"type"`
+	Title  string               `json:"title"`
+	Status int                  `json:"status"`
+	Detail string               `json:"detail,omitempty"`
+	Errors []receipt.FieldError `json:"errors,omitempty"`
+}
+
+// Write writes a problem with the status code, listing the fields
+// that caused it
+func Write(w http.ResponseWriter, status int, detail string, fields []receipt.FieldError) {
+	w.Header().Set("Content-Type", ContentType)
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(Problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+		Errors: fields,
+	})
+}
@@ -0,0 +1,35 @@
+package receipt
+
+// RuleResult is the outcome of a single scoring rule for a
+// receipt, including the inputs the rule looked at
+type RuleResult struct {
+	Rule        string            `json:"rule"`
+	Description string            `json:"description"`
+	Points      int               `json:"points"`
+	Inputs      map[string]string `json:"inputs,omitempty"`
+}
+
+// Breakdown explains how the points for a receipt were
+// awarded, rule by rule, and which version of the rules
+// awarded them
+type Breakdown struct {
+	Points  int          `json:"points"`
+	Version string       `json:"version"`
+	Rules   []RuleResult `json:"rules"`
+	// Reconciliation is nil for receipts scored before items
+	// were reconciled with the total
+	Reconciliation *Reconciliation `json:"reconciliation,omitempty"`
+	// Exchange is the rate the receipt was converted to the base
+	// currency with, nil for a receipt in the base currency
+	Exchange *Exchange `json:"exchange,omitempty"`
+}
+
+// sumPoints adds up the points awarded by the results
+func sumPoints(results []RuleResult) int {
+	total := 0
+	for _, r := range results {
+		total += r.Points
+	}
+
+	return total
+}
@@ -0,0 +1,349 @@
+package receipt
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ErrInvalidOverride is returned by RuleSet.Override for values
+// that cannot be used to score a receipt
+var ErrInvalidOverride = errors.New("invalid rules override")
+
+const (
+	// DefaultVersion is the version of the rules described in
+	// the README
+	DefaultVersion = "2"
+	// LegacyVersion is the version of the first rules, which scored
+	// with the legacy time window. It is kept so the receipts it
+	// scored can be rescored
+	LegacyVersion = "1"
+	// SimulatedVersion is the version of overridden rules that were
+	// not given one, see RuleSet.Override
+	SimulatedVersion = "simulated"
+)
+
+// Config holds the values used by the default rules. Any value
+// left out of a rules file keeps its default
+type Config struct {
+	Version string `json:"version" yaml:"version"`
+	// Strict refuses to score a receipt with an amount that could not
+	// be parsed, see ScoringError. Otherwise the rules award no points
+	// for it and explain why in their results
+	Strict          bool                  `json:"strict" yaml:"strict"`
+	Retailer        RetailerConfig        `json:"retailer" yaml:"retailer"`
+	Total           TotalConfig           `json:"total" yaml:"total"`
+	ItemPairs       ItemPairsConfig       `json:"itemPairs" yaml:"itemPairs"`
+	ItemDescription ItemDescriptionConfig `json:"itemDescription" yaml:"itemDescription"`
+	PurchaseDay     PurchaseDayConfig     `json:"purchaseDay" yaml:"purchaseDay"`
+	PurchaseTime    PurchaseTimeConfig    `json:"purchaseTime" yaml:"purchaseTime"`
+	Reconciliation  ReconciliationConfig  `json:"reconciliation" yaml:"reconciliation"`
+	Currency        CurrencyConfig        `json:"currency" yaml:"currency"`
+}
+
+// RetailerConfig configures the points awarded for the
+// retailer name
+type RetailerConfig struct {
+	PointsPerCharacter int `json:"pointsPerCharacter" yaml:"pointsPerCharacter"`
+}
+
+// TotalBasis is the amount of a receipt the total rules look at
+type TotalBasis string
+
+const (
+	// TotalBasisTotal is the total paid
+	TotalBasisTotal TotalBasis = "total"
+	// TotalBasisSubtotal is the pre-tax subtotal, see
+	// Receipt.PreTaxSubtotal
+	TotalBasisSubtotal TotalBasis = "subtotal"
+)
+
+// TotalConfig configures the points awarded for the
+// receipt total
+type TotalConfig struct {
+	Basis             TotalBasis `json:"basis" yaml:"basis"`
+	RoundDollarPoints int        `json:"roundDollarPoints" yaml:"roundDollarPoints"`
+	Multiple          float64    `json:"multiple" yaml:"multiple"`
+	MultiplePoints    int        `json:"multiplePoints" yaml:"multiplePoints"`
+}
+
+// ItemCounting is how the item rules count an item with a quantity
+type ItemCounting string
+
+const (
+	// ItemCountingLines counts every item as one, whatever its
+	// quantity
+	ItemCountingLines ItemCounting = "lines"
+	// ItemCountingQuantity counts every unit of an item, as if it
+	// had been sent as that many items
+	ItemCountingQuantity ItemCounting = "quantity"
+)
+
+// ItemPairsConfig configures the points awarded for every
+// group of items on the receipt
+type ItemPairsConfig struct {
+	Counting       ItemCounting `json:"counting" yaml:"counting"`
+	GroupSize      int          `json:"groupSize" yaml:"groupSize"`
+	PointsPerGroup int          `json:"pointsPerGroup" yaml:"pointsPerGroup"`
+}
+
+// ItemDescriptionConfig configures the points awarded for
+// items based on their description length
+type ItemDescriptionConfig struct {
+	Counting        ItemCounting `json:"counting" yaml:"counting"`
+	LengthDivisor   int          `json:"lengthDivisor" yaml:"lengthDivisor"`
+	PriceMultiplier float64      `json:"priceMultiplier" yaml:"priceMultiplier"`
+}
+
+// PurchaseDayConfig configures the points awarded for an
+// odd purchase day
+type PurchaseDayConfig struct {
+	OddDayPoints int `json:"oddDayPoints" yaml:"oddDayPoints"`
+}
+
+// PurchaseTimeConfig configures the points awarded for a
+// purchase made within the time window
+type PurchaseTimeConfig struct {
+	Points int `json:"points" yaml:"points"`
+	// Start and End are the window as 24-hour HH:MM times. A
+	// purchase at exactly the start or end is only in the window
+	// if that bound is inclusive
+	Start          string `json:"start" yaml:"start"`
+	End            string `json:"end" yaml:"end"`
+	StartInclusive bool   `json:"startInclusive" yaml:"startInclusive"`
+	EndInclusive   bool   `json:"endInclusive" yaml:"endInclusive"`
+	// Legacy scores with StartHour and EndHour instead, the way the
+	// first version of the rules did: a purchase is in the window
+	// if its hour is between them, both included, so 14 to 16 runs
+	// from 14:00 to 16:59
+	Legacy    bool `json:"legacy" yaml:"legacy"`
+	StartHour int  `json:"startHour" yaml:"startHour"`
+	EndHour   int  `json:"endHour" yaml:"endHour"`
+}
+
+// ReconciliationConfig configures how the prices of the items
+// are compared with the total, and what happens to a receipt
+// where they do not add up
+type ReconciliationConfig struct {
+	// TaxTolerance is how much the total may be over the sum of
+	// the item prices, as a share of the sum, e.g. 0.1 for 10% tax
+	TaxTolerance float64            `json:"taxTolerance" yaml:"taxTolerance"`
+	Policy       InconsistentPolicy `json:"policy" yaml:"policy"`
+}
+
+// CurrencyConfig configures the currency receipts are scored in.
+// Receipts in other currencies are converted to it before they are
+// scored, with the rate in effect on their purchase date
+type CurrencyConfig struct {
+	Base string `json:"base" yaml:"base"`
+	// Rates is the path of an exchange rate table, see
+	// LoadExchangeRates, relative to the rules file. Without it only
+	// receipts in the base currency can be scored
+	Rates string `json:"rates" yaml:"rates"`
+}
+
+// DefaultConfig returns the values of the rules described
+// in the README
+func DefaultConfig() Config {
+	return Config{
+		Version: DefaultVersion,
+		Retailer: RetailerConfig{
+			PointsPerCharacter: 1,
+		},
+		Total: TotalConfig{
+			Basis:             TotalBasisTotal,
+			RoundDollarPoints: 50,
+			Multiple:          0.25,
+			MultiplePoints:    25,
+		},
+		ItemPairs: ItemPairsConfig{
+			Counting:       ItemCountingLines,
+			GroupSize:      2,
+			PointsPerGroup: 5,
+		},
+		ItemDescription: ItemDescriptionConfig{
+			Counting:        ItemCountingLines,
+			LengthDivisor:   3,
+			PriceMultiplier: 0.2,
+		},
+		PurchaseDay: PurchaseDayConfig{
+			OddDayPoints: 6,
+		},
+		PurchaseTime: PurchaseTimeConfig{
+			Points:    10,
+			Start:     "14:00",
+			End:       "16:00",
+			StartHour: 14,
+			EndHour:   16,
+		},
+		Reconciliation: ReconciliationConfig{
+			TaxTolerance: 0.1,
+			Policy:       PolicyFlag,
+		},
+		Currency: CurrencyConfig{
+			Base: DefaultCurrency,
+		},
+	}
+}
+
+// LegacyConfig returns the values of the first version of the
+// rules, which are the default values with the legacy time window
+func LegacyConfig() Config {
+	cfg := DefaultConfig()
+	cfg.Version = LegacyVersion
+	cfg.PurchaseTime.Legacy = true
+
+	return cfg
+}
+
+// LoadConfig reads a YAML or JSON rules file, based on its
+// extension, on top of the values of the built-in rules with the
+// same version, or the default values, and validates it
+func LoadConfig(path string) (Config, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return Config{}, err
+	}
+
+	var decode func(*Config) error
+	switch filepath.Ext(path) {
+	case ".json":
+		decode = func(cfg *Config) error {
+			dec := json.NewDecoder(bytes.NewReader(b))
+			dec.DisallowUnknownFields()
+			return dec.Decode(cfg)
+		}
+	case ".yml", ".yaml":
+		decode = func(cfg *Config) error {
+			dec := yaml.NewDecoder(bytes.NewReader(b))
+			dec.KnownFields(true)
+			return dec.Decode(cfg)
+		}
+	default:
+		return Config{}, fmt.Errorf("rules file %s must be .json, .yml or .yaml", path)
+	}
+
+	cfg := DefaultConfig()
+	err = decode(&cfg)
+	if err == nil && cfg.Version == LegacyVersion {
+		// decode again so the values left out keep the legacy values
+		cfg = LegacyConfig()
+		err = decode(&cfg)
+	}
+	if err != nil {
+		return Config{}, fmt.Errorf("error parsing rules file %s: %w", path, err)
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return Config{}, fmt.Errorf("invalid rules file %s: %w", path, err)
+	}
+
+	if cfg.Currency.Rates != "" && !filepath.IsAbs(cfg.Currency.Rates) {
+		cfg.Currency.Rates = filepath.Join(filepath.Dir(path), cfg.Currency.Rates)
+	}
+
+	return cfg, nil
+}
+
+// Validate checks that every value can be used to score
+// a receipt
+func (c Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(c.Version != "", "version is required")
+	check(c.Retailer.PointsPerCharacter >= 0, "retailer.pointsPerCharacter must not be negative")
+	check(c.Total.Basis == TotalBasisTotal || c.Total.Basis == TotalBasisSubtotal, "total.basis must be total or subtotal")
+	check(c.Total.RoundDollarPoints >= 0, "total.roundDollarPoints must not be negative")
+	multiple, err := moneyFromFloat(c.Total.Multiple)
+	check(err == nil && multiple.Minor() > 0, "total.multiple must be a positive amount with at most two decimal places")
+	check(c.Total.MultiplePoints >= 0, "total.multiplePoints must not be negative")
+	check(validCounting(c.ItemPairs.Counting), "itemPairs.counting must be lines or quantity")
+	check(c.ItemPairs.GroupSize > 0, "itemPairs.groupSize must be greater than 0")
+	check(c.ItemPairs.PointsPerGroup >= 0, "itemPairs.pointsPerGroup must not be negative")
+	check(validCounting(c.ItemDescription.Counting), "itemDescription.counting must be lines or quantity")
+	check(c.ItemDescription.LengthDivisor > 0, "itemDescription.lengthDivisor must be greater than 0")
+	check(c.ItemDescription.PriceMultiplier >= 0, "itemDescription.priceMultiplier must not be negative")
+	check(c.PurchaseDay.OddDayPoints >= 0, "purchaseDay.oddDayPoints must not be negative")
+	check(c.PurchaseTime.Points >= 0, "purchaseTime.points must not be negative")
+	check(c.PurchaseTime.StartHour >= 0 && c.PurchaseTime.StartHour <= 23, "purchaseTime.startHour must be between 0 and 23")
+	check(c.PurchaseTime.EndHour >= 0 && c.PurchaseTime.EndHour <= 23, "purchaseTime.endHour must be between 0 and 23")
+	check(c.PurchaseTime.StartHour <= c.PurchaseTime.EndHour, "purchaseTime.startHour must not be after purchaseTime.endHour")
+	start, startErr := minuteOfDay(c.PurchaseTime.Start)
+	check(startErr == nil, "purchaseTime.start must be a time like 15:04")
+	end, endErr := minuteOfDay(c.PurchaseTime.End)
+	check(endErr == nil, "purchaseTime.end must be a time like 15:04")
+	check(startErr != nil || endErr != nil || start < end, "purchaseTime.start must be before purchaseTime.end")
+	check(c.Reconciliation.TaxTolerance >= 0, "reconciliation.taxTolerance must not be negative")
+	check(c.Reconciliation.Policy == PolicyReject || c.Reconciliation.Policy == PolicyFlag || c.Reconciliation.Policy == PolicyZero,
+		"reconciliation.policy must be reject, flag or zero")
+	_, ok := MinorUnits(c.Currency.Base)
+	check(ok, "currency.base must be an ISO 4217 currency")
+
+	return errors.Join(errs...)
+}
+
+// validCounting reports whether c is one of the ways to count items
+func validCounting(c ItemCounting) bool {
+	return c == ItemCountingLines || c == ItemCountingQuantity
+}
+
+// NewConfigRuleSet creates a RuleSet with the default rules
+// using the values in the config, loading its exchange rates
+func NewConfigRuleSet(cfg Config) (*RuleSet, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	var rates *ExchangeRates
+	if cfg.Currency.Rates != "" {
+		var err error
+		rates, err = LoadExchangeRates(cfg.Currency.Rates)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return configRuleSet(cfg, rates), nil
+}
+
+// Override returns the rules of rs with the values of a JSON rules
+// file applied on top of its config, e.g. to preview a change to the
+// rules before it is loaded. The rules have the version of the file,
+// or SimulatedVersion without one. The exchange rates of rs are kept,
+// so the currency cannot be overridden. Rules created with
+// NewRuleSet are overridden on top of the default config
+func (rs *RuleSet) Override(b []byte) (*RuleSet, error) {
+	cfg := DefaultConfig()
+	if rs.config != nil {
+		cfg = *rs.config
+	}
+	currency := cfg.Currency
+	// the values are no longer those of the version of rs
+	cfg.Version = SimulatedVersion
+
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&cfg); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidOverride, err)
+	}
+
+	if cfg.Currency != currency {
+		return nil, fmt.Errorf("%w: currency cannot be overridden", ErrInvalidOverride)
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidOverride, err)
+	}
+
+	return configRuleSet(cfg, rs.exchange.rates), nil
+}
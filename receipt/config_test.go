@@ -0,0 +1,324 @@
+package receipt
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeConfig(t *testing.T, name, contents string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(contents), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestLoadConfig(t *testing.T) {
+	custom := DefaultConfig()
+	custom.Total.RoundDollarPoints = 100
+	custom.PurchaseTime.StartHour = 15
+
+	tests := []struct {
+		name     string
+		file     string
+		contents string
+		want     Config
+		wantErr  string
+	}{
+		{
+			name:     "yaml overrides some values",
+			file:     "rules.yml",
+			contents: "total:\n  roundDollarPoints: 100\npurchaseTime:\n  startHour: 15\n",
+			want:     custom,
+		},
+		{
+			name:     "json overrides some values",
+			file:     "rules.json",
+			contents: `{"total": {"roundDollarPoints": 100}, "purchaseTime": {"startHour": 15}}`,
+			want:     custom,
+		},
+		{
+			name:     "empty yaml keeps the defaults",
+			file:     "rules.yaml",
+			contents: "{}",
+			want:     DefaultConfig(),
+		},
+		{
+			name:     "unknown field",
+			file:     "rules.json",
+			contents: `{"total": {"roundDolarPoints": 100}}`,
+			wantErr:  `unknown field "roundDolarPoints"`,
+		},
+		{
+			name:     "invalid values",
+			file:     "rules.yml",
+			contents: "total:\n  multiple: 0\nitemPairs:\n  groupSize: -1\n",
+			wantErr:  "total.multiple must be a positive amount with at most two decimal places\nitemPairs.groupSize must be greater than 0",
+		},
+		{
+			name:     "time window out of order",
+			file:     "rules.yml",
+			contents: "purchaseTime:\n  startHour: 17\n",
+			wantErr:  "purchaseTime.startHour must not be after purchaseTime.endHour",
+		},
+		{
+			name:     "time window that is not a time",
+			file:     "rules.yml",
+			contents: "purchaseTime:\n  start: 2pm\n  end: \"16:0\"\n",
+			wantErr:  "purchaseTime.start must be a time like 15:04\npurchaseTime.end must be a time like 15:04",
+		},
+		{
+			name:     "empty time window",
+			file:     "rules.yml",
+			contents: "purchaseTime:\n  start: \"16:00\"\n",
+			wantErr:  "purchaseTime.start must be before purchaseTime.end",
+		},
+		{
+			name:     "unknown reconciliation policy",
+			file:     "rules.yml",
+			contents: "reconciliation:\n  taxTolerance: -0.1\n  policy: ignore\n",
+			wantErr:  "reconciliation.taxTolerance must not be negative\nreconciliation.policy must be reject, flag or zero",
+		},
+		{
+			name:     "unknown item counting",
+			file:     "rules.yml",
+			contents: "itemDescription:\n  counting: units\n",
+			wantErr:  "itemDescription.counting must be lines or quantity",
+		},
+		{
+			name:     "unknown base currency",
+			file:     "rules.yml",
+			contents: "currency:\n  base: XYZ\n",
+			wantErr:  "currency.base must be an ISO 4217 currency",
+		},
+		{
+			name:     "unsupported extension",
+			file:     "rules.toml",
+			contents: "",
+			wantErr:  "must be .json, .yml or .yaml",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := LoadConfig(writeConfig(t, tt.file, tt.contents))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("LoadConfig() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("LoadConfig() error = %v", err)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("LoadConfig() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadConfig_ExampleMatchesDefaults(t *testing.T) {
+	got, err := LoadConfig("../examples/rules.yml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(got, DefaultConfig()) {
+		t.Errorf("examples/rules.yml = %+v, want %+v", got, DefaultConfig())
+	}
+}
+
+func TestNewConfigRuleSet(t *testing.T) {
+	purchaseDt, _ := time.Parse(time.DateOnly, "2022-03-20")
+	purchaseTm, _ := time.Parse(timeOnly, "14:33")
+
+	r := Receipt{
+		Retailer:     "M&M Corner Market",
+		PurchaseDate: purchaseDate(purchaseDt),
+		PurchaseTime: purchaseTime(purchaseTm),
+		Items: []Item{
+			{ShortDescription: "Gatorade", Price: MustParseMoney("2.25")},
+			{ShortDescription: "Gatorade", Price: MustParseMoney("2.25")},
+			{ShortDescription: "Gatorade", Price: MustParseMoney("2.25")},
+			{ShortDescription: "Gatorade", Price: MustParseMoney("2.25")},
+		},
+		Total: MustParseMoney("9.00"),
+	}
+
+	cfg := DefaultConfig()
+	cfg.Retailer.PointsPerCharacter = 2
+	cfg.Total.Multiple = 0.5
+	cfg.ItemPairs.GroupSize = 4
+	cfg.PurchaseTime.Start = "15:00"
+
+	rs, err := NewConfigRuleSet(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := rs.Score(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 28 retailer + 50 round dollar + 25 multiple of 0.5 + 5 for one group of 4
+	if got.Points != 108 {
+		t.Errorf("Score() = %v, want %v", got.Points, 108)
+	}
+
+	cfg.ItemDescription.LengthDivisor = 0
+	if _, err := NewConfigRuleSet(cfg); err == nil {
+		t.Errorf("NewConfigRuleSet() expected an error for an invalid config")
+	}
+}
+
+func TestRuleSet_Override(t *testing.T) {
+	purchaseDt, _ := time.Parse(time.DateOnly, "2022-03-20")
+	purchaseTm, _ := time.Parse(timeOnly, "14:33")
+
+	r := Receipt{
+		Retailer:     "M&M Corner Market",
+		PurchaseDate: purchaseDate(purchaseDt),
+		PurchaseTime: purchaseTime(purchaseTm),
+		Items:        []Item{{ShortDescription: "Gatorade", Price: MustParseMoney("2.25")}},
+		Total:        MustParseMoney("2.25"),
+	}
+
+	base := DefaultConfig()
+	base.Version = "3"
+	base.Retailer.PointsPerCharacter = 2
+
+	overridden := func(fn func(*Config)) Config {
+		cfg := base
+		fn(&cfg)
+		return cfg
+	}
+
+	tests := []struct {
+		name        string
+		override    string
+		expectedCfg Config
+		expectErr   bool
+	}{
+		{
+			name:     "override keeps the other values",
+			override: `{"total": {"multiplePoints": 40}}`,
+			expectedCfg: overridden(func(c *Config) {
+				c.Version = SimulatedVersion
+				c.Total.MultiplePoints = 40
+			}),
+		},
+		{
+			name:     "override with a version",
+			override: `{"version": "4", "retailer": {"pointsPerCharacter": 5}}`,
+			expectedCfg: overridden(func(c *Config) {
+				c.Version = "4"
+				c.Retailer.PointsPerCharacter = 5
+			}),
+		},
+		{
+			name:      "unknown field",
+			override:  `{"retailer": {"pointsPerLetter": 5}}`,
+			expectErr: true,
+		},
+		{
+			name:      "invalid value",
+			override:  `{"itemDescription": {"lengthDivisor": 0}}`,
+			expectErr: true,
+		},
+		{
+			name:      "currency cannot be overridden",
+			override:  `{"currency": {"base": "CAD"}}`,
+			expectErr: true,
+		},
+		{
+			name:      "not an object",
+			override:  `[]`,
+			expectErr: true,
+		},
+	}
+
+	rs, err := NewConfigRuleSet(base)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	before, err := rs.Score(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			proposed, err := rs.Override([]byte(tt.override))
+			if tt.expectErr {
+				if !errors.Is(err, ErrInvalidOverride) {
+					t.Errorf("Override() error = %v, want %v", err, ErrInvalidOverride)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			expectedRules, err := NewConfigRuleSet(tt.expectedCfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := proposed.Score(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			want, err := expectedRules.Score(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("Score() = %+v, want %+v", got, want)
+			}
+
+			after, err := rs.Score(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(after, before) {
+				t.Errorf("Override() changed the rules it was called on. Got %+v, want %+v", after, before)
+			}
+		})
+	}
+}
+
+func TestRuleSet_OverrideNewRuleSet(t *testing.T) {
+	rs, err := NewRuleSet("custom")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	proposed, err := rs.Override([]byte(`{"version": "custom-2"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := len(proposed.Rules()); got != len(DefaultRuleSet().Rules()) {
+		t.Errorf("Override() has %d rules, want the %d default rules", got, len(DefaultRuleSet().Rules()))
+	}
+
+	if proposed.Version() != "custom-2" {
+		t.Errorf("Version() = %v, want %v", proposed.Version(), "custom-2")
+	}
+}
@@ -0,0 +1,392 @@
+package receipt
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+// schemaProperty is the part of a property in api.yml the receipt
+// validation has to conform to
+type schemaProperty struct {
+	Type     string `yaml:"type"`
+	Pattern  string `yaml:"pattern"`
+	Format   string `yaml:"format"`
+	MinItems *int   `yaml:"minItems"`
+	Minimum  *int   `yaml:"minimum"`
+	Maximum  *int   `yaml:"maximum"`
+	Example  any    `yaml:"example"`
+}
+
+type schema struct {
+	Required []string `yaml:"required"`
+	// AnyOf are alternative sets of required properties
+	AnyOf []struct {
+		Required []string `yaml:"required"`
+	} `yaml:"anyOf"`
+	Properties map[string]schemaProperty `yaml:"properties"`
+}
+
+func loadSchemas(t *testing.T) map[string]schema {
+	t.Helper()
+
+	b, err := os.ReadFile("../openapi/api.yml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var spec struct {
+		Components struct {
+			Schemas map[string]schema `yaml:"schemas"`
+		} `yaml:"components"`
+	}
+	if err := yaml.Unmarshal(b, &spec); err != nil {
+		t.Fatal(err)
+	}
+
+	return spec.Components.Schemas
+}
+
+// conformanceBody returns a receipt that is valid according to the
+// schema, as decoded JSON so single properties can be changed
+func conformanceBody() map[string]any {
+	return map[string]any{
+		"retailer":     "Target",
+		"purchaseDate": "2022-01-01",
+		"purchaseTime": "13:01",
+		"total":        "6.49",
+		"items": []any{
+			map[string]any{"shortDescription": "Mountain Dew 12PK", "price": "6.49"},
+		},
+	}
+}
+
+// schemaTarget is where the properties of a schema are in a receipt
+// body, along with the JSON pointer of that object
+var schemaTargets = map[string]struct {
+	object  func(body map[string]any) map[string]any
+	pointer string
+	model   reflect.Type
+}{
+	"Receipt": {
+		object:  func(body map[string]any) map[string]any { return body },
+		pointer: "",
+		model:   reflect.TypeOf(Receipt{}),
+	},
+	"Item": {
+		object:  func(body map[string]any) map[string]any { return body["items"].([]any)[0].(map[string]any) },
+		pointer: "/items/0",
+		model:   reflect.TypeOf(Item{}),
+	},
+	"Discount": {
+		object: func(body map[string]any) map[string]any {
+			if discounts, ok := body["discounts"].([]any); ok {
+				return discounts[0].(map[string]any)
+			}
+
+			discount := map[string]any{"description": "Store Coupon", "amount": "1.00"}
+			body["discounts"] = []any{discount}
+			return discount
+		},
+		pointer: "/discounts/0",
+		model:   reflect.TypeOf(Discount{}),
+	},
+}
+
+// verdict decodes and validates the body the way a request is,
+// returning the pointers of the fields the schema rejects
+func verdict(t *testing.T, body map[string]any) []string {
+	t.Helper()
+
+	b, err := json.Marshal(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var rcpt Receipt
+	if err := json.Unmarshal(b, &rcpt); err != nil {
+		var decodeErr *DecodeError
+		if !errors.As(err, &decodeErr) {
+			t.Fatalf("decoding %s error = %v, want a %T", b, err, decodeErr)
+		}
+		return []string{decodeErr.Pointer}
+	}
+
+	_, err = rcpt.ValidateReceipt(NewValidator())
+	if err == nil {
+		return nil
+	}
+
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("validating %s error = %v, want a %T", b, err, validationErr)
+	}
+
+	// the lines adding up is not a constraint of the schema, so a
+	// value the schema allows can still be rejected for it
+	var pointers []string
+	for _, f := range validationErr.Fields {
+		if f.Constraint != ConstraintSum {
+			pointers = append(pointers, f.Pointer)
+		}
+	}
+
+	return pointers
+}
+
+// checkVerdict fails the test if the body is not accepted or
+// rejected at the pointer as wanted
+func checkVerdict(t *testing.T, body map[string]any, pointer string, accept bool) {
+	t.Helper()
+
+	rejected := verdict(t, body)
+	if accept && len(rejected) > 0 {
+		t.Errorf("rejected %v, want the receipt accepted", rejected)
+	} else if !accept && !slices.Contains(rejected, pointer) {
+		t.Errorf("rejected %v, want %s rejected", rejected, pointer)
+	}
+}
+
+// patternCorpus are the strings checked against every pattern
+var patternCorpus = []string{
+	"", " ", "Target", "M&M Corner Market", "Mountain Dew 12PK", "Klarbrunn 12-PK 12 FL OZ",
+	"Emils Cheese Pizza", "under_score", "tab\tseparated", "Café", "a/b", "a.b", "a,b", "50% off",
+	"6.49", "0.00", "35.35", "1e5", "-3", "6.4999", "6.4", ".49", "6.", "1,000.00", "+1.00", " 6.49",
+}
+
+// formatCorpus are the strings checked against every format, with
+// whether they are valid
+var formatCorpus = map[string]map[string]bool{
+	"date": {
+		"2022-01-01": true, "2022-03-20": true, "2024-02-29": true,
+		"2022-02-30": false, "2023-02-29": false, "2022-13-01": false, "2022-1-01": false,
+		"01/01/2022": false, "2022-01-01T13:01:00Z": false, "": false,
+	},
+	"time": {
+		"13:01": true, "00:00": true, "23:59": true, "09:05": true,
+		"24:00": false, "13:60": false, "1:01": false, "13:1": false, "1:01pm": false, "13:01:00": false, "": false,
+	},
+	// valid times are the purchase date and time of the body in the
+	// offset of the time
+	"date-time": {
+		"2022-01-01T13:01:00Z": true, "2022-01-01T13:01:00-06:00": true, "2022-01-01T13:01:59.5+05:30": true,
+		"2022-01-01": false, "13:01": false, "2022-01-01 13:01:00Z": false, "2022-01-01T13:01:00": false,
+		"2022-01-01T13:01Z": false, "2022-01-01T13:01:00+0600": false, "": false,
+	},
+}
+
+func TestConformance(t *testing.T) {
+	schemas := loadSchemas(t)
+
+	for name, target := range schemaTargets {
+		s, ok := schemas[name]
+		if !ok {
+			t.Fatalf("api.yml has no %s schema", name)
+		}
+
+		t.Run(name, func(t *testing.T) {
+			t.Run("properties", func(t *testing.T) {
+				var fields []string
+				for i := range target.model.NumField() {
+					if tag, _, _ := strings.Cut(target.model.Field(i).Tag.Get("json"), ","); tag != "" && tag != "-" {
+						fields = append(fields, tag)
+					}
+				}
+
+				for property := range s.Properties {
+					if !slices.Contains(fields, property) {
+						t.Errorf("%s has no field for the %s property", target.model.Name(), property)
+					}
+				}
+				for _, field := range fields {
+					if _, ok := s.Properties[field]; !ok {
+						t.Errorf("api.yml has no %s property for the field of %s", field, target.model.Name())
+					}
+				}
+			})
+
+			for _, property := range s.Required {
+				t.Run("required "+property, func(t *testing.T) {
+					body := conformanceBody()
+					delete(target.object(body), property)
+					checkVerdict(t, body, target.pointer+"/"+property, false)
+				})
+			}
+
+			for i, alternative := range s.AnyOf {
+				// a body with only the properties of the alternative
+				only := func() map[string]any {
+					body := conformanceBody()
+					object := target.object(body)
+					for _, other := range s.AnyOf {
+						for _, property := range other.Required {
+							if !slices.Contains(alternative.Required, property) {
+								delete(object, property)
+							}
+						}
+					}
+					for _, property := range alternative.Required {
+						if _, ok := object[property]; !ok {
+							object[property] = s.Properties[property].Example
+						}
+					}
+					return body
+				}
+
+				t.Run(fmt.Sprintf("any of %d", i), func(t *testing.T) {
+					checkVerdict(t, only(), "", true)
+				})
+
+				for _, property := range alternative.Required {
+					t.Run(fmt.Sprintf("any of %d without %s", i, property), func(t *testing.T) {
+						body := only()
+						delete(target.object(body), property)
+						if rejected := verdict(t, body); len(rejected) == 0 {
+							t.Errorf("accepted the receipt without %s, want it rejected", property)
+						}
+					})
+				}
+			}
+
+			for property, p := range s.Properties {
+				pointer := target.pointer + "/" + property
+
+				t.Run("example "+property, func(t *testing.T) {
+					if p.Example == nil {
+						t.Skip("no example")
+					}
+
+					body := conformanceBody()
+					target.object(body)[property] = p.Example
+					checkVerdict(t, body, pointer, true)
+				})
+
+				t.Run("type "+property, func(t *testing.T) {
+					// a value of another JSON type
+					var value any = "6.49"
+					if p.Type == "string" {
+						value = 6.49
+					}
+
+					body := conformanceBody()
+					target.object(body)[property] = value
+					checkVerdict(t, body, pointer, false)
+				})
+
+				if p.Pattern != "" {
+					t.Run("pattern "+property, func(t *testing.T) {
+						pattern := regexp.MustCompile(p.Pattern)
+						for _, value := range patternCorpus {
+							t.Run(fmt.Sprintf("%q", value), func(t *testing.T) {
+								body := conformanceBody()
+								target.object(body)[property] = value
+								// an amount must also have the decimal places
+								// of the currency of the body, USD
+								valid := pattern.MatchString(value)
+								if p.Pattern == moneyPattern.String() {
+									_, err := ParseAmount(value, 2)
+									valid = valid && err == nil
+								}
+
+								checkVerdict(t, body, pointer, valid)
+							})
+						}
+					})
+				}
+
+				if p.Format != "" {
+					t.Run("format "+property, func(t *testing.T) {
+						corpus, ok := formatCorpus[p.Format]
+						if !ok {
+							t.Fatalf("no corpus for the %s format", p.Format)
+						}
+
+						for value, valid := range corpus {
+							t.Run(fmt.Sprintf("%q", value), func(t *testing.T) {
+								body := conformanceBody()
+								target.object(body)[property] = value
+								checkVerdict(t, body, pointer, valid)
+							})
+						}
+					})
+				}
+
+				if p.Minimum != nil {
+					t.Run("minimum "+property, func(t *testing.T) {
+						for n := *p.Minimum - 1; n <= *p.Minimum+1; n++ {
+							t.Run(fmt.Sprint(n), func(t *testing.T) {
+								body := conformanceBody()
+								target.object(body)[property] = n
+								checkVerdict(t, body, pointer, n >= *p.Minimum)
+							})
+						}
+					})
+				}
+
+				if p.Maximum != nil {
+					t.Run("maximum "+property, func(t *testing.T) {
+						for n := *p.Maximum - 1; n <= *p.Maximum+1; n++ {
+							t.Run(fmt.Sprint(n), func(t *testing.T) {
+								body := conformanceBody()
+								target.object(body)[property] = n
+								checkVerdict(t, body, pointer, n <= *p.Maximum)
+							})
+						}
+					})
+				}
+
+				if p.MinItems != nil {
+					t.Run("minItems "+property, func(t *testing.T) {
+						item := target.object(conformanceBody())[property].([]any)[0]
+
+						for n := range *p.MinItems + 2 {
+							t.Run(fmt.Sprint(n), func(t *testing.T) {
+								body := conformanceBody()
+								target.object(body)[property] = slices.Repeat([]any{item}, n)
+								checkVerdict(t, body, pointer, n >= *p.MinItems)
+							})
+						}
+					})
+				}
+			}
+		})
+	}
+}
+
+// TestConformance_Patterns checks the patterns validated by the Go
+// code are the patterns of api.yml, so the corpus cannot miss a
+// difference between them
+func TestConformance_Patterns(t *testing.T) {
+	schemas := loadSchemas(t)
+
+	for name, target := range schemaTargets {
+		for i := range target.model.NumField() {
+			field := target.model.Field(i)
+			property, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+			want := schemas[name].Properties[property].Pattern
+
+			var got string
+			for _, tag := range strings.Split(field.Tag.Get("validate"), ",") {
+				if param, ok := strings.CutPrefix(tag, "pattern="); ok {
+					got = param
+				} else if tag == "amount" {
+					got = moneyPattern.String()
+				} else if tag == "currency" {
+					got = currencyPattern.String()
+				}
+			}
+
+			if got != want {
+				t.Errorf("%s.%s validates the pattern %q, want %q", name, property, got, want)
+			}
+		}
+	}
+}
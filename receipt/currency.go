@@ -0,0 +1,73 @@
+package receipt
+
+import (
+	"errors"
+	"regexp"
+
+	"github.com/go-playground/validator/v10"
+)
+
+var ErrUnknownCurrency = errors.New("unknown currency")
+
+// DefaultCurrency is the currency of a receipt without one
+const DefaultCurrency = "USD"
+
+// currencyPattern is the format of a currency in api.yml
+var currencyPattern = regexp.MustCompile(`^[A-Z]{3}$`)
+
+// minorUnits are the decimal places of the ISO 4217 currencies.
+// Currencies that are not listed have two
+var minorUnits = map[string]int{
+	"BIF": 0, "CLP": 0, "DJF": 0, "GNF": 0, "ISK": 0, "JPY": 0, "KMF": 0, "KRW": 0, "PYG": 0,
+	"RWF": 0, "UGX": 0, "UYI": 0, "VND": 0, "VUV": 0, "XAF": 0, "XOF": 0, "XPF": 0,
+	"BHD": 3, "IQD": 3, "JOD": 3, "KWD": 3, "LYD": 3, "OMR": 3, "TND": 3,
+	"CLF": 4, "UYW": 4,
+}
+
+// currencies are the ISO 4217 codes with two decimal places
+var currencies = []string{
+	"AED", "AFN", "ALL", "AMD", "ANG", "AOA", "ARS", "AUD", "AWG", "AZN", "BAM", "BBD", "BDT", "BGN",
+	"BMD", "BND", "BOB", "BOV", "BRL", "BSD", "BTN", "BWP", "BYN", "BZD", "CAD", "CDF", "CHE", "CHF",
+	"CHW", "CNY", "COP", "COU", "CRC", "CUP", "CVE", "CZK", "DKK", "DOP", "DZD", "EGP", "ERN", "ETB",
+	"EUR", "FJD", "FKP", "GBP", "GEL", "GHS", "GIP", "GMD", "GTQ", "GYD", "HKD", "HNL", "HTG", "HUF",
+	"IDR", "ILS", "INR", "IRR", "JMD", "KES", "KGS", "KHR", "KPW", "KYD", "KZT", "LAK", "LBP", "LKR",
+	"LRD", "LSL", "MAD", "MDL", "MGA", "MKD", "MMK", "MNT", "MOP", "MRU", "MUR", "MVR", "MWK", "MXN",
+	"MXV", "MYR", "MZN", "NAD", "NGN", "NIO", "NOK", "NPR", "NZD", "PAB", "PEN", "PGK", "PHP", "PKR",
+	"PLN", "QAR", "RON", "RSD", "RUB", "SAR", "SBD", "SCR", "SDG", "SEK", "SGD", "SHP", "SLE", "SOS",
+	"SRD", "SSP", "STN", "SVC", "SYP", "SZL", "THB", "TJS", "TMT", "TOP", "TRY", "TTD", "TWD", "TZS",
+	"UAH", "USD", "USN", "UYU", "UZS", "VED", "VES", "WST", "XCD", "XCG", "YER", "ZAR", "ZMW", "ZWG",
+}
+
+func init() {
+	for _, code := range currencies {
+		minorUnits[code] = 2
+	}
+}
+
+// MinorUnits returns the number of decimal places of an ISO 4217
+// currency, e.g. 2 for USD and 0 for JPY
+func MinorUnits(currency string) (int, bool) {
+	digits, ok := minorUnits[currency]
+	return digits, ok
+}
+
+// CurrencyCode returns the currency of the receipt, DefaultCurrency
+// if it has none
+func (r Receipt) CurrencyCode() string {
+	if r.Currency == "" {
+		return DefaultCurrency
+	}
+
+	return r.Currency
+}
+
+// money returns an amount in the currency of the receipt
+func (r Receipt) money(minor int64) Money {
+	digits, _ := MinorUnits(r.CurrencyCode())
+	return NewMinorMoney(minor, digits)
+}
+
+func validateCurrency(fl validator.FieldLevel) bool {
+	_, ok := MinorUnits(fl.Field().String())
+	return ok && currencyPattern.MatchString(fl.Field().String())
+}
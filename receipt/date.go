@@ -2,22 +2,31 @@ package receipt
 
 import (
 	"encoding/json"
+	"fmt"
 	"time"
 )
 
-const timeOnly = "15:04"
+const (
+	timeOnly    = "15:04"
+	kitchenTime = "3:04pm"
+)
 
 // purchaseDate is custom type used to parse the receipt
 // purchase date
 type purchaseDate time.Time
 
 func (d *purchaseDate) UnmarshalJSON(b []byte) error {
+	return d.decode(b, DateFormats{})
+}
+
+// decode parses a purchase date in the formats
+func (d *purchaseDate) decode(b []byte, f DateFormats) error {
 	var s string
 	if err := json.Unmarshal(b, &s); err != nil {
 		return err
 	}
 
-	t, err := time.Parse(time.DateOnly, s)
+	t, err := f.parseDate(s)
 	if err != nil {
 		return err
 	}
@@ -27,13 +36,26 @@ func (d *purchaseDate) UnmarshalJSON(b []byte) error {
 	return nil
 }
 
-func (d *purchaseDate) scoreDay() int {
+func (d purchaseDate) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Time(d).Format(time.DateOnly))
+}
+
+func (d purchaseDate) scoreDay(cfg PurchaseDayConfig) RuleResult {
+	result := RuleResult{
+		Rule:        RulePurchaseDayOdd,
+		Description: "purchase day is even",
+		Inputs:      map[string]string{"purchaseDate": time.Time(d).Format(time.DateOnly)},
+	}
+
 	// day is even
-	if time.Time(*d).Day()%2 == 0 {
-		return 0
+	if time.Time(d).Day()%2 == 0 {
+		return result
 	}
 
-	return 6
+	result.Description = "purchase day is odd"
+	result.Points = cfg.OddDayPoints
+
+	return result
 }
 
 // purchaseTime is custom type used to parse the receipt
@@ -41,12 +63,17 @@ func (d *purchaseDate) scoreDay() int {
 type purchaseTime time.Time
 
 func (pt *purchaseTime) UnmarshalJSON(b []byte) error {
+	return pt.decode(b, DateFormats{})
+}
+
+// decode parses a purchase time in the formats
+func (pt *purchaseTime) decode(b []byte, f DateFormats) error {
 	var s string
 	if err := json.Unmarshal(b, &s); err != nil {
 		return err
 	}
 
-	t, err := time.Parse(timeOnly, s)
+	t, err := f.parseTime(s)
 	if err != nil {
 		return err
 	}
@@ -56,13 +83,84 @@ func (pt *purchaseTime) UnmarshalJSON(b []byte) error {
 	return nil
 }
 
-func (pt *purchaseTime) scoreTime() int {
-	hour := time.Time(*pt).Hour()
+func (pt purchaseTime) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Time(pt).Format(timeOnly))
+}
+
+// scoreTime awards points for a purchase within the time window,
+// 2:00pm to 4:00pm by default. See PurchaseTimeConfig for which
+// bounds are in it
+func (pt purchaseTime) scoreTime(cfg PurchaseTimeConfig) RuleResult {
+	if cfg.Legacy {
+		return pt.scoreLegacyTime(cfg)
+	}
+
+	t := time.Time(pt)
+	minute := t.Hour()*60 + t.Minute()
+	start, _ := minuteOfDay(cfg.Start)
+	end, _ := minuteOfDay(cfg.End)
+
+	after, afterStart := "after", minute > start
+	if cfg.StartInclusive {
+		after, afterStart = "at or after", minute >= start
+	}
+
+	before, beforeEnd := "before", minute < end
+	if cfg.EndInclusive {
+		before, beforeEnd = "at or before", minute <= end
+	}
 
-	// the time is between 2PM and 4PM
-	if hour >= 14 && hour <= 16 {
-		return 10
+	window := fmt.Sprintf("%s %s and %s %s", after, kitchenMinute(start), before, kitchenMinute(end))
+	result := RuleResult{
+		Rule:        RulePurchaseTime,
+		Description: fmt.Sprintf("%s is not %s", t.Format(kitchenTime), window),
+		Inputs:      map[string]string{"purchaseTime": t.Format(timeOnly)},
 	}
 
-	return 0
+	if afterStart && beforeEnd {
+		result.Description = fmt.Sprintf("%s is %s", t.Format(kitchenTime), window)
+		result.Points = cfg.Points
+	}
+
+	return result
+}
+
+// scoreLegacyTime awards points for a purchase with an hour between
+// the start and end hours, both included
+func (pt purchaseTime) scoreLegacyTime(cfg PurchaseTimeConfig) RuleResult {
+	t := time.Time(pt)
+	hour := t.Hour()
+
+	start := time.Date(0, 1, 1, cfg.StartHour, 0, 0, 0, time.UTC).Format(kitchenTime)
+	end := time.Date(0, 1, 1, cfg.EndHour, 0, 0, 0, time.UTC).Format(kitchenTime)
+
+	result := RuleResult{
+		Rule:        RulePurchaseTime,
+		Description: fmt.Sprintf("%s is not between %s and %s", t.Format(kitchenTime), start, end),
+		Inputs:      map[string]string{"purchaseTime": t.Format(timeOnly)},
+	}
+
+	// the hour is within the window, 2PM to 4:59PM by default
+	if hour >= cfg.StartHour && hour <= cfg.EndHour {
+		result.Description = fmt.Sprintf("%s is between %s and %s", t.Format(kitchenTime), start, end)
+		result.Points = cfg.Points
+	}
+
+	return result
+}
+
+// minuteOfDay parses a time in the format of api.yml and returns
+// the minutes since midnight
+func minuteOfDay(s string) (int, error) {
+	t, err := DateFormats{}.parseTime(s)
+	if err != nil {
+		return 0, err
+	}
+
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// kitchenMinute formats minutes since midnight as a kitchen time
+func kitchenMinute(minute int) string {
+	return time.Date(0, 1, 1, 0, minute, 0, 0, time.UTC).Format(kitchenTime)
 }
@@ -69,8 +69,8 @@ func Test_purchaseDate_scoreDay(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if got := tt.d.scoreDay(); got != tt.want {
-				t.Errorf("scoreDay() = %v, want %v", got, tt.want)
+			if got := tt.d.scoreDay(DefaultConfig().PurchaseDay); got.Points != tt.want {
+				t.Errorf("scoreDay() = %v, want %v", got.Points, tt.want)
 			}
 		})
 	}
@@ -105,6 +105,15 @@ func Test_purchaseTime_UnmarshalJSON(t *testing.T) {
 			wantErr:  false,
 			wantTime: time.Date(0, 1, 1, 14, 33, 0, 0, time.UTC),
 		},
+		{
+			name: "single digit hour is rejected",
+			pt:   purchaseTime{},
+			args: args{
+				b: []byte(`"1:01"`),
+			},
+			wantErr:  true,
+			wantTime: time.Time{},
+		},
 	}
 
 	for _, tt := range tests {
@@ -139,8 +148,226 @@ func Test_purchaseTime_scoreTime(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if got := tt.pt.scoreTime(); got != tt.want {
-				t.Errorf("scoreTime() = %v, want %v", got, tt.want)
+			if got := tt.pt.scoreTime(DefaultConfig().PurchaseTime); got.Points != tt.want {
+				t.Errorf("scoreTime() = %v, want %v", got.Points, tt.want)
+			}
+		})
+	}
+}
+
+func Test_purchaseTime_scoreTimeBounds(t *testing.T) {
+	window := func(startInclusive, endInclusive bool) PurchaseTimeConfig {
+		cfg := DefaultConfig().PurchaseTime
+		cfg.Legacy, cfg.StartInclusive, cfg.EndInclusive = false, startInclusive, endInclusive
+		return cfg
+	}
+	legacy := LegacyConfig().PurchaseTime
+
+	type want struct{ exclusive, startInclusive, endInclusive, inclusive, legacy int }
+	tests := []struct {
+		time string
+		want want
+	}{
+		{time: "00:00", want: want{0, 0, 0, 0, 0}},
+		{time: "13:00", want: want{0, 0, 0, 0, 0}},
+		{time: "13:59", want: want{0, 0, 0, 0, 0}},
+		{time: "14:00", want: want{0, 10, 0, 10, 10}},
+		{time: "14:01", want: want{10, 10, 10, 10, 10}},
+		{time: "14:33", want: want{10, 10, 10, 10, 10}},
+		{time: "15:00", want: want{10, 10, 10, 10, 10}},
+		{time: "15:59", want: want{10, 10, 10, 10, 10}},
+		{time: "16:00", want: want{0, 0, 10, 10, 10}},
+		{time: "16:01", want: want{0, 0, 0, 0, 10}},
+		{time: "16:59", want: want{0, 0, 0, 0, 10}},
+		{time: "17:00", want: want{0, 0, 0, 0, 0}},
+		{time: "23:59", want: want{0, 0, 0, 0, 0}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.time, func(t *testing.T) {
+			var pt purchaseTime
+			if err := pt.UnmarshalJSON([]byte(`"` + tt.time + `"`)); err != nil {
+				t.Fatal(err)
+			}
+
+			configs := []struct {
+				name string
+				cfg  PurchaseTimeConfig
+				want int
+			}{
+				{name: "exclusive", cfg: window(false, false), want: tt.want.exclusive},
+				{name: "start inclusive", cfg: window(true, false), want: tt.want.startInclusive},
+				{name: "end inclusive", cfg: window(false, true), want: tt.want.endInclusive},
+				{name: "inclusive", cfg: window(true, true), want: tt.want.inclusive},
+				{name: "legacy", cfg: legacy, want: tt.want.legacy},
+			}
+			for _, c := range configs {
+				if got := pt.scoreTime(c.cfg); got.Points != c.want {
+					t.Errorf("%s scoreTime() = %v, want %v: %s", c.name, got.Points, c.want, got.Description)
+				}
+			}
+		})
+	}
+}
+
+func Test_purchaseTime_scoreTimeEveryMinute(t *testing.T) {
+	tests := []struct {
+		name   string
+		cfg    PurchaseTimeConfig
+		inside func(minute int) bool
+	}{
+		{
+			name:   "exclusive",
+			cfg:    PurchaseTimeConfig{Points: 1, Start: "09:30", End: "17:15"},
+			inside: func(m int) bool { return m > 9*60+30 && m < 17*60+15 },
+		},
+		{
+			name:   "start inclusive",
+			cfg:    PurchaseTimeConfig{Points: 1, Start: "09:30", End: "17:15", StartInclusive: true},
+			inside: func(m int) bool { return m >= 9*60+30 && m < 17*60+15 },
+		},
+		{
+			name:   "end inclusive",
+			cfg:    PurchaseTimeConfig{Points: 1, Start: "09:30", End: "17:15", EndInclusive: true},
+			inside: func(m int) bool { return m > 9*60+30 && m <= 17*60+15 },
+		},
+		{
+			name:   "whole day",
+			cfg:    PurchaseTimeConfig{Points: 1, Start: "00:00", End: "23:59", StartInclusive: true, EndInclusive: true},
+			inside: func(m int) bool { return true },
+		},
+		{
+			name:   "legacy",
+			cfg:    PurchaseTimeConfig{Points: 1, Legacy: true, StartHour: 9, EndHour: 17},
+			inside: func(m int) bool { return m >= 9*60 && m < 18*60 },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for m := range 24 * 60 {
+				want := 0
+				if tt.inside(m) {
+					want = 1
+				}
+
+				pt := purchaseTime(time.Date(0, 1, 1, m/60, m%60, 0, 0, time.UTC))
+				if got := pt.scoreTime(tt.cfg); got.Points != want {
+					t.Errorf("scoreTime() at %s = %v, want %v: %s", time.Time(pt).Format(timeOnly), got.Points, want, got.Description)
+				}
+			}
+		})
+	}
+}
+
+func Test_purchaseTime_scoreTimeDescription(t *testing.T) {
+	cfg := DefaultConfig().PurchaseTime
+	cfg.Legacy, cfg.EndInclusive = false, true
+
+	tests := []struct {
+		time string
+		want string
+	}{
+		{time: "14:00", want: "2:00pm is not after 2:00pm and at or before 4:00pm"},
+		{time: "16:00", want: "4:00pm is after 2:00pm and at or before 4:00pm"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.time, func(t *testing.T) {
+			var pt purchaseTime
+			if err := pt.UnmarshalJSON([]byte(`"` + tt.time + `"`)); err != nil {
+				t.Fatal(err)
+			}
+
+			if got := pt.scoreTime(cfg).Description; got != tt.want {
+				t.Errorf("scoreTime() description = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_purchaseDate_MarshalJSON(t *testing.T) {
+	tests := []struct {
+		name string
+		d    purchaseDate
+		want string
+	}{
+		{
+			name: "test example #1 round trips",
+			d:    purchaseDate(time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)),
+			want: `"2022-01-01"`,
+		},
+		{
+			name: "test example #2 round trips",
+			d:    purchaseDate(time.Date(2022, 3, 20, 0, 0, 0, 0, time.UTC)),
+			want: `"2022-03-20"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.d.MarshalJSON()
+			if err != nil {
+				t.Fatalf("MarshalJSON() error = %v", err)
+			}
+
+			if string(got) != tt.want {
+				t.Errorf("MarshalJSON() = %s, want %s", got, tt.want)
+			}
+
+			var parsed purchaseDate
+			if err := parsed.UnmarshalJSON(got); err != nil {
+				t.Fatalf("UnmarshalJSON() error = %v", err)
+			}
+
+			if !time.Time(parsed).Equal(time.Time(tt.d)) {
+				t.Errorf("round trip did not match, want = %v, got = %v", time.Time(tt.d), time.Time(parsed))
+			}
+		})
+	}
+}
+
+func Test_purchaseTime_MarshalJSON(t *testing.T) {
+	tests := []struct {
+		name string
+		pt   purchaseTime
+		want string
+	}{
+		{
+			name: "test example #1 round trips",
+			pt:   purchaseTime(time.Date(0, 1, 1, 13, 1, 0, 0, time.UTC)),
+			want: `"13:01"`,
+		},
+		{
+			name: "test example #2 round trips",
+			pt:   purchaseTime(time.Date(0, 1, 1, 14, 33, 0, 0, time.UTC)),
+			want: `"14:33"`,
+		},
+		{
+			name: "midnight keeps leading zeros",
+			pt:   purchaseTime(time.Date(0, 1, 1, 0, 5, 0, 0, time.UTC)),
+			want: `"00:05"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.pt.MarshalJSON()
+			if err != nil {
+				t.Fatalf("MarshalJSON() error = %v", err)
+			}
+
+			if string(got) != tt.want {
+				t.Errorf("MarshalJSON() = %s, want %s", got, tt.want)
+			}
+
+			var parsed purchaseTime
+			if err := parsed.UnmarshalJSON(got); err != nil {
+				t.Fatalf("UnmarshalJSON() error = %v", err)
+			}
+
+			if !time.Time(parsed).Equal(time.Time(tt.pt)) {
+				t.Errorf("round trip did not match, want = %v, got = %v", time.Time(tt.pt), time.Time(parsed))
 			}
 		})
 	}
@@ -0,0 +1,178 @@
+package receipt
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var ErrAmbiguousDate = errors.New("ambiguous date")
+
+// DateMode is how strictly a purchase date or time must match a
+// layout of DateFormats
+type DateMode string
+
+const (
+	// DateModeStrict accepts a value that is exactly in a layout,
+	// and rejects a value that is a different date or time in two
+	// layouts, such as 01/02/2022 in 01/02/2006 and 02/01/2006
+	DateModeStrict DateMode = "strict"
+	// DateModeLenient accepts a value with spaces around it or that
+	// Go can parse with a layout, such as 1:01 in 15:04, and parses
+	// an ambiguous value with the first layout it is in
+	DateModeLenient DateMode = "lenient"
+)
+
+// ParseDateMode parses the name of a mode
+func ParseDateMode(s string) (DateMode, error) {
+	switch m := DateMode(s); m {
+	case DateModeStrict, DateModeLenient:
+		return m, nil
+	}
+
+	return "", fmt.Errorf("invalid date mode %q, want strict or lenient", s)
+}
+
+// DateFormats are the Go layouts the purchase date and time of a
+// receipt are accepted in, tried in order after the formats of
+// api.yml. The zero value is strict and only accepts the formats of
+// api.yml. Dates and times are always kept, and written, in the
+// formats of api.yml
+type DateFormats struct {
+	// Mode is DateModeStrict if it is empty
+	Mode        DateMode
+	DateLayouts []string
+	TimeLayouts []string
+}
+
+// layoutReference is formatted with a layout and parsed back to
+// check the layout has every part of a date or time
+var layoutReference = time.Date(2009, 11, 23, 17, 45, 0, 0, time.UTC)
+
+// Validate checks the mode is known, every date layout has a year,
+// month and day, and every time layout has an hour and minute
+func (f DateFormats) Validate() error {
+	var errs []error
+	if f.Mode != "" {
+		if _, err := ParseDateMode(string(f.Mode)); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	for _, layout := range f.DateLayouts {
+		t, err := time.Parse(layout, layoutReference.Format(layout))
+		if err != nil || t.Format(time.DateOnly) != layoutReference.Format(time.DateOnly) {
+			errs = append(errs, fmt.Errorf("date layout %q must have a year, month and day", layout))
+		}
+	}
+
+	for _, layout := range f.TimeLayouts {
+		t, err := time.Parse(layout, layoutReference.Format(layout))
+		if err != nil || t.Format(timeOnly) != layoutReference.Format(timeOnly) {
+			errs = append(errs, fmt.Errorf("time layout %q must have an hour and minute", layout))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Unmarshal decodes a JSON receipt the way json.Unmarshal does, with
+// its purchase date and time parsed in the formats
+func (f DateFormats) Unmarshal(b []byte, r *Receipt) error {
+	return r.decode(b, f)
+}
+
+// ValidDate reports if a purchase date is in the formats
+func (f DateFormats) ValidDate(s string) bool {
+	_, err := f.parseDate(s)
+	return err == nil
+}
+
+// ValidTime reports if a purchase time is in the formats
+func (f DateFormats) ValidTime(s string) bool {
+	_, err := f.parseTime(s)
+	return err == nil
+}
+
+// parseDate parses a purchase date, in the formats of api.yml or
+// one of the date layouts
+func (f DateFormats) parseDate(s string) (time.Time, error) {
+	t, err := f.parse(s, append([]string{time.DateOnly}, f.DateLayouts...), time.DateOnly)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC), nil
+}
+
+// parseTime parses a purchase time, in the formats of api.yml or
+// one of the time layouts
+func (f DateFormats) parseTime(s string) (time.Time, error) {
+	t, err := f.parse(s, append([]string{timeOnly}, f.TimeLayouts...), timeOnly)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return time.Date(0, 1, 1, t.Hour(), t.Minute(), 0, 0, time.UTC), nil
+}
+
+// parse parses the value with the layouts in order. In strict mode
+// every layout is tried, and the value is ambiguous if two of them
+// give a different canonical value. The error of the first layout is
+// returned if the value is in none of them
+func (f DateFormats) parse(s string, layouts []string, canonical string) (time.Time, error) {
+	lenient := f.Mode == DateModeLenient
+	if lenient {
+		s = strings.TrimSpace(s)
+	}
+
+	var found time.Time
+	var foundLayout string
+	var firstErr error
+	for _, layout := range layouts {
+		t, err := time.Parse(layout, s)
+		// a layout also accepts values Go can parse, such as a
+		// single digit hour in 15:04
+		if err == nil && !lenient && t.Format(layout) != s {
+			err = &time.ParseError{Layout: layout, Value: s, LayoutElem: layout, ValueElem: s, Message: fmt.Sprintf(": not exactly in the layout %q", layout)}
+		}
+
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+
+		if foundLayout == "" {
+			found, foundLayout = t, layout
+			if lenient {
+				break
+			}
+			continue
+		}
+
+		if t.Format(canonical) != found.Format(canonical) {
+			return time.Time{}, fmt.Errorf("%w: %q is %s in the layout %q and %s in the layout %q",
+				ErrAmbiguousDate, s, found.Format(canonical), foundLayout, t.Format(canonical), layout)
+		}
+	}
+
+	if foundLayout == "" {
+		return time.Time{}, firstErr
+	}
+
+	return found, nil
+}
+
+// inFormats decodes the purchase date or time of a receipt in the
+// formats the receipt is decoded with
+type inFormats struct {
+	decode  func(b []byte, f DateFormats) error
+	formats DateFormats
+}
+
+func (d *inFormats) UnmarshalJSON(b []byte) error {
+	return d.decode(b, d.formats)
+}
@@ -0,0 +1,160 @@
+package receipt
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestDateFormats_Unmarshal(t *testing.T) {
+	usAndEurope := []string{"01/02/2006", "02/01/2006"}
+
+	tests := []struct {
+		name        string
+		formats     DateFormats
+		date        string
+		time        string
+		wantDate    string
+		wantTime    string
+		wantPointer string
+		wantErr     error
+	}{
+		{name: "formats of api.yml", date: "2022-01-02", time: "13:01", wantDate: "2022-01-02", wantTime: "13:01"},
+		{name: "no other layouts by default", date: "01/02/2022", time: "13:01", wantPointer: "/purchaseDate"},
+		{name: "single digit hour by default", date: "2022-01-02", time: "1:01", wantPointer: "/purchaseTime"},
+		{
+			name:     "date and time layouts",
+			formats:  DateFormats{DateLayouts: []string{"01/02/2006"}, TimeLayouts: []string{"3:04 PM"}},
+			date:     "01/02/2022",
+			time:     "1:01 PM",
+			wantDate: "2022-01-02",
+			wantTime: "13:01",
+		},
+		{
+			name:     "date and time of a timestamp",
+			formats:  DateFormats{DateLayouts: []string{"2006-01-02T15:04:05"}, TimeLayouts: []string{"2006-01-02T15:04:05"}},
+			date:     "2022-01-02T13:01:00",
+			time:     "2022-01-02T13:01:00",
+			wantDate: "2022-01-02",
+			wantTime: "13:01",
+		},
+		{
+			name:        "ambiguous in strict mode",
+			formats:     DateFormats{DateLayouts: usAndEurope},
+			date:        "01/02/2022",
+			time:        "13:01",
+			wantPointer: "/purchaseDate",
+			wantErr:     ErrAmbiguousDate,
+		},
+		{
+			name:     "the same date in both layouts is not ambiguous",
+			formats:  DateFormats{DateLayouts: usAndEurope},
+			date:     "01/01/2022",
+			time:     "13:01",
+			wantDate: "2022-01-01",
+			wantTime: "13:01",
+		},
+		{
+			name:     "only in one layout is not ambiguous",
+			formats:  DateFormats{DateLayouts: usAndEurope},
+			date:     "25/12/2022",
+			time:     "13:01",
+			wantDate: "2022-12-25",
+			wantTime: "13:01",
+		},
+		{
+			name:     "first layout in lenient mode",
+			formats:  DateFormats{Mode: DateModeLenient, DateLayouts: usAndEurope},
+			date:     "01/02/2022",
+			time:     "13:01",
+			wantDate: "2022-01-02",
+			wantTime: "13:01",
+		},
+		{
+			name:        "not exactly in a layout in strict mode",
+			formats:     DateFormats{Mode: DateModeStrict, TimeLayouts: []string{"3:04 PM"}},
+			date:        "2022-01-02",
+			time:        " 1:01 PM",
+			wantPointer: "/purchaseTime",
+		},
+		{
+			name:     "spaces and single digit hour in lenient mode",
+			formats:  DateFormats{Mode: DateModeLenient, TimeLayouts: []string{"3:04 PM"}},
+			date:     " 2022-01-02",
+			time:     "1:01",
+			wantDate: "2022-01-02",
+			wantTime: "01:01",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := `{"retailer": "Target", "purchaseDate": "` + tt.date + `", "purchaseTime": "` + tt.time + `"}`
+
+			var r Receipt
+			err := tt.formats.Unmarshal([]byte(body), &r)
+			if tt.wantPointer != "" {
+				var decodeErr *DecodeError
+				if !errors.As(err, &decodeErr) {
+					t.Fatalf("Unmarshal() error = %v, want a %T", err, decodeErr)
+				}
+				if decodeErr.Pointer != tt.wantPointer {
+					t.Errorf("Unmarshal() pointer = %s, want %s", decodeErr.Pointer, tt.wantPointer)
+				}
+				if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+					t.Errorf("Unmarshal() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Unmarshal() error = %v", err)
+			}
+
+			// dates and times are written in the formats of api.yml
+			b, err := json.Marshal(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var got struct {
+				PurchaseDate string `json:"purchaseDate"`
+				PurchaseTime string `json:"purchaseTime"`
+			}
+			if err := json.Unmarshal(b, &got); err != nil {
+				t.Fatal(err)
+			}
+
+			if got.PurchaseDate != tt.wantDate || got.PurchaseTime != tt.wantTime {
+				t.Errorf("Unmarshal() = %s %s, want %s %s", got.PurchaseDate, got.PurchaseTime, tt.wantDate, tt.wantTime)
+			}
+		})
+	}
+}
+
+func TestDateFormats_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		formats DateFormats
+		wantErr bool
+	}{
+		{name: "zero value", formats: DateFormats{}},
+		{
+			name:    "layouts",
+			formats: DateFormats{Mode: DateModeLenient, DateLayouts: []string{"01/02/2006", "Jan 2, 2006"}, TimeLayouts: []string{"3:04 PM", time.RFC3339}},
+		},
+		{name: "unknown mode", formats: DateFormats{Mode: "loose"}, wantErr: true},
+		{name: "date layout without a day", formats: DateFormats{DateLayouts: []string{"01/2006"}}, wantErr: true},
+		{name: "time layout without minutes", formats: DateFormats{TimeLayouts: []string{"3 PM"}}, wantErr: true},
+		{name: "date layout that is a time", formats: DateFormats{DateLayouts: []string{"15:04"}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.formats.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,43 @@
+package receipt
+
+import (
+	"encoding/json"
+)
+
+// Discount is a discount or coupon taken off the items of a receipt
+type Discount struct {
+	Description string `json:"description" validate:"required,pattern=^[\\w\\s\\-]+$"`
+	Amount      Money  `json:"amount" validate:"required,amount"`
+}
+
+// UnmarshalJSON decodes every field on its own, see
+// Receipt.UnmarshalJSON. The amounts are in DefaultCurrency
+func (d *Discount) UnmarshalJSON(b []byte) error {
+	digits, _ := MinorUnits(DefaultCurrency)
+	return d.decode(b, digits)
+}
+
+// decode decodes the fields with amounts that have digits decimal
+// places
+func (d *Discount) decode(b []byte, digits int) error {
+	var fields struct {
+		Description json.RawMessage `json:"description"`
+		Amount      json.RawMessage `json:"amount"`
+	}
+	if err := decodeField(b, "", ConstraintType, &fields); err != nil {
+		return err
+	}
+
+	var discount Discount
+	if err := decodeField(fields.Description, "/description", ConstraintType, &discount.Description); err != nil {
+		return err
+	}
+
+	if err := decodeAmount(fields.Amount, "/amount", digits, &discount.Amount); err != nil {
+		return err
+	}
+
+	*d = discount
+
+	return nil
+}
@@ -0,0 +1,204 @@
+package receipt
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// Constraints reported for values that could not be decoded. Values
+// that fail validation report the validator tag instead
+const (
+	ConstraintType     = "type"
+	ConstraintDate     = "date"
+	ConstraintTime     = "time"
+	ConstraintDateTime = "date-time"
+	ConstraintAmount   = "amount"
+	ConstraintCurrency = "currency"
+)
+
+// ConstraintReconciliation is reported for the total of a receipt
+// rejected because its items do not add up to it
+const ConstraintReconciliation = "reconciliation"
+
+// ConstraintLocalTime is reported for a purchase time that is not a
+// time of day in the time zone of the store, such as a time skipped
+// when the clocks go forward, or for a purchasedAt that is not the
+// purchase date and time
+const ConstraintLocalTime = "localTime"
+
+// ConstraintExchangeRate is reported for the currency of a receipt
+// that has no exchange rate to the base currency of the rules
+const ConstraintExchangeRate = "exchangeRate"
+
+// FieldError is an invalid field of a receipt
+type FieldError struct {
+	// Pointer is the JSON pointer to the field, e.g. /items/2/price
+	Pointer string `json:"pointer"`
+	// Constraint is the rule the value failed, e.g. required
+	Constraint string `json:"constraint"`
+	// Value is the offending value, null if it is missing
+	Value any `json:"value"`
+}
+
+// ValidationError is returned by ValidateReceipt with every field
+// that failed validation
+type ValidationError struct {
+	Fields []FieldError
+	names  []string
+}
+
+func (e *ValidationError) Error() string {
+	var fields string
+	for _, name := range e.names {
+		fields += name + ", "
+	}
+
+	return fmt.Sprintf("validation errors for the following fields: %s", fields)
+}
+
+func newValidationError(validationErrors validator.ValidationErrors) *ValidationError {
+	e := &ValidationError{}
+	for _, fe := range validationErrors {
+		// a field that is only required without another is
+		// reported as missing like any other required field
+		constraint, value := fe.Tag(), fe.Value()
+		if strings.HasPrefix(constraint, "required") {
+			constraint, value = "required", nil
+		}
+
+		e.Fields = append(e.Fields, FieldError{
+			Pointer:    jsonPointer(fe.StructNamespace()),
+			Constraint: constraint,
+			Value:      value,
+		})
+		e.names = append(e.names, fe.Field())
+	}
+
+	return e
+}
+
+// jsonPointer converts the namespace of a field that failed
+// validation, e.g. Receipt.Items[2].Price, to a JSON pointer using
+// the json names of the fields, e.g. /items/2/price
+func jsonPointer(namespace string) string {
+	t := reflect.TypeOf(Receipt{})
+
+	var b strings.Builder
+	_, path, _ := strings.Cut(namespace, ".")
+	for _, part := range strings.Split(path, ".") {
+		name, index, hasIndex := strings.Cut(part, "[")
+
+		field, ok := t.FieldByName(name)
+		if !ok {
+			b.WriteString("/" + part)
+			continue
+		}
+
+		jsonName, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if jsonName == "" {
+			jsonName = name
+		}
+		b.WriteString("/" + jsonName)
+
+		t = field.Type
+		if hasIndex {
+			b.WriteString("/" + strings.TrimSuffix(index, "]"))
+			t = t.Elem()
+		}
+	}
+
+	return b.String()
+}
+
+// DecodeError is a field of a receipt that could not be decoded
+type DecodeError struct {
+	FieldError
+	Err error
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Pointer, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// decodeField decodes a field on its own so an error is reported
+// with the pointer to the field. Missing fields are left as they are
+func decodeField(raw json.RawMessage, pointer, constraint string, v any) error {
+	if raw == nil {
+		return nil
+	}
+
+	err := json.Unmarshal(raw, v)
+	if err == nil {
+		return nil
+	}
+
+	// the error of a nested field already has its pointer
+	var decodeErr *DecodeError
+	if errors.As(err, &decodeErr) {
+		decodeErr.Pointer = pointer + decodeErr.Pointer
+		return decodeErr
+	}
+
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		constraint = ConstraintType
+	}
+
+	return &DecodeError{
+		FieldError: FieldError{Pointer: pointer, Constraint: constraint, Value: raw},
+		Err:        err,
+	}
+}
+
+// decodeAmount decodes an amount that must have the decimal places
+// of the currency of the receipt
+func decodeAmount(raw json.RawMessage, pointer string, digits int, m *Money) error {
+	if err := decodeField(raw, pointer, ConstraintAmount, m); err != nil || raw == nil {
+		return err
+	}
+
+	return checkDigits(raw, pointer, digits, *m)
+}
+
+// decodeOptionalAmount is decodeAmount for an optional line, which
+// is left nil if it is missing or null
+func decodeOptionalAmount(raw json.RawMessage, pointer string, digits int, m **Money) error {
+	if err := decodeField(raw, pointer, ConstraintAmount, m); err != nil || *m == nil {
+		return err
+	}
+
+	return checkDigits(raw, pointer, digits, **m)
+}
+
+// checkDigits reports an amount without the decimal places of the
+// currency the same way as an amount that cannot be parsed
+func checkDigits(raw json.RawMessage, pointer string, digits int, m Money) error {
+	if m.Digits() == digits {
+		return nil
+	}
+
+	return &DecodeError{
+		FieldError: FieldError{Pointer: pointer, Constraint: ConstraintAmount, Value: raw},
+		Err:        fmt.Errorf("%w: %q", ErrInvalidMoney, m.String()),
+	}
+}
+
+// inCurrency decodes an item or discount of a receipt with the
+// decimal places of the currency of the receipt
+type inCurrency struct {
+	decode func(b []byte, digits int) error
+	digits int
+}
+
+func (c *inCurrency) UnmarshalJSON(b []byte) error {
+	return c.decode(b, c.digits)
+}
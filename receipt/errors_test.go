@@ -0,0 +1,187 @@
+package receipt
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestReceipt_UnmarshalJSONErrors(t *testing.T) {
+	tests := []struct {
+		name           string
+		body           string
+		wantPointer    string
+		wantConstraint string
+		wantValue      string
+		wantErr        error
+	}{
+		{
+			name:           "invalid purchase date",
+			body:           `{"retailer": "Target", "purchaseDate": "2022-13-01", "purchaseTime": "13:01"}`,
+			wantPointer:    "/purchaseDate",
+			wantConstraint: ConstraintDate,
+			wantValue:      `"2022-13-01"`,
+		},
+		{
+			name:           "invalid purchase time",
+			body:           `{"retailer": "Target", "purchaseDate": "2022-01-01", "purchaseTime": "1:01pm"}`,
+			wantPointer:    "/purchaseTime",
+			wantConstraint: ConstraintTime,
+			wantValue:      `"1:01pm"`,
+		},
+		{
+			name: "invalid price of the third item",
+			body: `{"retailer": "Target", "items": [{"shortDescription": "a", "price": "1.00"},
+				{"shortDescription": "b", "price": "2.00"}, {"shortDescription": "c", "price": "3"}]}`,
+			wantPointer:    "/items/2/price",
+			wantConstraint: ConstraintAmount,
+			wantValue:      `"3"`,
+			wantErr:        ErrInvalidMoney,
+		},
+		{
+			name:           "amount without the decimal places of the currency",
+			body:           `{"retailer": "Target", "currency": "JPY", "items": [{"shortDescription": "a", "price": "12.00"}]}`,
+			wantPointer:    "/items/0/price",
+			wantConstraint: ConstraintAmount,
+			wantValue:      `"12.00"`,
+			wantErr:        ErrInvalidMoney,
+		},
+		{
+			name:           "unknown currency",
+			body:           `{"retailer": "Target", "currency": "usd", "total": "1.00"}`,
+			wantPointer:    "/currency",
+			wantConstraint: ConstraintCurrency,
+			wantValue:      `"usd"`,
+			wantErr:        ErrUnknownCurrency,
+		},
+		{
+			name:           "purchasedAt without an offset",
+			body:           `{"retailer": "Target", "purchasedAt": "2022-01-01T13:01:00"}`,
+			wantPointer:    "/purchasedAt",
+			wantConstraint: ConstraintDateTime,
+			wantValue:      `"2022-01-01T13:01:00"`,
+		},
+		{
+			name:           "retailer that is not a string",
+			body:           `{"retailer": 5}`,
+			wantPointer:    "/retailer",
+			wantConstraint: ConstraintType,
+			wantValue:      `5`,
+		},
+		{
+			name:           "invalid tax",
+			body:           `{"retailer": "Target", "tax": "0.5"}`,
+			wantPointer:    "/tax",
+			wantConstraint: ConstraintAmount,
+			wantValue:      `"0.5"`,
+			wantErr:        ErrInvalidMoney,
+		},
+		{
+			name:           "invalid amount of a discount",
+			body:           `{"retailer": "Target", "discounts": [{"description": "Store Coupon", "amount": "-1.00"}]}`,
+			wantPointer:    "/discounts/0/amount",
+			wantConstraint: ConstraintAmount,
+			wantValue:      `"-1.00"`,
+			wantErr:        ErrInvalidMoney,
+		},
+		{
+			name:           "item that is not an object",
+			body:           `{"items": ["Pepsi"]}`,
+			wantPointer:    "/items/0",
+			wantConstraint: ConstraintType,
+			wantValue:      `"Pepsi"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var r Receipt
+			err := json.Unmarshal([]byte(tt.body), &r)
+
+			var decodeErr *DecodeError
+			if !errors.As(err, &decodeErr) {
+				t.Fatalf("json.Unmarshal() error = %v, want a *DecodeError", err)
+			}
+
+			if decodeErr.Pointer != tt.wantPointer || decodeErr.Constraint != tt.wantConstraint {
+				t.Errorf("DecodeError = %s %s, want %s %s", decodeErr.Pointer, decodeErr.Constraint, tt.wantPointer, tt.wantConstraint)
+			}
+
+			if value, _ := json.Marshal(decodeErr.Value); string(value) != tt.wantValue {
+				t.Errorf("DecodeError value = %s, want %s", value, tt.wantValue)
+			}
+
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("json.Unmarshal() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestReceipt_UnmarshalJSON(t *testing.T) {
+	body := `{"retailer": "Target", "purchaseDate": "2022-01-01", "purchaseTime": "13:01", "total": "6.49",
+		"items": [{"shortDescription": "Mountain Dew 12PK", "price": "6.49"}]}`
+
+	var r Receipt
+	if err := json.Unmarshal([]byte(body), &r); err != nil {
+		t.Fatal(err)
+	}
+
+	want := Receipt{
+		Retailer:     "Target",
+		PurchaseDate: purchaseDate(time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)),
+		PurchaseTime: purchaseTime(time.Date(0, 1, 1, 13, 1, 0, 0, time.UTC)),
+		Items:        []Item{{ShortDescription: "Mountain Dew 12PK", Price: MustParseMoney("6.49")}},
+		Total:        MustParseMoney("6.49"),
+	}
+
+	got, _ := json.Marshal(r)
+	if wantJSON, _ := json.Marshal(want); string(got) != string(wantJSON) {
+		t.Errorf("json.Unmarshal() = %s, want %s", got, wantJSON)
+	}
+}
+
+func TestReceipt_UnmarshalJSONCurrency(t *testing.T) {
+	tests := []struct {
+		currency string
+		amount   string
+	}{
+		{currency: "", amount: "6.49"},
+		{currency: "CAD", amount: "6.49"},
+		{currency: "JPY", amount: "649"},
+		{currency: "KWD", amount: "6.490"},
+		{currency: "CLF", amount: "6.4900"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.currency, func(t *testing.T) {
+			body := `{"retailer": "Target", "purchaseDate": "2022-01-01", "purchaseTime": "13:01", "total": "` + tt.amount + `",
+				"items": [{"shortDescription": "Mountain Dew 12PK", "price": "` + tt.amount + `"}]`
+			if tt.currency != "" {
+				body += `, "currency": "` + tt.currency + `"`
+			}
+			body += "}"
+
+			var r Receipt
+			if err := json.Unmarshal([]byte(body), &r); err != nil {
+				t.Fatal(err)
+			}
+
+			if r.Total.String() != tt.amount || r.ItemsTotal().String() != tt.amount {
+				t.Errorf("json.Unmarshal() total = %s and items %s, want %s", r.Total, r.ItemsTotal(), tt.amount)
+			}
+
+			// the amounts are written back as they were read
+			b, err := json.Marshal(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var again Receipt
+			if err := json.Unmarshal(b, &again); err != nil || again.Total != r.Total {
+				t.Errorf("json.Unmarshal(%s) = %v, %v, want the total %s", b, again.Total, err, r.Total)
+			}
+		})
+	}
+}
@@ -0,0 +1,182 @@
+package receipt
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+var ErrNoExchangeRate = errors.New("no exchange rate")
+
+// ExchangeRate is the value of one unit of a currency in the base
+// currency of the rules, from its effective date until the next
+// rate of the currency
+type ExchangeRate struct {
+	Currency  string  `json:"currency" yaml:"currency"`
+	Effective string  `json:"effective" yaml:"effective"`
+	Rate      float64 `json:"rate" yaml:"rate"`
+}
+
+// ExchangeRates is a table of exchange rates read from a local
+// file, so converting an amount never depends on when it is done
+type ExchangeRates struct {
+	Rates []ExchangeRate `json:"rates" yaml:"rates"`
+}
+
+// LoadExchangeRates reads a YAML or JSON exchange rate table, based
+// on its extension, and validates it
+func LoadExchangeRates(path string) (*ExchangeRates, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var rates ExchangeRates
+	switch filepath.Ext(path) {
+	case ".json":
+		dec := json.NewDecoder(bytes.NewReader(b))
+		dec.DisallowUnknownFields()
+		err = dec.Decode(&rates)
+	case ".yml", ".yaml":
+		dec := yaml.NewDecoder(bytes.NewReader(b))
+		dec.KnownFields(true)
+		err = dec.Decode(&rates)
+	default:
+		return nil, fmt.Errorf("exchange rates file %s must be .json, .yml or .yaml", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error parsing exchange rates file %s: %w", path, err)
+	}
+
+	if err := rates.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid exchange rates file %s: %w", path, err)
+	}
+
+	return &rates, nil
+}
+
+// Validate checks that every rate is a positive rate of a known
+// currency, and that no currency has two rates on the same date
+func (x *ExchangeRates) Validate() error {
+	var errs []error
+	seen := make(map[ExchangeRate]bool)
+	for i, rate := range x.Rates {
+		if _, ok := MinorUnits(rate.Currency); !ok {
+			errs = append(errs, fmt.Errorf("rates[%d].currency %q is not an ISO 4217 currency", i, rate.Currency))
+		}
+
+		if _, err := time.Parse(time.DateOnly, rate.Effective); err != nil {
+			errs = append(errs, fmt.Errorf("rates[%d].effective must be a date like 2006-01-02", i))
+		}
+
+		if rate.Rate <= 0 {
+			errs = append(errs, fmt.Errorf("rates[%d].rate must be greater than 0", i))
+		}
+
+		key := ExchangeRate{Currency: rate.Currency, Effective: rate.Effective}
+		if seen[key] {
+			errs = append(errs, fmt.Errorf("rates[%d] is a second rate for %s on %s", i, rate.Currency, rate.Effective))
+		}
+		seen[key] = true
+	}
+
+	return errors.Join(errs...)
+}
+
+// Rate returns the rate of the currency in effect on the date, the
+// rate with the latest effective date that is not after it
+func (x *ExchangeRates) Rate(currency string, on time.Time) (ExchangeRate, error) {
+	day := on.Format(time.DateOnly)
+
+	var found *ExchangeRate
+	if x != nil {
+		for i, rate := range x.Rates {
+			if rate.Currency == currency && rate.Effective <= day && (found == nil || rate.Effective > found.Effective) {
+				found = &x.Rates[i]
+			}
+		}
+	}
+
+	if found == nil {
+		return ExchangeRate{}, fmt.Errorf("%w for %s on %s", ErrNoExchangeRate, currency, day)
+	}
+
+	return *found, nil
+}
+
+// Exchange is the rate the amounts of a receipt were converted with
+// before it was scored
+type Exchange struct {
+	Currency  string `json:"currency"`
+	Base      string `json:"base"`
+	Rate      string `json:"rate"`
+	Effective string `json:"effective"`
+}
+
+// exchange converts receipts to the base currency of a RuleSet
+type exchange struct {
+	base  string
+	rates *ExchangeRates
+}
+
+// normalize returns the receipt with every amount converted to the
+// base currency and rounded to its minor units, along with the rate
+// used. A receipt in the base currency is returned as it is
+func (ex exchange) normalize(r Receipt) (Receipt, *Exchange, error) {
+	currency := r.CurrencyCode()
+	if currency == ex.base {
+		return r, nil, nil
+	}
+
+	rate, err := ex.rates.Rate(currency, time.Time(r.PurchaseDate))
+	if err != nil {
+		return Receipt{}, nil, err
+	}
+
+	factor := decimalRat(rate.Rate)
+	digits, _ := MinorUnits(ex.base)
+	convert := func(m Money) Money {
+		if !m.Valid() {
+			return m
+		}
+		return roundMoney(m.Mul(factor), digits)
+	}
+	convertLine := func(m *Money) *Money {
+		if m == nil {
+			return nil
+		}
+		converted := convert(*m)
+		return &converted
+	}
+
+	n := r
+	n.Currency = ex.base
+	n.Total = convert(r.Total)
+	n.Subtotal, n.Tax, n.Tip = convertLine(r.Subtotal), convertLine(r.Tax), convertLine(r.Tip)
+
+	n.Items = make([]Item, len(r.Items))
+	for i, item := range r.Items {
+		item.Price, item.UnitPrice = convert(item.Price), convertLine(item.UnitPrice)
+		n.Items[i] = item
+	}
+
+	n.Discounts = nil
+	for _, d := range r.Discounts {
+		d.Amount = convert(d.Amount)
+		n.Discounts = append(n.Discounts, d)
+	}
+
+	return n, &Exchange{
+		Currency:  currency,
+		Base:      ex.base,
+		Rate:      strconv.FormatFloat(rate.Rate, 'f', -1, 64),
+		Effective: rate.Effective,
+	}, nil
+}
@@ -0,0 +1,220 @@
+package receipt
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const ratesYAML = `rates:
+  - currency: CAD
+    effective: 2022-01-01
+    rate: 0.79
+  - currency: CAD
+    effective: 2023-01-01
+    rate: 0.74
+  - currency: JPY
+    effective: 2022-01-01
+    rate: 0.0087
+`
+
+func TestLoadExchangeRates(t *testing.T) {
+	tests := []struct {
+		name     string
+		file     string
+		contents string
+		wantErr  string
+	}{
+		{name: "yaml", file: "rates.yml", contents: ratesYAML},
+		{name: "json", file: "rates.json", contents: `{"rates": [{"currency": "EUR", "effective": "2022-01-01", "rate": 1.13}]}`},
+		{
+			name:     "invalid rates",
+			file:     "rates.yml",
+			contents: "rates:\n  - currency: XYZ\n    effective: 01/01/2022\n    rate: 0\n",
+			wantErr: `rates[0].currency "XYZ" is not an ISO 4217 currency
+rates[0].effective must be a date like 2006-01-02
+rates[0].rate must be greater than 0`,
+		},
+		{
+			name:     "two rates on a date",
+			file:     "rates.yml",
+			contents: "rates:\n  - {currency: CAD, effective: 2022-01-01, rate: 0.79}\n  - {currency: CAD, effective: 2022-01-01, rate: 0.8}\n",
+			wantErr:  "rates[1] is a second rate for CAD on 2022-01-01",
+		},
+		{name: "unknown field", file: "rates.yml", contents: "rates:\n  - {currency: CAD, date: 2022-01-01}\n", wantErr: "field date not found"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadExchangeRates(writeConfig(t, tt.file, tt.contents))
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("LoadExchangeRates() error = %v", err)
+				}
+				return
+			}
+
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("LoadExchangeRates() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoadExchangeRates_Example(t *testing.T) {
+	if _, err := LoadExchangeRates("../examples/rates.yml"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadConfig_Rates(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "rates.yml"), []byte(ratesYAML), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "rules.yml"), []byte("currency:\n  base: CAD\n  rates: rates.yml\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// the rates are relative to the rules file
+	cfg, err := LoadConfig(filepath.Join(dir, "rules.yml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(dir, "rates.yml"); cfg.Currency.Rates != want {
+		t.Errorf("LoadConfig() rates = %s, want %s", cfg.Currency.Rates, want)
+	}
+
+	if _, err := NewConfigRuleSet(cfg); err != nil {
+		t.Errorf("NewConfigRuleSet() error = %v", err)
+	}
+
+	cfg.Currency.Rates = filepath.Join(dir, "missing.yml")
+	if _, err := NewConfigRuleSet(cfg); err == nil {
+		t.Error("NewConfigRuleSet() expected an error for a missing rates file")
+	}
+}
+
+func TestExchangeRates_Rate(t *testing.T) {
+	rates, err := LoadExchangeRates(writeConfig(t, "rates.yml", ratesYAML))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		currency string
+		on       string
+		want     float64
+		wantErr  bool
+	}{
+		{currency: "CAD", on: "2021-12-31", wantErr: true},
+		{currency: "CAD", on: "2022-01-01", want: 0.79},
+		{currency: "CAD", on: "2022-12-31", want: 0.79},
+		{currency: "CAD", on: "2023-01-01", want: 0.74},
+		{currency: "CAD", on: "2030-06-15", want: 0.74},
+		{currency: "EUR", on: "2023-01-01", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.currency+" "+tt.on, func(t *testing.T) {
+			on, _ := time.Parse(time.DateOnly, tt.on)
+			got, err := rates.Rate(tt.currency, on)
+			if tt.wantErr {
+				if !errors.Is(err, ErrNoExchangeRate) {
+					t.Errorf("Rate() error = %v, want %v", err, ErrNoExchangeRate)
+				}
+				return
+			}
+
+			if err != nil || got.Rate != tt.want {
+				t.Errorf("Rate() = %v, %v, want %v", got.Rate, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestRuleSet_ScoreCurrency(t *testing.T) {
+	path := writeConfig(t, "rates.yml", ratesYAML)
+	cfg := DefaultConfig()
+	cfg.Currency.Rates = path
+	rs, err := NewConfigRuleSet(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	receiptIn := func(currency, date, total string) Receipt {
+		on, _ := time.Parse(time.DateOnly, date)
+		digits, _ := MinorUnits(currency)
+		amount, err := ParseAmount(total, digits)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return Receipt{
+			Retailer:     "Target",
+			PurchaseDate: purchaseDate(on),
+			PurchaseTime: purchaseTime(time.Date(0, 1, 1, 13, 1, 0, 0, time.UTC)),
+			Items:        []Item{{ShortDescription: "Emils Cheese Pizza", Price: amount}},
+			Total:        amount,
+			Currency:     currency,
+		}
+	}
+
+	tests := []struct {
+		name      string
+		r         Receipt
+		wantTotal string
+		wantRate  string
+		wantErr   error
+	}{
+		{name: "base currency is not converted", r: receiptIn("USD", "2022-01-02", "12.25"), wantTotal: "12.25"},
+		{name: "rate of the purchase date", r: receiptIn("CAD", "2022-06-01", "100.00"), wantTotal: "79.00", wantRate: "0.79"},
+		{name: "later rate", r: receiptIn("CAD", "2023-06-01", "100.00"), wantTotal: "74.00", wantRate: "0.74"},
+		// 0.87 is rounded to the cent
+		{name: "no minor units", r: receiptIn("JPY", "2022-06-01", "100"), wantTotal: "0.87", wantRate: "0.0087"},
+		{name: "before the first rate", r: receiptIn("CAD", "2021-06-01", "100.00"), wantErr: ErrNoExchangeRate},
+		{name: "no rates for the currency", r: receiptIn("EUR", "2022-06-01", "100.00"), wantErr: ErrNoExchangeRate},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := rs.Score(tt.r)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("Score() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var total string
+			for _, result := range got.Rules {
+				if result.Rule == RuleTotalRoundDollar {
+					total = result.Inputs["total"]
+				}
+			}
+			if total != tt.wantTotal {
+				t.Errorf("Score() scored the total %s, want %s", total, tt.wantTotal)
+			}
+
+			if tt.wantRate == "" {
+				if got.Exchange != nil {
+					t.Errorf("Score() exchange = %+v, want none", got.Exchange)
+				}
+			} else if got.Exchange == nil || got.Exchange.Rate != tt.wantRate || got.Exchange.Base != "USD" {
+				t.Errorf("Score() exchange = %+v, want the rate %s to USD", got.Exchange, tt.wantRate)
+			}
+
+			// the items are reconciled with the total in the currency
+			// of the receipt
+			if got.Reconciliation.Total != tt.r.Total {
+				t.Errorf("Score() reconciled the total %s, want %s", got.Reconciliation.Total, tt.r.Total)
+			}
+		})
+	}
+}
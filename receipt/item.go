@@ -1,29 +1,137 @@
 package receipt
 
 import (
-	"math"
+	"encoding/json"
+	"fmt"
 	"strconv"
 	"strings"
 )
 
 type Item struct {
-	ShortDescription string `json:"shortDescription" validate:"required"`
-	Price            string `json:"price" validate:"required,numeric"`
+	ShortDescription string `json:"shortDescription" validate:"required,pattern=^[\\w\\s\\-]+$"`
+	// Price is the total price of the line, the quantity times the
+	// unit price
+	Price Money `json:"price" validate:"required,amount"`
+
+	// Quantity and UnitPrice are optional, an item without them is
+	// a single unit at its price. The quantity is bounded so counting
+	// the units of a receipt cannot overflow, and the price must be
+	// the exact product of the two, see validateQuantity
+	Quantity  *int   `json:"quantity,omitempty" validate:"omitempty,min=1,max=10000"`
+	UnitPrice *Money `json:"unitPrice,omitempty" validate:"omitempty,amount"`
+}
+
+// UnmarshalJSON decodes every field on its own, see
+// Receipt.UnmarshalJSON. The amounts are in DefaultCurrency
+func (i *Item) UnmarshalJSON(b []byte) error {
+	digits, _ := MinorUnits(DefaultCurrency)
+	return i.decode(b, digits)
+}
+
+// decode decodes the fields with amounts that have digits decimal
+// places
+func (i *Item) decode(b []byte, digits int) error {
+	var fields struct {
+		ShortDescription json.RawMessage `json:"shortDescription"`
+		Price            json.RawMessage `json:"price"`
+		Quantity         json.RawMessage `json:"quantity"`
+		UnitPrice        json.RawMessage `json:"unitPrice"`
+	}
+	if err := decodeField(b, "", ConstraintType, &fields); err != nil {
+		return err
+	}
+
+	var item Item
+	if err := decodeField(fields.ShortDescription, "/shortDescription", ConstraintType, &item.ShortDescription); err != nil {
+		return err
+	}
+
+	if err := decodeAmount(fields.Price, "/price", digits, &item.Price); err != nil {
+		return err
+	}
+
+	if err := decodeField(fields.Quantity, "/quantity", ConstraintType, &item.Quantity); err != nil {
+		return err
+	}
+
+	if err := decodeOptionalAmount(fields.UnitPrice, "/unitPrice", digits, &item.UnitPrice); err != nil {
+		return err
+	}
+
+	*i = item
+
+	return nil
 }
 
-func (i Item) scoreDescription() int {
+// Units returns the quantity of the item, 1 if it has none
+func (i Item) Units() int {
+	if i.Quantity == nil {
+		return 1
+	}
+
+	return *i.Quantity
+}
+
+// unitMinor returns the unit price of the item in minor units, or the
+// price divided by the quantity if it has none. The division is
+// only exact for a consistent item, see validateQuantity
+func (i Item) unitMinor() int64 {
+	if i.UnitPrice != nil {
+		return i.UnitPrice.Minor()
+	}
+
+	return i.Price.Minor() / int64(max(i.Units(), 1))
+}
+
+// scoreDescription awards points based on the item price when
+// the trimmed length of the description is a multiple of the
+// configured divisor. Counting by quantity awards the points of
+// the unit price for every unit, as if every unit were an item
+func (i Item) scoreDescription(cfg ItemDescriptionConfig) RuleResult {
 	desc := strings.TrimSpace(i.ShortDescription)
 
-	// length of description is not a multiple of 3
-	if len(desc)%3 != 0 {
-		return 0
+	result := RuleResult{
+		Rule: RuleItemDescription,
+		Inputs: map[string]string{
+			"shortDescription": i.ShortDescription,
+			"price":            i.Price.String(),
+		},
+	}
+	if i.Quantity != nil {
+		result.Inputs["quantity"] = strconv.Itoa(*i.Quantity)
+	}
+	if i.UnitPrice != nil {
+		result.Inputs["unitPrice"] = i.UnitPrice.String()
+	}
+
+	// length of description is not a multiple of the divisor
+	if len(desc)%cfg.LengthDivisor != 0 {
+		result.Description = fmt.Sprintf("%q is %d characters (not a multiple of %d)", desc, len(desc), cfg.LengthDivisor)
+		return result
 	}
 
-	price, _ := strconv.ParseFloat(i.Price, 32)
+	multiplier := strconv.FormatFloat(cfg.PriceMultiplier, 'f', -1, 64)
+
+	if cfg.Counting == ItemCountingQuantity {
+		unit := NewMinorMoney(i.unitMinor(), i.Price.Digits())
+		price := unit.Mul(decimalRat(cfg.PriceMultiplier))
+
+		result.Points = ceil(price) * i.Units()
+		result.Description = fmt.Sprintf(
+			"%q is %d characters (a multiple of %d), unit price of %s * %s = %s, rounded up is %d points for each of %d units = %d points",
+			desc, len(desc), cfg.LengthDivisor, unit, multiplier, formatDecimal(price), ceil(price), i.Units(), result.Points,
+		)
+
+		return result
+	}
 
-	price *= 0.2
+	price := i.Price.Mul(decimalRat(cfg.PriceMultiplier))
 
-	price = math.Ceil(price)
+	result.Points = ceil(price)
+	result.Description = fmt.Sprintf(
+		"%q is %d characters (a multiple of %d), item price of %s * %s = %s, rounded up is %d points",
+		desc, len(desc), cfg.LengthDivisor, i.Price, multiplier, formatDecimal(price), result.Points,
+	)
 
-	return int(price)
+	return result
 }
@@ -1,11 +1,15 @@
 package receipt
 
-import "testing"
+import (
+	"errors"
+	"testing"
+	"time"
+)
 
 func TestItem_scoreDescription(t *testing.T) {
 	type fields struct {
 		ShortDescription string
-		Price            string
+		Price            Money
 	}
 	tests := []struct {
 		name   string
@@ -16,7 +20,7 @@ func TestItem_scoreDescription(t *testing.T) {
 			name: "test example #1 mountain dew",
 			fields: fields{
 				ShortDescription: "Mountain Dew 12PK",
-				Price:            "6.49",
+				Price:            MustParseMoney("6.49"),
 			},
 			want: 0,
 		},
@@ -24,7 +28,7 @@ func TestItem_scoreDescription(t *testing.T) {
 			name: "test example #1 emils cheese pizza",
 			fields: fields{
 				ShortDescription: "Emils Cheese Pizza",
-				Price:            "12.25",
+				Price:            MustParseMoney("12.25"),
 			},
 			want: 3,
 		},
@@ -32,7 +36,7 @@ func TestItem_scoreDescription(t *testing.T) {
 			name: "test example #1 Klarbrunn",
 			fields: fields{
 				ShortDescription: "   Klarbrunn 12-PK 12 FL OZ  ",
-				Price:            "12.00",
+				Price:            MustParseMoney("12.00"),
 			},
 			want: 3,
 		},
@@ -40,7 +44,7 @@ func TestItem_scoreDescription(t *testing.T) {
 			name: "test example #2 gatorade",
 			fields: fields{
 				ShortDescription: "Gatorade",
-				Price:            "2.25",
+				Price:            MustParseMoney("2.25"),
 			},
 			want: 0,
 		},
@@ -51,8 +55,153 @@ func TestItem_scoreDescription(t *testing.T) {
 				ShortDescription: tt.fields.ShortDescription,
 				Price:            tt.fields.Price,
 			}
-			if got := i.scoreDescription(); got != tt.want {
-				t.Errorf("scoreDescription() = %v, want %v", got, tt.want)
+			if got := i.scoreDescription(DefaultConfig().ItemDescription); got.Points != tt.want {
+				t.Errorf("scoreDescription() = %v, want %v", got.Points, tt.want)
+			}
+		})
+	}
+}
+
+func TestItem_Quantity(t *testing.T) {
+	quantity := func(n int) *int { return &n }
+	unitPrice := MustParseMoney("2.25")
+
+	// the M&M Corner Market example with its four Gatorades as a
+	// single item
+	separate := Receipt{Items: []Item{
+		{ShortDescription: "Gatorade", Price: MustParseMoney("2.25")},
+		{ShortDescription: "Gatorade", Price: MustParseMoney("2.25")},
+		{ShortDescription: "Gatorade", Price: MustParseMoney("2.25")},
+		{ShortDescription: "Gatorade", Price: MustParseMoney("2.25")},
+	}}
+	combined := Receipt{Items: []Item{
+		{ShortDescription: "Gatorade", Price: MustParseMoney("9.00"), Quantity: quantity(4), UnitPrice: &unitPrice},
+	}}
+
+	lines, byQuantity := DefaultConfig(), DefaultConfig()
+	byQuantity.ItemPairs.Counting = ItemCountingQuantity
+	byQuantity.ItemDescription.Counting = ItemCountingQuantity
+	// every description is a multiple of 4 characters so the
+	// description rule awards points
+	lines.ItemDescription.LengthDivisor, byQuantity.ItemDescription.LengthDivisor = 4, 4
+
+	tests := []struct {
+		name            string
+		r               Receipt
+		cfg             Config
+		wantPairs       int
+		wantDescription int
+	}{
+		{name: "separate items by lines", r: separate, cfg: lines, wantPairs: 10, wantDescription: 4},
+		{name: "separate items by quantity", r: separate, cfg: byQuantity, wantPairs: 10, wantDescription: 4},
+		{name: "combined item by lines", r: combined, cfg: lines, wantPairs: 0, wantDescription: 2},
+		{name: "combined item by quantity", r: combined, cfg: byQuantity, wantPairs: 10, wantDescription: 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.r.scoreItems(tt.cfg.ItemPairs); got.Points != tt.wantPairs {
+				t.Errorf("scoreItems() = %v, want %v", got.Points, tt.wantPairs)
+			}
+
+			var description int
+			for _, i := range tt.r.Items {
+				description += i.scoreDescription(tt.cfg.ItemDescription).Points
+			}
+			if description != tt.wantDescription {
+				t.Errorf("scoreDescription() = %v, want %v", description, tt.wantDescription)
+			}
+		})
+	}
+}
+
+func TestValidateReceipt_Quantity(t *testing.T) {
+	validate := NewValidator()
+	quantity := func(n int) *int { return &n }
+	money := func(s string) *Money {
+		m := MustParseMoney(s)
+		return &m
+	}
+
+	tests := []struct {
+		name           string
+		item           Item
+		wantPointer    string
+		wantConstraint string
+	}{
+		{name: "quantity times unit price", item: Item{Quantity: quantity(3), UnitPrice: money("2.25"), Price: MustParseMoney("6.75")}},
+		{name: "unit price alone", item: Item{UnitPrice: money("2.25"), Price: MustParseMoney("2.25")}},
+		{name: "quantity alone divides the price", item: Item{Quantity: quantity(3), Price: MustParseMoney("6.75")}},
+		{
+			name:           "price is not the quantity times unit price",
+			item:           Item{Quantity: quantity(3), UnitPrice: money("2.25"), Price: MustParseMoney("6.50")},
+			wantPointer:    "/items/0/price",
+			wantConstraint: ConstraintSum,
+		},
+		{
+			name:           "unit price alone is not the price",
+			item:           Item{UnitPrice: money("2.25"), Price: MustParseMoney("6.75")},
+			wantPointer:    "/items/0/price",
+			wantConstraint: ConstraintSum,
+		},
+		{
+			name:           "quantity alone does not divide the price",
+			item:           Item{Quantity: quantity(3), Price: MustParseMoney("1.00")},
+			wantPointer:    "/items/0/price",
+			wantConstraint: ConstraintSum,
+		},
+		{
+			name:           "quantity times unit price overflows",
+			item:           Item{Quantity: quantity(3), UnitPrice: money("61489146912365172.06"), Price: MustParseMoney("0.02")},
+			wantPointer:    "/items/0/price",
+			wantConstraint: ConstraintSum,
+		},
+		{
+			name:           "zero quantity",
+			item:           Item{Quantity: quantity(0), Price: MustParseMoney("1.00")},
+			wantPointer:    "/items/0/quantity",
+			wantConstraint: "min",
+		},
+		{
+			name: "largest quantity",
+			item: Item{Quantity: quantity(10000), UnitPrice: money("0.00"), Price: MustParseMoney("0.00")},
+		},
+		{
+			name:           "quantity over the maximum",
+			item:           Item{Quantity: quantity(10001), UnitPrice: money("0.00"), Price: MustParseMoney("0.00")},
+			wantPointer:    "/items/0/quantity",
+			wantConstraint: "max",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.item.ShortDescription = "Gatorade"
+			r := Receipt{
+				Retailer:     "Store A",
+				PurchaseDate: purchaseDate(time.Date(2024, 11, 26, 0, 0, 0, 0, time.UTC)),
+				PurchaseTime: purchaseTime(time.Date(0, 1, 1, 15, 30, 0, 0, time.UTC)),
+				Items:        []Item{tt.item},
+				Total:        tt.item.Price,
+			}
+
+			_, err := r.ValidateReceipt(validate)
+			if tt.wantPointer == "" {
+				if err != nil {
+					t.Errorf("expected no error but got %q", err)
+				}
+				return
+			}
+
+			var validationErr *ValidationError
+			if !errors.As(err, &validationErr) {
+				t.Fatalf("expected a *ValidationError but got %T", err)
+			}
+
+			want := []FieldError{{Pointer: tt.wantPointer, Constraint: tt.wantConstraint}}
+			got := validationErr.Fields
+			if len(got) != 1 || got[0].Pointer != want[0].Pointer || got[0].Constraint != want[0].Constraint {
+				t.Errorf("expected %+v but got %+v", want, got)
 			}
 		})
 	}
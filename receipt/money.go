@@ -0,0 +1,220 @@
+package receipt
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var ErrInvalidMoney = errors.New("invalid amount")
+
+// moneyPattern is the format of amounts in api.yml, a number of
+// major units with the minor units of the currency, if it has any,
+// after the decimal point
+var moneyPattern = regexp.MustCompile(`^\d+(\.\d{1,4})?$`)
+
+// Money is an exact amount of money stored in the minor units of
+// its currency, such as cents. The zero value is an amount that
+// was never set, which is different from 0.00
+type Money struct {
+	minor  int64
+	digits int
+	valid  bool
+}
+
+// NewMoney creates an amount from a number of cents
+func NewMoney(cents int64) Money {
+	return NewMinorMoney(cents, 2)
+}
+
+// NewMinorMoney creates an amount from a number of minor units,
+// where a major unit has digits decimal places
+func NewMinorMoney(minor int64, digits int) Money {
+	return Money{minor: minor, digits: digits, valid: true}
+}
+
+// ParseMoney parses an amount with exactly two decimal places, the
+// minor units of USD
+func ParseMoney(s string) (Money, error) {
+	return ParseAmount(s, 2)
+}
+
+// ParseAmount parses an amount in the format used by api.yml with
+// exactly the decimal places of a currency, see MinorUnits
+func ParseAmount(s string, digits int) (Money, error) {
+	m, err := parseAmount(s)
+	if err != nil {
+		return Money{}, err
+	}
+
+	if m.digits != digits {
+		return Money{}, fmt.Errorf("%w: %q", ErrInvalidMoney, s)
+	}
+
+	return m, nil
+}
+
+// MustParseMoney is like ParseMoney but panics if the
+// amount cannot be parsed
+func MustParseMoney(s string) Money {
+	m, err := ParseMoney(s)
+	if err != nil {
+		panic(err)
+	}
+
+	return m
+}
+
+// moneyFromFloat converts a configured amount, such as the 0.25
+// the total must be a multiple of, to Money. The amount must
+// not have fractions of a cent
+func moneyFromFloat(f float64) (Money, error) {
+	s := strconv.FormatFloat(f, 'f', -1, 64)
+
+	whole, frac, _ := strings.Cut(s, ".")
+	if f < 0 || len(frac) > 2 {
+		return Money{}, fmt.Errorf("%w: %s", ErrInvalidMoney, s)
+	}
+
+	return parseAmount(whole + "." + frac + strings.Repeat("0", 2-len(frac)))
+}
+
+// parseAmount parses an amount in the format used by api.yml with
+// as many minor units as it has decimal places
+func parseAmount(s string) (Money, error) {
+	if !moneyPattern.MatchString(s) {
+		return Money{}, fmt.Errorf("%w: %q", ErrInvalidMoney, s)
+	}
+
+	_, frac, _ := strings.Cut(s, ".")
+	minor, err := strconv.ParseInt(strings.Replace(s, ".", "", 1), 10, 64)
+	if err != nil {
+		return Money{}, fmt.Errorf("%w: %q", ErrInvalidMoney, s)
+	}
+
+	return NewMinorMoney(minor, len(frac)), nil
+}
+
+// Minor returns the amount in the minor units of its currency,
+// e.g. cents
+func (m Money) Minor() int64 {
+	return m.minor
+}
+
+// Digits returns the number of decimal places of the amount
+func (m Money) Digits() int {
+	return m.digits
+}
+
+// Valid reports whether the amount was set
+func (m Money) Valid() bool {
+	return m.valid
+}
+
+// String formats the amount with its decimal places
+func (m Money) String() string {
+	if m.digits == 0 {
+		return strconv.FormatInt(m.minor, 10)
+	}
+
+	scale := pow10(m.digits)
+	return fmt.Sprintf("%d.%0*d", m.minor/scale, m.digits, m.minor%scale)
+}
+
+// IsWholeUnit reports whether the amount has no minor units, such
+// as a round dollar amount
+func (m Money) IsWholeUnit() bool {
+	return m.minor%pow10(m.digits) == 0
+}
+
+// IsMultipleOf reports whether the amount is an exact multiple
+// of other
+func (m Money) IsMultipleOf(other Money) bool {
+	if other.minor == 0 {
+		return false
+	}
+
+	return new(big.Rat).Quo(m.rat(), other.rat()).IsInt()
+}
+
+// Mul multiplies the amount in major units by the factor exactly
+func (m Money) Mul(factor *big.Rat) *big.Rat {
+	return new(big.Rat).Mul(m.rat(), factor)
+}
+
+// rat returns the amount in major units
+func (m Money) rat() *big.Rat {
+	return big.NewRat(m.minor, pow10(m.digits))
+}
+
+// roundMoney rounds a non-negative amount in major units to the
+// nearest minor unit, halves rounded up
+func roundMoney(r *big.Rat, digits int) Money {
+	scaled := new(big.Rat).Mul(r, big.NewRat(pow10(digits), 1))
+	scaled.Add(scaled, big.NewRat(1, 2))
+
+	minor := new(big.Int).Quo(scaled.Num(), scaled.Denom())
+
+	return NewMinorMoney(minor.Int64(), digits)
+}
+
+// pow10 returns 10 to the power of n
+func pow10(n int) int64 {
+	p := int64(1)
+	for range n {
+		p *= 10
+	}
+
+	return p
+}
+
+func (m Money) MarshalJSON() ([]byte, error) {
+	return json.Marshal(m.String())
+}
+
+func (m *Money) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+
+	// the decimal places of the currency are checked by the receipt
+	parsed, err := parseAmount(s)
+	if err != nil {
+		return err
+	}
+
+	*m = parsed
+
+	return nil
+}
+
+// ceil rounds a non-negative rational up to the nearest integer
+func ceil(r *big.Rat) int {
+	q, rem := new(big.Int).QuoRem(r.Num(), r.Denom(), new(big.Int))
+	if rem.Sign() > 0 {
+		q.Add(q, big.NewInt(1))
+	}
+
+	return int(q.Int64())
+}
+
+// formatDecimal formats a rational with a finite decimal
+// expansion without trailing zeros
+func formatDecimal(r *big.Rat) string {
+	s := r.FloatString(10)
+	s = strings.TrimRight(s, "0")
+
+	return strings.TrimSuffix(s, ".")
+}
+
+// decimalRat converts a configured decimal, such as the 0.2 item
+// price multiplier, to the exact value it was written as
+func decimalRat(f float64) *big.Rat {
+	r, _ := new(big.Rat).SetString(strconv.FormatFloat(f, 'f', -1, 64))
+	return r
+}
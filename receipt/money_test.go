@@ -0,0 +1,207 @@
+package receipt
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestParseMoney(t *testing.T) {
+	tests := []struct {
+		name      string
+		s         string
+		wantCents int64
+		wantErr   bool
+	}{
+		{name: "zero", s: "0.00", wantCents: 0},
+		{name: "one cent", s: "0.01", wantCents: 1},
+		{name: "whole dollars", s: "9.00", wantCents: 900},
+		{name: "leading zeros", s: "007.50", wantCents: 750},
+		{name: "large amount", s: "92233720368547758.07", wantCents: 9223372036854775807},
+		{name: "overflow", s: "92233720368547758.08", wantErr: true},
+		{name: "exponent", s: "1e5", wantErr: true},
+		{name: "negative", s: "-3.00", wantErr: true},
+		{name: "too many decimals", s: "6.4999", wantErr: true},
+		{name: "one decimal", s: "6.4", wantErr: true},
+		{name: "no decimals", s: "6", wantErr: true},
+		{name: "trailing point", s: "6.", wantErr: true},
+		{name: "no dollars", s: ".50", wantErr: true},
+		{name: "thousands separator", s: "1,000.00", wantErr: true},
+		{name: "surrounding space", s: " 1.00", wantErr: true},
+		{name: "empty", s: "", wantErr: true},
+		{name: "not a number", s: "abc", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseMoney(tt.s)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidMoney) {
+					t.Errorf("ParseMoney(%q) error = %v, want %v", tt.s, err, ErrInvalidMoney)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("ParseMoney(%q) error = %v", tt.s, err)
+			}
+
+			if got.Minor() != tt.wantCents || !got.Valid() {
+				t.Errorf("ParseMoney(%q) = %d cents, want %d", tt.s, got.Minor(), tt.wantCents)
+			}
+		})
+	}
+}
+
+func TestParseAmount(t *testing.T) {
+	tests := []struct {
+		s          string
+		digits     int
+		wantMinor  int64
+		wantString string
+		wantErr    bool
+	}{
+		{s: "1200", digits: 0, wantMinor: 1200, wantString: "1200"},
+		{s: "1.250", digits: 3, wantMinor: 1250, wantString: "1.250"},
+		{s: "0.0001", digits: 4, wantMinor: 1, wantString: "0.0001"},
+		{s: "6.49", digits: 2, wantMinor: 649, wantString: "6.49"},
+		{s: "12.00", digits: 0, wantErr: true},
+		{s: "1200", digits: 2, wantErr: true},
+		{s: "1.25", digits: 3, wantErr: true},
+		{s: "1.00000", digits: 5, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.s, func(t *testing.T) {
+			got, err := ParseAmount(tt.s, tt.digits)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidMoney) {
+					t.Errorf("ParseAmount(%q, %d) error = %v, want %v", tt.s, tt.digits, err, ErrInvalidMoney)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("ParseAmount(%q, %d) error = %v", tt.s, tt.digits, err)
+			}
+
+			if got.Minor() != tt.wantMinor || got.String() != tt.wantString {
+				t.Errorf("ParseAmount(%q, %d) = %d minor units (%s), want %d (%s)", tt.s, tt.digits, got.Minor(), got, tt.wantMinor, tt.wantString)
+			}
+		})
+	}
+}
+
+func TestMoney_Digits(t *testing.T) {
+	quarter := MustParseMoney("0.25")
+
+	tests := []struct {
+		amount       Money
+		wantWhole    bool
+		wantMultiple bool
+	}{
+		{amount: NewMinorMoney(1200, 0), wantWhole: true, wantMultiple: true},
+		{amount: NewMinorMoney(1250, 3), wantWhole: false, wantMultiple: true},
+		{amount: NewMinorMoney(1255, 3), wantWhole: false, wantMultiple: false},
+		{amount: NewMinorMoney(20000, 4), wantWhole: true, wantMultiple: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.amount.String(), func(t *testing.T) {
+			if got := tt.amount.IsWholeUnit(); got != tt.wantWhole {
+				t.Errorf("IsWholeUnit() = %t, want %t", got, tt.wantWhole)
+			}
+
+			if got := tt.amount.IsMultipleOf(quarter); got != tt.wantMultiple {
+				t.Errorf("IsMultipleOf(%s) = %t, want %t", quarter, got, tt.wantMultiple)
+			}
+		})
+	}
+}
+
+func TestMoney_JSON(t *testing.T) {
+	var item Item
+	if err := json.Unmarshal([]byte(`{"shortDescription": "Gatorade", "price": "002.25"}`), &item); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := json.Marshal(item)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := `{"shortDescription":"Gatorade","price":"2.25"}`; string(b) != want {
+		t.Errorf("Marshal() = %s, want %s", b, want)
+	}
+
+	for _, body := range []string{`{"price": "2.2"}`, `{"price": 2.25}`, `{"price": "abc"}`} {
+		if err := json.Unmarshal([]byte(body), &item); err == nil {
+			t.Errorf("Unmarshal(%s) expected an error", body)
+		}
+	}
+}
+
+func TestMoney_ScoreBoundaries(t *testing.T) {
+	cfg := DefaultConfig()
+
+	descriptions := []struct {
+		price string
+		want  int
+	}{
+		{price: "0.00", want: 0},
+		{price: "0.01", want: 1},
+		{price: "0.05", want: 1},
+		{price: "4.99", want: 1},
+		{price: "5.00", want: 1},
+		{price: "5.01", want: 2},
+		{price: "10.00", want: 2},
+		{price: "15.00", want: 3},
+		{price: "35.00", want: 7},
+		{price: "45.00", want: 9},
+		{price: "12.25", want: 3},
+		{price: "99999.95", want: 20000},
+		{price: "99999.96", want: 20000},
+		{price: "99999.99", want: 20000},
+	}
+
+	for _, tt := range descriptions {
+		t.Run("price "+tt.price, func(t *testing.T) {
+			i := Item{ShortDescription: "abc", Price: MustParseMoney(tt.price)}
+			if got := i.scoreDescription(cfg.ItemDescription); got.Points != tt.want {
+				t.Errorf("scoreDescription() = %v, want %v", got.Points, tt.want)
+			}
+		})
+	}
+
+	totals := []struct {
+		total string
+		want  int
+	}{
+		{total: "0.00", want: 75},
+		{total: "0.01", want: 0},
+		{total: "0.24", want: 0},
+		{total: "0.25", want: 25},
+		{total: "0.26", want: 0},
+		{total: "0.99", want: 0},
+		{total: "1.00", want: 75},
+		{total: "1.01", want: 0},
+		{total: "16777217.00", want: 75},
+		{total: "16777216.25", want: 25},
+		{total: "16777216.26", want: 0},
+		{total: "92233720368547758.00", want: 75},
+	}
+
+	for _, tt := range totals {
+		t.Run("total "+tt.total, func(t *testing.T) {
+			r := Receipt{Total: MustParseMoney(tt.total)}
+			got, err := r.scoreTotal(cfg.Total)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if sumPoints(got) != tt.want {
+				t.Errorf("scoreTotal() = %v, want %v", sumPoints(got), tt.want)
+			}
+		})
+	}
+}
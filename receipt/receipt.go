@@ -1,89 +1,353 @@
 package receipt
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
-	"math"
 	"strconv"
 	"strings"
+	"time"
 	"unicode"
 
 	"github.com/go-playground/validator/v10"
 )
 
-const multipleConstant = 0.25
+// IDs of the rules used to score a receipt
+const (
+	RuleRetailer         = "retailer-alphanumeric"
+	RuleTotalRoundDollar = "total-round-dollar"
+	RuleTotalMultiple    = "total-multiple"
+	RuleItemPairs        = "item-pairs"
+	RuleItemDescription  = "item-description"
+	RulePurchaseDayOdd   = "purchase-day-odd"
+	RulePurchaseTime     = "purchase-time-window"
+)
 
 type Receipt struct {
-	Retailer     string       `json:"retailer" validate:"required"`
-	PurchaseDate purchaseDate `json:"purchaseDate" validate:"required"`
-	PurchaseTime purchaseTime `json:"purchaseTime" validate:"required"`
+	Retailer string `json:"retailer" validate:"required,pattern=^[\\w\\s\\-&]+$"`
+	// PurchaseDate and PurchaseTime are the local time of the store.
+	// They are optional with a purchasedAt, see Receipt.decode
+	PurchaseDate purchaseDate `json:"purchaseDate" validate:"required_without=PurchasedAt"`
+	PurchaseTime purchaseTime `json:"purchaseTime" validate:"required_without=PurchasedAt"`
 	Items        []Item       `json:"items" validate:"gt=0,dive"`
-	Total        string       `json:"total" validate:"required,numeric"`
+	Total        Money        `json:"total" validate:"required,amount"`
+	// Currency is the ISO 4217 code of the currency of every amount
+	// on the receipt, DefaultCurrency if it is empty
+	Currency string `json:"currency,omitempty" validate:"omitempty,currency"`
+	// Timezone is the IANA time zone of the store, e.g.
+	// America/Chicago. The purchase date and time are the local
+	// time of the store, in UTC without a timezone or purchasedAt
+	Timezone string `json:"timezone,omitempty" validate:"omitempty,timezone"`
+	// PurchasedAt is the RFC 3339 time of the purchase. Its local
+	// time, in the timezone of the receipt or the offset of
+	// purchasedAt without one, is the purchase date and time, which
+	// it can replace
+	PurchasedAt *time.Time `json:"purchasedAt,omitempty"`
+
+	// Subtotal, Tax, Tip and Discounts are optional lines of the
+	// receipt. The subtotal is the items less the discounts, before
+	// tax and tip
+	Subtotal  *Money     `json:"subtotal,omitempty" validate:"omitempty,amount"`
+	Tax       *Money     `json:"tax,omitempty" validate:"omitempty,amount"`
+	Tip       *Money     `json:"tip,omitempty" validate:"omitempty,amount"`
+	Discounts []Discount `json:"discounts,omitempty" validate:"dive"`
 }
 
-// GetScore gets the total number of points that is
-// awarded to receipt
-func (r Receipt) GetScore() (int, error) {
-	score := 0
+// UnmarshalJSON decodes every field on its own so a value that
+// cannot be decoded is reported with the path to it, see DecodeError.
+// The purchase date and time must be in the formats of api.yml, see
+// DateFormats to accept others
+func (r *Receipt) UnmarshalJSON(b []byte) error {
+	return r.decode(b, DateFormats{})
+}
 
-	score += r.scoreRetailer()
+// decode decodes a receipt with its purchase date and time in the
+// formats. A purchase date or time that is left out of a receipt
+// with a purchasedAt is its local time
+func (r *Receipt) decode(b []byte, f DateFormats) error {
+	var fields struct {
+		Retailer     json.RawMessage `json:"retailer"`
+		PurchaseDate json.RawMessage `json:"purchaseDate"`
+		PurchaseTime json.RawMessage `json:"purchaseTime"`
+		Items        json.RawMessage `json:"items"`
+		Total        json.RawMessage `json:"total"`
+		Currency     json.RawMessage `json:"currency"`
+		Timezone     json.RawMessage `json:"timezone"`
+		PurchasedAt  json.RawMessage `json:"purchasedAt"`
+		Subtotal     json.RawMessage `json:"subtotal"`
+		Tax          json.RawMessage `json:"tax"`
+		Tip          json.RawMessage `json:"tip"`
+		Discounts    json.RawMessage `json:"discounts"`
+	}
+	if err := decodeField(b, "", ConstraintType, &fields); err != nil {
+		return err
+	}
 
-	total, err := r.scoreTotal()
-	if err != nil {
-		return 0, nil
+	var rcpt Receipt
+	if err := decodeField(fields.Retailer, "/retailer", ConstraintType, &rcpt.Retailer); err != nil {
+		return err
+	}
+
+	date := inFormats{decode: rcpt.PurchaseDate.decode, formats: f}
+	if err := decodeField(fields.PurchaseDate, "/purchaseDate", ConstraintDate, &date); err != nil {
+		return err
+	}
+
+	tm := inFormats{decode: rcpt.PurchaseTime.decode, formats: f}
+	if err := decodeField(fields.PurchaseTime, "/purchaseTime", ConstraintTime, &tm); err != nil {
+		return err
+	}
+
+	if err := decodeField(fields.Timezone, "/timezone", ConstraintType, &rcpt.Timezone); err != nil {
+		return err
+	}
+
+	if err := decodeField(fields.PurchasedAt, "/purchasedAt", ConstraintDateTime, &rcpt.PurchasedAt); err != nil {
+		return err
+	}
+
+	if rcpt.PurchasedAt != nil {
+		// an unknown timezone is reported by validation, the local
+		// time is in the offset of purchasedAt until then
+		local := *rcpt.PurchasedAt
+		if loc, err := rcpt.Location(); err == nil {
+			local = local.In(loc)
+		}
+
+		if fields.PurchaseDate == nil {
+			rcpt.PurchaseDate = purchaseDate(time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, time.UTC))
+		}
+		if fields.PurchaseTime == nil {
+			rcpt.PurchaseTime = purchaseTime(time.Date(0, 1, 1, local.Hour(), local.Minute(), 0, 0, time.UTC))
+		}
+	}
+
+	var currency *string
+	if err := decodeField(fields.Currency, "/currency", ConstraintType, &currency); err != nil {
+		return err
+	}
+
+	// amounts are decoded with the decimal places of the currency
+	if currency != nil {
+		if _, ok := MinorUnits(*currency); !ok {
+			return &DecodeError{
+				FieldError: FieldError{Pointer: "/currency", Constraint: ConstraintCurrency, Value: fields.Currency},
+				Err:        fmt.Errorf("%w: %q", ErrUnknownCurrency, *currency),
+			}
+		}
+		rcpt.Currency = *currency
+	}
+	digits, _ := MinorUnits(rcpt.CurrencyCode())
+
+	var items []json.RawMessage
+	if err := decodeField(fields.Items, "/items", ConstraintType, &items); err != nil {
+		return err
+	}
+
+	if items != nil {
+		rcpt.Items = make([]Item, len(items))
+	}
+	for i, raw := range items {
+		item := inCurrency{decode: rcpt.Items[i].decode, digits: digits}
+		if err := decodeField(raw, fmt.Sprintf("/items/%d", i), ConstraintType, &item); err != nil {
+			return err
+		}
+	}
+
+	if err := decodeAmount(fields.Total, "/total", digits, &rcpt.Total); err != nil {
+		return err
 	}
-	score += total
 
-	score += r.scoreItems()
+	if err := decodeOptionalAmount(fields.Subtotal, "/subtotal", digits, &rcpt.Subtotal); err != nil {
+		return err
+	}
+
+	if err := decodeOptionalAmount(fields.Tax, "/tax", digits, &rcpt.Tax); err != nil {
+		return err
+	}
+
+	if err := decodeOptionalAmount(fields.Tip, "/tip", digits, &rcpt.Tip); err != nil {
+		return err
+	}
+
+	var discounts []json.RawMessage
+	if err := decodeField(fields.Discounts, "/discounts", ConstraintType, &discounts); err != nil {
+		return err
+	}
 
-	for _, i := range r.Items {
-		score += i.scoreDescription()
+	if discounts != nil {
+		rcpt.Discounts = make([]Discount, len(discounts))
+	}
+	for i, raw := range discounts {
+		discount := inCurrency{decode: rcpt.Discounts[i].decode, digits: digits}
+		if err := decodeField(raw, fmt.Sprintf("/discounts/%d", i), ConstraintType, &discount); err != nil {
+			return err
+		}
 	}
 
-	score += r.PurchaseDate.scoreDay()
-	score += r.PurchaseTime.scoreTime()
+	*r = rcpt
 
-	return score, nil
+	return nil
+}
+
+// ItemsTotal returns the sum of the item prices
+func (r Receipt) ItemsTotal() Money {
+	var minor int64
+	for _, item := range r.Items {
+		minor += item.Price.Minor()
+	}
+
+	return r.money(minor)
+}
+
+// Units returns the number of units of every item, see Item.Units
+func (r Receipt) Units() int {
+	var units int
+	for _, item := range r.Items {
+		units += item.Units()
+	}
+
+	return units
+}
+
+// DiscountsTotal returns the sum of the discounts
+func (r Receipt) DiscountsTotal() Money {
+	var minor int64
+	for _, d := range r.Discounts {
+		minor += d.Amount.Minor()
+	}
+
+	return r.money(minor)
+}
+
+// PreTaxSubtotal returns the subtotal of the receipt, or the items
+// less the discounts if it has none
+func (r Receipt) PreTaxSubtotal() Money {
+	if r.Subtotal != nil {
+		return *r.Subtotal
+	}
+
+	return r.money(r.ItemsTotal().Minor() - r.DiscountsTotal().Minor())
+}
+
+// lineMinor returns the minor units of an optional line, 0 if it is not
+// on the receipt
+func lineMinor(m *Money) int64 {
+	if m == nil {
+		return 0
+	}
+
+	return m.Minor()
+}
+
+// GetScore gets the total number of points that is
+// awarded to receipt
+func (r Receipt) GetScore() (int, error) {
+	breakdown, err := r.GetBreakdown()
+	if err != nil {
+		return 0, err
+	}
+
+	return breakdown.Points, nil
+}
+
+// GetBreakdown scores the receipt with the default rules and
+// returns the result of every rule along with the total points
+func (r Receipt) GetBreakdown() (Breakdown, error) {
+	return DefaultRuleSet().Score(r)
 }
 
 // scoreRetailer counts the number of alphanumeric characters
 // in the string
-func (r Receipt) scoreRetailer() int {
+func (r Receipt) scoreRetailer(cfg RetailerConfig) RuleResult {
 	retailer := strings.TrimSpace(r.Retailer)
 
-	score := 0
+	count := 0
 	for _, v := range retailer {
 		if unicode.IsNumber(v) || unicode.IsLetter(v) {
-			score++
+			count++
 		}
 	}
 
-	return score
+	return RuleResult{
+		Rule:        RuleRetailer,
+		Description: fmt.Sprintf("retailer name (%s) has %d alphanumeric characters", retailer, count),
+		Points:      count * cfg.PointsPerCharacter,
+		Inputs:      map[string]string{"retailer": r.Retailer},
+	}
 }
 
-// scoreTotal checks if the receipt total is a multiple of
-// 0.25 and has no cents
-func (r Receipt) scoreTotal() (int, error) {
-	score := 0
-
-	total, err := strconv.ParseFloat(r.Total, 32)
+// scoreTotal checks if the receipt total, or its pre-tax subtotal
+// depending on the configured basis, is a multiple of the configured
+// amount and has no minor units
+func (r Receipt) scoreTotal(cfg TotalConfig) ([]RuleResult, error) {
+	multipleOf, err := moneyFromFloat(cfg.Multiple)
 	if err != nil {
-		return 0, err
+		return nil, err
+	}
+
+	basis, amount := "total", r.Total
+	if cfg.Basis == TotalBasisSubtotal {
+		// the subtotal is the items less the discounts without one
+		if r.Subtotal == nil {
+			if err := r.checkSubtotal(); err != nil {
+				return nil, err
+			}
+		}
+		basis, amount = "subtotal", r.PreTaxSubtotal()
 	}
 
-	if math.Mod(total, multipleConstant) == 0 {
-		score += 25
+	if err := checkAmount("/"+basis, -1, amount); err != nil {
+		return nil, err
 	}
 
-	if math.Mod(total*100, 100) == 0 {
-		score += 50
+	inputs := map[string]string{basis: amount.String()}
+
+	roundDollar := RuleResult{
+		Rule:        RuleTotalRoundDollar,
+		Description: basis + " is not a round dollar amount",
+		Inputs:      inputs,
+	}
+	if amount.IsWholeUnit() {
+		roundDollar.Description = basis + " is a round dollar amount"
+		roundDollar.Points = cfg.RoundDollarPoints
 	}
 
-	return score, nil
+	multiple := RuleResult{
+		Rule:        RuleTotalMultiple,
+		Description: fmt.Sprintf("%s is not a multiple of %s", basis, multipleOf),
+		Inputs:      inputs,
+	}
+	if amount.IsMultipleOf(multipleOf) {
+		multiple.Description = fmt.Sprintf("%s is a multiple of %s", basis, multipleOf)
+		multiple.Points = cfg.MultiplePoints
+	}
+
+	return []RuleResult{roundDollar, multiple}, nil
 }
 
-func (r Receipt) scoreItems() int {
-	return (len(r.Items) / 2) * 5
+// scoreItems awards points for every group of items (every
+// two items by default) on the receipt. Counting by quantity
+// counts every unit of an item
+func (r Receipt) scoreItems(cfg ItemPairsConfig) RuleResult {
+	count := len(r.Items)
+	if cfg.Counting == ItemCountingQuantity {
+		count = r.Units()
+	}
+
+	groups := count / cfg.GroupSize
+
+	description := fmt.Sprintf("%d items (%d groups of %d @ %d points each)", count, groups, cfg.GroupSize, cfg.PointsPerGroup)
+	if cfg.GroupSize == 2 {
+		description = fmt.Sprintf("%d items (%d pairs @ %d points each)", count, groups, cfg.PointsPerGroup)
+	}
+
+	return RuleResult{
+		Rule:        RuleItemPairs,
+		Description: description,
+		Points:      groups * cfg.PointsPerGroup,
+		Inputs:      map[string]string{"items": strconv.Itoa(count)},
+	}
 }
 
 func (r Receipt) ValidateReceipt(v *validator.Validate) (validator.ValidationErrors, error) {
@@ -98,10 +362,5 @@ func (r Receipt) ValidateReceipt(v *validator.Validate) (validator.ValidationErr
 		return nil, nil
 	}
 
-	var fields string
-	for _, v := range validationErrors {
-		fields += v.Field() + ", "
-	}
-
-	return validationErrors, errors.New(fmt.Sprintf("validation errors for the following fields: %s", fields))
+	return validationErrors, newValidationError(validationErrors)
 }
@@ -1,7 +1,8 @@
 package receipt
 
 import (
-	"github.com/go-playground/validator/v10"
+	"errors"
+	"reflect"
 	"testing"
 	"time"
 )
@@ -18,7 +19,7 @@ func TestReceipt_GetScore(t *testing.T) {
 		PurchaseDate purchaseDate
 		PurchaseTime purchaseTime
 		Items        []Item
-		Total        string
+		Total        Money
 	}
 	tests := []struct {
 		name   string
@@ -34,26 +35,26 @@ func TestReceipt_GetScore(t *testing.T) {
 				Items: []Item{
 					{
 						ShortDescription: "Mountain Dew 12PK",
-						Price:            "6.49",
+						Price:            MustParseMoney("6.49"),
 					},
 					{
 						ShortDescription: "Emils Cheese Pizza",
-						Price:            "12.25",
+						Price:            MustParseMoney("12.25"),
 					},
 					{
 						ShortDescription: "Knorr Creamy Chicken",
-						Price:            "1.26",
+						Price:            MustParseMoney("1.26"),
 					},
 					{
 						ShortDescription: "Doritos Nacho Cheese",
-						Price:            "3.35",
+						Price:            MustParseMoney("3.35"),
 					},
 					{
 						ShortDescription: "   Klarbrunn 12-PK 12 FL OZ  ",
-						Price:            "12.00",
+						Price:            MustParseMoney("12.00"),
 					},
 				},
-				Total: "35.35",
+				Total: MustParseMoney("35.35"),
 			},
 			want: 28,
 		},
@@ -66,22 +67,22 @@ func TestReceipt_GetScore(t *testing.T) {
 				Items: []Item{
 					{
 						ShortDescription: "Gatorade",
-						Price:            "2.25",
+						Price:            MustParseMoney("2.25"),
 					},
 					{
 						ShortDescription: "Gatorade",
-						Price:            "2.25",
+						Price:            MustParseMoney("2.25"),
 					},
 					{
 						ShortDescription: "Gatorade",
-						Price:            "2.25",
+						Price:            MustParseMoney("2.25"),
 					},
 					{
 						ShortDescription: "Gatorade",
-						Price:            "2.25",
+						Price:            MustParseMoney("2.25"),
 					},
 				},
-				Total: "9.00",
+				Total: MustParseMoney("9.00"),
 			},
 			want: 109,
 		},
@@ -102,6 +103,77 @@ func TestReceipt_GetScore(t *testing.T) {
 	}
 }
 
+func TestReceipt_GetScoreError(t *testing.T) {
+	// the default rules have no exchange rates
+	r := Receipt{
+		Retailer:     "Target",
+		PurchaseDate: purchaseDate(time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)),
+		PurchaseTime: purchaseTime(time.Date(0, 1, 1, 13, 1, 0, 0, time.UTC)),
+		Items:        []Item{{ShortDescription: "Pepsi - 12-oz", Price: MustParseMoney("1.25")}},
+		Total:        MustParseMoney("1.25"),
+		Currency:     "CAD",
+	}
+
+	if got, err := r.GetScore(); !errors.Is(err, ErrNoExchangeRate) {
+		t.Errorf("GetScore() = %v, %v, want error %v", got, err, ErrNoExchangeRate)
+	}
+}
+
+func TestReceipt_GetBreakdown(t *testing.T) {
+	purchaseDt, _ := time.Parse(time.DateOnly, "2022-03-20")
+	purchaseTm, _ := time.Parse(timeOnly, "14:33")
+
+	r := Receipt{
+		Retailer:     "M&M Corner Market",
+		PurchaseDate: purchaseDate(purchaseDt),
+		PurchaseTime: purchaseTime(purchaseTm),
+		Items: []Item{
+			{ShortDescription: "Gatorade", Price: MustParseMoney("2.25")},
+			{ShortDescription: "Gatorade", Price: MustParseMoney("2.25")},
+			{ShortDescription: "Gatorade", Price: MustParseMoney("2.25")},
+			{ShortDescription: "Gatorade", Price: MustParseMoney("2.25")},
+		},
+		Total: MustParseMoney("9.00"),
+	}
+
+	want := []RuleResult{
+		{Rule: RuleRetailer, Description: "retailer name (M&M Corner Market) has 14 alphanumeric characters", Points: 14},
+		{Rule: RuleTotalRoundDollar, Description: "total is a round dollar amount", Points: 50},
+		{Rule: RuleTotalMultiple, Description: "total is a multiple of 0.25", Points: 25},
+		{Rule: RuleItemPairs, Description: "4 items (2 pairs @ 5 points each)", Points: 10},
+		{Rule: RuleItemDescription, Description: `"Gatorade" is 8 characters (not a multiple of 3)`},
+		{Rule: RuleItemDescription, Description: `"Gatorade" is 8 characters (not a multiple of 3)`},
+		{Rule: RuleItemDescription, Description: `"Gatorade" is 8 characters (not a multiple of 3)`},
+		{Rule: RuleItemDescription, Description: `"Gatorade" is 8 characters (not a multiple of 3)`},
+		{Rule: RulePurchaseDayOdd, Description: "purchase day is even"},
+		{Rule: RulePurchaseTime, Description: "2:33pm is after 2:00pm and before 4:00pm", Points: 10},
+	}
+
+	got, err := r.GetBreakdown()
+	if err != nil {
+		t.Fatalf("GetBreakdown() error = %v", err)
+	}
+
+	if got.Points != 109 {
+		t.Errorf("GetBreakdown() points = %v, want %v", got.Points, 109)
+	}
+
+	if len(got.Rules) != len(want) {
+		t.Fatalf("GetBreakdown() returned %d rules, want %d", len(got.Rules), len(want))
+	}
+
+	for i, w := range want {
+		g := got.Rules[i]
+		if g.Rule != w.Rule || g.Description != w.Description || g.Points != w.Points {
+			t.Errorf("rule #%d = {%s %q %d}, want {%s %q %d}", i, g.Rule, g.Description, g.Points, w.Rule, w.Description, w.Points)
+		}
+	}
+
+	if got.Rules[4].Inputs["item"] != "0" || got.Rules[7].Inputs["item"] != "3" {
+		t.Errorf("item index inputs not recorded, got %v and %v", got.Rules[4].Inputs, got.Rules[7].Inputs)
+	}
+}
+
 func TestReceipt_scoreItems(t *testing.T) {
 	type fields struct {
 		Items []Item
@@ -117,23 +189,23 @@ func TestReceipt_scoreItems(t *testing.T) {
 				Items: []Item{
 					{
 						ShortDescription: "Mountain Dew 12PK",
-						Price:            "6.49",
+						Price:            MustParseMoney("6.49"),
 					},
 					{
 						ShortDescription: "Emils Cheese Pizza",
-						Price:            "12.25",
+						Price:            MustParseMoney("12.25"),
 					},
 					{
 						ShortDescription: "Knorr Creamy Chicken",
-						Price:            "1.26",
+						Price:            MustParseMoney("1.26"),
 					},
 					{
 						ShortDescription: "Doritos Nacho Cheese",
-						Price:            "3.35",
+						Price:            MustParseMoney("3.35"),
 					},
 					{
 						ShortDescription: "   Klarbrunn 12-PK 12 FL OZ  ",
-						Price:            "12.00",
+						Price:            MustParseMoney("12.00"),
 					},
 				},
 			},
@@ -145,23 +217,23 @@ func TestReceipt_scoreItems(t *testing.T) {
 				Items: []Item{
 					{
 						ShortDescription: "Gatorade",
-						Price:            "2.25",
+						Price:            MustParseMoney("2.25"),
 					},
 					{
 						ShortDescription: "Gatorade",
-						Price:            "2.25",
+						Price:            MustParseMoney("2.25"),
 					},
 					{
 						ShortDescription: "Gatorade",
-						Price:            "2.25",
+						Price:            MustParseMoney("2.25"),
 					},
 					{
 						ShortDescription: "Gatorade",
-						Price:            "2.25",
+						Price:            MustParseMoney("2.25"),
 					},
 					{
 						ShortDescription: "Gatorade",
-						Price:            "2.25",
+						Price:            MustParseMoney("2.25"),
 					},
 				},
 			},
@@ -173,8 +245,8 @@ func TestReceipt_scoreItems(t *testing.T) {
 			r := Receipt{
 				Items: tt.fields.Items,
 			}
-			if got := r.scoreItems(); got != tt.want {
-				t.Errorf("scoreItems() = %v, want %v", got, tt.want)
+			if got := r.scoreItems(DefaultConfig().ItemPairs); got.Points != tt.want {
+				t.Errorf("scoreItems() = %v, want %v", got.Points, tt.want)
 			}
 		})
 	}
@@ -211,8 +283,8 @@ func TestReceipt_scoreRetailer(t *testing.T) {
 			r := Receipt{
 				Retailer: tt.fields.Retailer,
 			}
-			if got := r.scoreRetailer(); got != tt.want {
-				t.Errorf("scoreRetailer() = %v, want %v", got, tt.want)
+			if got := r.scoreRetailer(DefaultConfig().Retailer); got.Points != tt.want {
+				t.Errorf("scoreRetailer() = %v, want %v", got.Points, tt.want)
 			}
 		})
 	}
@@ -220,7 +292,7 @@ func TestReceipt_scoreRetailer(t *testing.T) {
 
 func TestReceipt_scoreTotal(t *testing.T) {
 	type fields struct {
-		Total string
+		Total Money
 	}
 	tests := []struct {
 		name   string
@@ -230,21 +302,21 @@ func TestReceipt_scoreTotal(t *testing.T) {
 		{
 			name: "total not divisible by 0.25 and not round dollar amount",
 			fields: fields{
-				Total: "35.35",
+				Total: MustParseMoney("35.35"),
 			},
 			want: 0,
 		},
 		{
 			name: "total is divisible by 0.25 and round dollar amount",
 			fields: fields{
-				Total: "9.00",
+				Total: MustParseMoney("9.00"),
 			},
 			want: 75,
 		},
 		{
 			name: "total is divisible by 0.25 and not round dollar amount",
 			fields: fields{
-				Total: "2.50",
+				Total: MustParseMoney("2.50"),
 			},
 			want: 25,
 		},
@@ -255,21 +327,22 @@ func TestReceipt_scoreTotal(t *testing.T) {
 			r := Receipt{
 				Total: tt.fields.Total,
 			}
-			if got, _ := r.scoreTotal(); got != tt.want {
-				t.Errorf("scoreTotal() = %v, want %v", got, tt.want)
+			if got, _ := r.scoreTotal(DefaultConfig().Total); sumPoints(got) != tt.want {
+				t.Errorf("scoreTotal() = %v, want %v", sumPoints(got), tt.want)
 			}
 		})
 	}
 }
 
 func TestValidateReceipt(t *testing.T) {
-	validate := validator.New()
+	validate := NewValidator()
 
 	tests := []struct {
-		name           string
-		input          Receipt
-		expectedErrors bool
-		expectedErr    string
+		name             string
+		input            Receipt
+		expectedErrors   bool
+		expectedErr      string
+		expectedPointers []string
 	}{
 		{
 			name: "Valid receipt",
@@ -278,9 +351,9 @@ func TestValidateReceipt(t *testing.T) {
 				PurchaseDate: purchaseDate(time.Date(2024, 11, 26, 0, 0, 0, 0, time.UTC)),
 				PurchaseTime: purchaseTime(time.Date(0, 1, 1, 15, 30, 0, 0, time.UTC)),
 				Items: []Item{
-					{ShortDescription: "Item A", Price: "10.00"},
+					{ShortDescription: "Item A", Price: MustParseMoney("10.00")},
 				},
-				Total: "10.00",
+				Total: MustParseMoney("10.00"),
 			},
 			expectedErrors: false,
 			expectedErr:    "",
@@ -292,10 +365,11 @@ func TestValidateReceipt(t *testing.T) {
 				PurchaseDate: purchaseDate{},
 				PurchaseTime: purchaseTime{},
 				Items:        nil,
-				Total:        "",
+				Total:        Money{},
 			},
-			expectedErrors: true,
-			expectedErr:    "validation errors for the following fields: Retailer, PurchaseDate, PurchaseTime, Items, Total, ",
+			expectedErrors:   true,
+			expectedErr:      "validation errors for the following fields: Retailer, PurchaseDate, PurchaseTime, Items, Total, ",
+			expectedPointers: []string{"/retailer", "/purchaseDate", "/purchaseTime", "/items", "/total"},
 		},
 		{
 			name: "Invalid nested item validation",
@@ -304,27 +378,28 @@ func TestValidateReceipt(t *testing.T) {
 				PurchaseDate: purchaseDate(time.Date(2024, 11, 26, 0, 0, 0, 0, time.UTC)),
 				PurchaseTime: purchaseTime(time.Date(0, 1, 1, 15, 30, 0, 0, time.UTC)),
 				Items: []Item{
-					{ShortDescription: "", Price: "10.00"},  // Missing ShortDescription
-					{ShortDescription: "Item B", Price: ""}, // Missing Price
+					{ShortDescription: "", Price: MustParseMoney("10.00")}, // Missing ShortDescription
+					{ShortDescription: "Item B", Price: Money{}},           // Missing Price
 				},
-				Total: "20.00",
+				Total: MustParseMoney("20.00"),
 			},
-			expectedErrors: true,
-			expectedErr:    "validation errors for the following fields: ShortDescription, Price, ",
+			expectedErrors:   true,
+			expectedErr:      "validation errors for the following fields: ShortDescription, Price, ",
+			expectedPointers: []string{"/items/0/shortDescription", "/items/1/price"},
 		},
 		{
-			name: "Non-numeric total",
+			name: "Total that was never set",
 			input: Receipt{
 				Retailer:     "Store C",
 				PurchaseDate: purchaseDate(time.Date(2024, 11, 26, 0, 0, 0, 0, time.UTC)),
 				PurchaseTime: purchaseTime(time.Date(0, 1, 1, 15, 30, 0, 0, time.UTC)),
 				Items: []Item{
-					{ShortDescription: "Item C", Price: "10.00"},
+					{ShortDescription: "Item C", Price: MustParseMoney("10.00")},
 				},
-				Total: "abc",
 			},
-			expectedErrors: true,
-			expectedErr:    "validation errors for the following fields: Total, ",
+			expectedErrors:   true,
+			expectedErr:      "validation errors for the following fields: Total, ",
+			expectedPointers: []string{"/total"},
 		},
 	}
 
@@ -339,6 +414,19 @@ func TestValidateReceipt(t *testing.T) {
 				if err == nil || err.Error() != tt.expectedErr {
 					t.Errorf("expected error %q but got %q", tt.expectedErr, err)
 				}
+
+				var validationErr *ValidationError
+				if !errors.As(err, &validationErr) {
+					t.Fatalf("expected a *ValidationError but got %T", err)
+				}
+
+				var pointers []string
+				for _, field := range validationErr.Fields {
+					pointers = append(pointers, field.Pointer)
+				}
+				if !reflect.DeepEqual(pointers, tt.expectedPointers) {
+					t.Errorf("expected pointers %v but got %v", tt.expectedPointers, pointers)
+				}
 			} else {
 				if validationErrors != nil {
 					t.Errorf("expected no validation errors but got %v", validationErrors)
@@ -350,3 +438,135 @@ func TestValidateReceipt(t *testing.T) {
 		})
 	}
 }
+
+func TestReceipt_scoreTotalSubtotalBasis(t *testing.T) {
+	cfg := DefaultConfig().Total
+	cfg.Basis = TotalBasisSubtotal
+
+	subtotal := MustParseMoney("10.00")
+
+	tests := []struct {
+		name string
+		r    Receipt
+		want int
+	}{
+		{
+			name: "stated subtotal",
+			r:    Receipt{Total: MustParseMoney("10.83"), Subtotal: &subtotal},
+			want: 75,
+		},
+		{
+			name: "items less the discounts",
+			r: Receipt{
+				Total:     MustParseMoney("9.75"),
+				Items:     []Item{{ShortDescription: "Gatorade", Price: MustParseMoney("11.25")}},
+				Discounts: []Discount{{Description: "Store Coupon", Amount: MustParseMoney("1.50")}},
+			},
+			want: 25,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.r.scoreTotal(cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if sumPoints(got) != tt.want {
+				t.Errorf("scoreTotal() = %v, want %v", sumPoints(got), tt.want)
+			}
+
+			if _, ok := got[0].Inputs["subtotal"]; !ok {
+				t.Errorf("scoreTotal() inputs = %v, want the subtotal", got[0].Inputs)
+			}
+		})
+	}
+}
+
+func TestValidateReceipt_Lines(t *testing.T) {
+	validate := NewValidator()
+	money := func(s string) *Money {
+		m := MustParseMoney(s)
+		return &m
+	}
+
+	receiptWith := func(total string, subtotal, tax, tip *Money, discounts ...string) Receipt {
+		r := Receipt{
+			Retailer:     "Store A",
+			PurchaseDate: purchaseDate(time.Date(2024, 11, 26, 0, 0, 0, 0, time.UTC)),
+			PurchaseTime: purchaseTime(time.Date(0, 1, 1, 15, 30, 0, 0, time.UTC)),
+			Items: []Item{
+				{ShortDescription: "Item A", Price: MustParseMoney("6.00")},
+				{ShortDescription: "Item B", Price: MustParseMoney("4.00")},
+			},
+			Total:    MustParseMoney(total),
+			Subtotal: subtotal,
+			Tax:      tax,
+			Tip:      tip,
+		}
+		for _, d := range discounts {
+			r.Discounts = append(r.Discounts, Discount{Description: "Store Coupon", Amount: MustParseMoney(d)})
+		}
+
+		return r
+	}
+
+	tests := []struct {
+		name             string
+		input            Receipt
+		expectedPointers []string
+	}{
+		{
+			name:  "every line adds up",
+			input: receiptWith("10.30", money("9.00"), money("0.80"), money("0.50"), "1.00"),
+		},
+		{
+			name:  "no subtotal leaves the total to reconciliation",
+			input: receiptWith("12.00", nil, money("0.80"), nil),
+		},
+		{
+			name:             "subtotal is not the items less the discounts",
+			input:            receiptWith("10.00", money("10.00"), nil, nil, "1.00"),
+			expectedPointers: []string{"/subtotal"},
+		},
+		{
+			name:             "subtotal, tax and tip do not add up to the total",
+			input:            receiptWith("10.00", money("10.00"), money("0.80"), nil),
+			expectedPointers: []string{"/total"},
+		},
+		{
+			name:             "discounts over the items",
+			input:            receiptWith("0.00", nil, nil, nil, "6.00", "5.00"),
+			expectedPointers: []string{"/discounts"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.input.ValidateReceipt(validate)
+			if tt.expectedPointers == nil {
+				if err != nil {
+					t.Errorf("expected no error but got %q", err)
+				}
+				return
+			}
+
+			var validationErr *ValidationError
+			if !errors.As(err, &validationErr) {
+				t.Fatalf("expected a *ValidationError but got %T", err)
+			}
+
+			var pointers []string
+			for _, field := range validationErr.Fields {
+				if field.Constraint != ConstraintSum {
+					t.Errorf("expected the %s constraint but got %s", ConstraintSum, field.Constraint)
+				}
+				pointers = append(pointers, field.Pointer)
+			}
+			if !reflect.DeepEqual(pointers, tt.expectedPointers) {
+				t.Errorf("expected pointers %v but got %v", tt.expectedPointers, pointers)
+			}
+		})
+	}
+}
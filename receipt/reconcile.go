@@ -0,0 +1,85 @@
+package receipt
+
+import (
+	"math/big"
+)
+
+// ReconciliationStatus is how the lines of a receipt compare with
+// its total
+type ReconciliationStatus string
+
+const (
+	// ReconciliationConsistent is a total equal to the item prices
+	// less the discounts, plus the tax and tip
+	ReconciliationConsistent ReconciliationStatus = "consistent"
+	// ReconciliationExplainableWithTax is a receipt without a tax
+	// line whose total is over the lines by no more than the tax
+	// tolerance
+	ReconciliationExplainableWithTax ReconciliationStatus = "explainable-with-tax"
+	// ReconciliationInconsistent is any other total
+	ReconciliationInconsistent ReconciliationStatus = "inconsistent"
+)
+
+// InconsistentPolicy is what happens to an inconsistent receipt
+type InconsistentPolicy string
+
+const (
+	// PolicyReject refuses to store the receipt
+	PolicyReject InconsistentPolicy = "reject"
+	// PolicyFlag scores the receipt as usual and records it as
+	// inconsistent
+	PolicyFlag InconsistentPolicy = "flag"
+	// PolicyZero awards the receipt no points
+	PolicyZero InconsistentPolicy = "zero"
+)
+
+// Reconciliation is the result of comparing the lines of a receipt
+// with its total
+type Reconciliation struct {
+	Status     ReconciliationStatus `json:"status"`
+	ItemsTotal Money                `json:"itemsTotal"`
+	// Expected is what the items, discounts, tax and tip add up to
+	Expected Money `json:"expected"`
+	Total    Money `json:"total"`
+	// Policy is what was done with the receipt, only set when it is
+	// inconsistent
+	Policy InconsistentPolicy `json:"policy,omitempty"`
+}
+
+// Rejected reports whether the receipt must not be stored
+func (rec Reconciliation) Rejected() bool {
+	return rec.Policy == PolicyReject
+}
+
+// Reconcile sums the lines of the receipt exactly and compares the
+// sum with its total. Tax within the tolerance is only allowed for
+// when the receipt has no tax line
+func Reconcile(r Receipt, cfg ReconciliationConfig) Reconciliation {
+	subtotal := r.ItemsTotal().Minor() - r.DiscountsTotal().Minor()
+	expected := subtotal + lineMinor(r.Tax) + lineMinor(r.Tip)
+
+	rec := Reconciliation{
+		Status:     ReconciliationConsistent,
+		ItemsTotal: r.ItemsTotal(),
+		Expected:   r.money(expected),
+		Total:      r.Total,
+	}
+
+	diff := r.Total.Minor() - expected
+	if diff == 0 {
+		return rec
+	}
+
+	// the tax allowed is a share of the subtotal, compared in minor units
+	// so the tolerance is applied exactly
+	allowed := new(big.Rat).Mul(big.NewRat(subtotal, 1), decimalRat(cfg.TaxTolerance))
+	if r.Tax == nil && diff > 0 && big.NewRat(diff, 1).Cmp(allowed) <= 0 {
+		rec.Status = ReconciliationExplainableWithTax
+		return rec
+	}
+
+	rec.Status = ReconciliationInconsistent
+	rec.Policy = cfg.Policy
+
+	return rec
+}
@@ -0,0 +1,174 @@
+package receipt
+
+import (
+	"testing"
+)
+
+func reconcileReceipt(total string, prices ...string) Receipt {
+	r := Receipt{Total: MustParseMoney(total)}
+	for _, price := range prices {
+		r.Items = append(r.Items, Item{ShortDescription: "Gatorade", Price: MustParseMoney(price)})
+	}
+
+	return r
+}
+
+func TestReconcile(t *testing.T) {
+	cfg := ReconciliationConfig{TaxTolerance: 0.1, Policy: PolicyFlag}
+
+	tests := []struct {
+		name       string
+		r          Receipt
+		want       ReconciliationStatus
+		itemsTotal string
+	}{
+		{
+			name:       "items add up to the total",
+			r:          reconcileReceipt("35.35", "6.49", "12.25", "1.26", "3.35", "12.00"),
+			want:       ReconciliationConsistent,
+			itemsTotal: "35.35",
+		},
+		{
+			// floating point sums of these prices are not exact
+			name:       "sum is exact",
+			r:          reconcileReceipt("0.30", "0.10", "0.10", "0.10"),
+			want:       ReconciliationConsistent,
+			itemsTotal: "0.30",
+		},
+		{
+			name:       "tax within the tolerance",
+			r:          reconcileReceipt("11.00", "5.00", "5.00"),
+			want:       ReconciliationExplainableWithTax,
+			itemsTotal: "10.00",
+		},
+		{
+			name:       "tax over the tolerance by a cent",
+			r:          reconcileReceipt("11.01", "5.00", "5.00"),
+			want:       ReconciliationInconsistent,
+			itemsTotal: "10.00",
+		},
+		{
+			name:       "total under the items",
+			r:          reconcileReceipt("9.99", "5.00", "5.00"),
+			want:       ReconciliationInconsistent,
+			itemsTotal: "10.00",
+		},
+		{
+			name:       "no items",
+			r:          reconcileReceipt("1.00"),
+			want:       ReconciliationInconsistent,
+			itemsTotal: "0.00",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Reconcile(tt.r, cfg)
+
+			if got.Status != tt.want {
+				t.Errorf("Reconcile() status = %s, want %s", got.Status, tt.want)
+			}
+
+			if got.ItemsTotal.String() != tt.itemsTotal {
+				t.Errorf("Reconcile() items total = %s, want %s", got.ItemsTotal, tt.itemsTotal)
+			}
+
+			wantPolicy := InconsistentPolicy("")
+			if tt.want == ReconciliationInconsistent {
+				wantPolicy = cfg.Policy
+			}
+			if got.Policy != wantPolicy {
+				t.Errorf("Reconcile() policy = %q, want %q", got.Policy, wantPolicy)
+			}
+		})
+	}
+}
+
+func TestRuleSet_ScoreInconsistent(t *testing.T) {
+	r := reconcileReceipt("20.00", "5.00", "5.00")
+	flagged, err := DefaultRuleSet().Score(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		policy       InconsistentPolicy
+		wantPoints   int
+		wantRejected bool
+	}{
+		{policy: PolicyFlag, wantPoints: flagged.Points},
+		{policy: PolicyZero, wantPoints: 0},
+		{policy: PolicyReject, wantPoints: flagged.Points, wantRejected: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.policy), func(t *testing.T) {
+			cfg := DefaultConfig()
+			cfg.Reconciliation.Policy = tt.policy
+			rs, err := NewConfigRuleSet(cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := rs.Score(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got.Points != tt.wantPoints {
+				t.Errorf("Score() points = %d, want %d", got.Points, tt.wantPoints)
+			}
+
+			if got.Reconciliation == nil || got.Reconciliation.Status != ReconciliationInconsistent {
+				t.Fatalf("Score() reconciliation = %+v, want %s", got.Reconciliation, ReconciliationInconsistent)
+			}
+
+			if got.Reconciliation.Rejected() != tt.wantRejected {
+				t.Errorf("Rejected() = %t, want %t", got.Reconciliation.Rejected(), tt.wantRejected)
+			}
+
+			// the rules still explain the points the receipt would
+			// have been awarded
+			if sumPoints(got.Rules) != flagged.Points {
+				t.Errorf("Score() rules add up to %d, want %d", sumPoints(got.Rules), flagged.Points)
+			}
+		})
+	}
+}
+
+func TestReconcile_Lines(t *testing.T) {
+	cfg := ReconciliationConfig{TaxTolerance: 0.1, Policy: PolicyFlag}
+	tax := MustParseMoney("0.80")
+	tip := MustParseMoney("2.00")
+
+	discounted := reconcileReceipt("9.00", "5.00", "5.00")
+	discounted.Discounts = []Discount{{Description: "Store Coupon", Amount: MustParseMoney("1.00")}}
+
+	withTax := reconcileReceipt("12.80", "5.00", "5.00")
+	withTax.Tax, withTax.Tip = &tax, &tip
+
+	// a stated tax leaves nothing to allow for
+	underTaxed := reconcileReceipt("11.00", "5.00", "5.00")
+	underTaxed.Tax = &tax
+
+	tests := []struct {
+		name     string
+		r        Receipt
+		want     ReconciliationStatus
+		expected string
+	}{
+		{name: "discounts", r: discounted, want: ReconciliationConsistent, expected: "9.00"},
+		{name: "tax and tip", r: withTax, want: ReconciliationConsistent, expected: "12.80"},
+		{name: "total over the stated tax", r: underTaxed, want: ReconciliationInconsistent, expected: "10.80"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Reconcile(tt.r, cfg)
+
+			if got.Status != tt.want || got.Expected.String() != tt.expected {
+				t.Errorf("Reconcile() = %s expecting %s, want %s expecting %s", got.Status, got.Expected, tt.want, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,143 @@
+package receipt
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+var (
+	ErrDuplicateVersion = errors.New("rule set version already registered")
+	ErrUnknownVersion   = errors.New("unknown rule set version")
+	ErrBuiltinVersion   = errors.New("rules file changes a built-in rule set version")
+)
+
+// Registry keeps every version of the rules known to the
+// server and which one is used to score new receipts
+type Registry struct {
+	mu      sync.RWMutex
+	sets    map[string]*RuleSet
+	current string
+	// files is the rules file every loaded version was read from
+	files map[string]string
+}
+
+// NewRegistry creates a Registry with the rule set registered
+// and used as the current version
+func NewRegistry(current *RuleSet) *Registry {
+	return &Registry{
+		sets:    map[string]*RuleSet{current.Version(): current},
+		current: current.Version(),
+		files:   map[string]string{},
+	}
+}
+
+// NewDefaultRegistry creates a Registry with the built-in rules,
+// the legacy rules and the default rules, which are used as the
+// current version
+func NewDefaultRegistry() *Registry {
+	r := NewRegistry(DefaultRuleSet())
+	r.sets[LegacyVersion] = LegacyRuleSet()
+
+	return r
+}
+
+// Register adds a version of the rules to the registry
+func (r *Registry) Register(rs *RuleSet) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.sets[rs.Version()]; ok {
+		return fmt.Errorf("%w: %s", ErrDuplicateVersion, rs.Version())
+	}
+
+	r.sets[rs.Version()] = rs
+
+	return nil
+}
+
+// Load reads a rules file, see LoadConfig, and registers its rules.
+// A version always means the same values, so a rules file with the
+// version of rules that are already registered, such as the built-in
+// rules or a file that leaves out the version, is only accepted if
+// it has their values, and two files cannot have the same version
+func (r *Registry) Load(path string) (*RuleSet, error) {
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		return nil, err
+	}
+
+	rs, err := NewConfigRuleSet(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if other, ok := r.files[rs.Version()]; ok {
+		return nil, fmt.Errorf("%w: %s is also the version of %s", ErrDuplicateVersion, rs.Version(), other)
+	}
+
+	if registered, ok := r.sets[rs.Version()]; ok {
+		if registered.config == nil || *registered.config != cfg {
+			return nil, fmt.Errorf("%w: %s has version %s with different values, give it a new version", ErrBuiltinVersion, path, rs.Version())
+		}
+		rs = registered
+	}
+
+	r.sets[rs.Version()] = rs
+	r.files[rs.Version()] = path
+
+	return rs, nil
+}
+
+// SetCurrent changes the version used to score new receipts
+func (r *Registry) SetCurrent(version string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.sets[version]; !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownVersion, version)
+	}
+
+	r.current = version
+
+	return nil
+}
+
+// Current returns the rules used to score new receipts
+func (r *Registry) Current() *RuleSet {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.sets[r.current]
+}
+
+// Get returns the rules with the given version
+func (r *Registry) Get(version string) (*RuleSet, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	rs, ok := r.sets[version]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownVersion, version)
+	}
+
+	return rs, nil
+}
+
+// Versions returns every registered version in sorted order
+func (r *Registry) Versions() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	versions := make([]string, 0, len(r.sets))
+	for v := range r.sets {
+		versions = append(versions, v)
+	}
+	sort.Strings(versions)
+
+	return versions
+}
@@ -0,0 +1,210 @@
+package receipt
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRegistry(t *testing.T) {
+	reg := NewRegistry(DefaultRuleSet())
+
+	if got := reg.Current().Version(); got != DefaultVersion {
+		t.Errorf("Current() = %v, want %v", got, DefaultVersion)
+	}
+
+	cfg := DefaultConfig()
+	cfg.Version = "3"
+	cfg.PurchaseDay.OddDayPoints = 12
+	v3, err := NewConfigRuleSet(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := reg.Register(v3); err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+
+	if err := reg.Register(DefaultRuleSet()); !errors.Is(err, ErrDuplicateVersion) {
+		t.Errorf("Register() error = %v, want %v", err, ErrDuplicateVersion)
+	}
+
+	if err := reg.SetCurrent("4"); !errors.Is(err, ErrUnknownVersion) {
+		t.Errorf("SetCurrent() error = %v, want %v", err, ErrUnknownVersion)
+	}
+
+	if err := reg.SetCurrent("3"); err != nil {
+		t.Fatalf("SetCurrent() error = %v", err)
+	}
+
+	if got := reg.Current(); got != v3 {
+		t.Errorf("Current() = %v, want version 3", got.Version())
+	}
+
+	if got, err := reg.Get(DefaultVersion); err != nil || got.Version() != DefaultVersion {
+		t.Errorf("Get() = %v, %v, want version %v", got, err, DefaultVersion)
+	}
+
+	if _, err := reg.Get("4"); !errors.Is(err, ErrUnknownVersion) {
+		t.Errorf("Get() error = %v, want %v", err, ErrUnknownVersion)
+	}
+
+	if got := reg.Versions(); !reflect.DeepEqual(got, []string{"2", "3"}) {
+		t.Errorf("Versions() = %v, want %v", got, []string{"2", "3"})
+	}
+}
+
+func TestNewDefaultRegistry(t *testing.T) {
+	reg := NewDefaultRegistry()
+
+	if got := reg.Current().Version(); got != DefaultVersion {
+		t.Errorf("Current() = %v, want %v", got, DefaultVersion)
+	}
+
+	if got := reg.Versions(); !reflect.DeepEqual(got, []string{LegacyVersion, DefaultVersion}) {
+		t.Errorf("Versions() = %v, want %v", got, []string{LegacyVersion, DefaultVersion})
+	}
+
+	r := Receipt{
+		Retailer:     "Target",
+		PurchaseDate: purchaseDate(time.Date(2022, 3, 20, 0, 0, 0, 0, time.UTC)),
+		PurchaseTime: purchaseTime(time.Date(0, 1, 1, 16, 0, 0, 0, time.UTC)),
+		Items:        []Item{{ShortDescription: "Gatorade", Price: MustParseMoney("2.25")}},
+		Total:        MustParseMoney("2.25"),
+	}
+
+	tests := []struct {
+		version string
+		want    int
+	}{
+		// 6 retailer + 25 multiple, and 10 for the legacy window
+		{version: LegacyVersion, want: 41},
+		{version: DefaultVersion, want: 31},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.version, func(t *testing.T) {
+			rs, err := reg.Get(tt.version)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := rs.Score(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got.Points != tt.want || got.Version != tt.version {
+				t.Errorf("Score() = %d points with version %s, want %d with version %s", got.Points, got.Version, tt.want, tt.version)
+			}
+		})
+	}
+}
+
+func TestRegistry_Load(t *testing.T) {
+	newVersion := writeConfig(t, "v3.yml", "version: \"3\"\nretailer:\n  pointsPerCharacter: 2\n")
+
+	tests := []struct {
+		name          string
+		paths         []string
+		expectVersion string
+		expectRules   *RuleSet
+		expectErr     error
+	}{
+		{
+			name:          "example with the values of the built-in rules",
+			paths:         []string{"../examples/rules.yml"},
+			expectVersion: DefaultVersion,
+			expectRules:   DefaultRuleSet(),
+		},
+		{
+			name:          "file with the legacy version and its values",
+			paths:         []string{writeConfig(t, "legacy.yml", "version: \"1\"\n")},
+			expectVersion: LegacyVersion,
+			expectRules:   LegacyRuleSet(),
+		},
+		{
+			name:          "file with a new version",
+			paths:         []string{newVersion},
+			expectVersion: "3",
+			expectRules: func() *RuleSet {
+				cfg := DefaultConfig()
+				cfg.Version = "3"
+				cfg.Retailer.PointsPerCharacter = 2
+				return configRuleSet(cfg, nil)
+			}(),
+		},
+		{
+			name:      "file without a version changes the built-in rules",
+			paths:     []string{writeConfig(t, "default.yml", "retailer:\n  pointsPerCharacter: 2\n")},
+			expectErr: ErrBuiltinVersion,
+		},
+		{
+			name:      "file with the legacy version changes the legacy rules",
+			paths:     []string{writeConfig(t, "legacy.yml", "version: \"1\"\nretailer:\n  pointsPerCharacter: 2\n")},
+			expectErr: ErrBuiltinVersion,
+		},
+		{
+			name:      "two files with the same version",
+			paths:     []string{newVersion, newVersion},
+			expectErr: ErrDuplicateVersion,
+		},
+		{
+			name:      "example loaded twice",
+			paths:     []string{"../examples/rules.yml", "../examples/rules.yml"},
+			expectErr: ErrDuplicateVersion,
+		},
+	}
+
+	// only the legacy window awards points at 16:30
+	r := Receipt{
+		Retailer:     "M&M Corner Market",
+		PurchaseDate: purchaseDate(time.Date(2022, 3, 20, 0, 0, 0, 0, time.UTC)),
+		PurchaseTime: purchaseTime(time.Date(0, 1, 1, 16, 30, 0, 0, time.UTC)),
+		Items:        []Item{{ShortDescription: "Gatorade", Price: MustParseMoney("2.25")}},
+		Total:        MustParseMoney("2.25"),
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reg := NewDefaultRegistry()
+
+			var err error
+			for _, path := range tt.paths {
+				if _, err = reg.Load(path); err != nil {
+					break
+				}
+			}
+
+			if tt.expectErr != nil {
+				if !errors.Is(err, tt.expectErr) {
+					t.Errorf("Load() error = %v, want %v", err, tt.expectErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+
+			got, err := reg.Get(tt.expectVersion)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			gotBreakdown, err := got.Score(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			wantBreakdown, err := tt.expectRules.Score(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(gotBreakdown, wantBreakdown) {
+				t.Errorf("Score() = %+v, want %+v", gotBreakdown, wantBreakdown)
+			}
+		})
+	}
+}
@@ -0,0 +1,308 @@
+package receipt
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+var (
+	ErrDuplicateRule = errors.New("rule already exists")
+	ErrRuleNotFound  = errors.New("rule not found")
+)
+
+// RuleTotal is the ID of the rule that scores the receipt total. It
+// reports a RuleTotalRoundDollar and a RuleTotalMultiple result
+const RuleTotal = "total"
+
+// Rule is a single scoring rule that can be registered
+// in a RuleSet
+type Rule interface {
+	// ID uniquely identifies the rule within a RuleSet
+	ID() string
+	// Score applies the rule to the receipt. A rule may
+	// report more than one result
+	Score(Receipt) ([]RuleResult, error)
+}
+
+// NewRule creates a Rule from a function
+func NewRule(id string, fn func(Receipt) ([]RuleResult, error)) Rule {
+	return funcRule{id: id, fn: fn}
+}
+
+type funcRule struct {
+	id string
+	fn func(Receipt) ([]RuleResult, error)
+}
+
+func (f funcRule) ID() string {
+	return f.id
+}
+
+func (f funcRule) Score(r Receipt) ([]RuleResult, error) {
+	return f.fn(r)
+}
+
+// RuleSet is an ordered registry of rules used to score
+// receipts. A RuleSet should not be modified while it is
+// being used to score receipts
+type RuleSet struct {
+	version        string
+	rules          []Rule
+	reconciliation ReconciliationConfig
+	exchange       exchange
+	strict         bool
+	// config is the config the rules were created from, nil for
+	// rules created with NewRuleSet
+	config *Config
+}
+
+// NewRuleSet creates a RuleSet with the rules in the
+// order given. The version is recorded on every breakdown
+// the RuleSet produces. Receipts are reconciled with the
+// default config, and only receipts in DefaultCurrency can be
+// scored
+func NewRuleSet(version string, rules ...Rule) (*RuleSet, error) {
+	if version == "" {
+		return nil, errors.New("rule set version is required")
+	}
+
+	rs := &RuleSet{
+		version:        version,
+		reconciliation: DefaultConfig().Reconciliation,
+		exchange:       exchange{base: DefaultCurrency},
+	}
+	for _, rule := range rules {
+		if err := rs.Add(rule); err != nil {
+			return nil, err
+		}
+	}
+
+	return rs, nil
+}
+
+// DefaultRuleSet returns a RuleSet with the rules described
+// in the README
+func DefaultRuleSet() *RuleSet {
+	return configRuleSet(DefaultConfig(), nil)
+}
+
+// LegacyRuleSet returns a RuleSet with the first version of the
+// rules, see LegacyConfig
+func LegacyRuleSet() *RuleSet {
+	return configRuleSet(LegacyConfig(), nil)
+}
+
+// configRuleSet creates the default rules using the values
+// of an already validated config and its exchange rates
+func configRuleSet(cfg Config, rates *ExchangeRates) *RuleSet {
+	return &RuleSet{
+		version:        cfg.Version,
+		reconciliation: cfg.Reconciliation,
+		exchange:       exchange{base: cfg.Currency.Base, rates: rates},
+		strict:         cfg.Strict,
+		config:         &cfg,
+		rules: []Rule{
+			NewRule(RuleRetailer, func(r Receipt) ([]RuleResult, error) {
+				return []RuleResult{r.scoreRetailer(cfg.Retailer)}, nil
+			}),
+			NewRule(RuleTotal, func(r Receipt) ([]RuleResult, error) {
+				results, err := r.scoreTotal(cfg.Total)
+				if err != nil {
+					return skipInvalid(cfg.Strict, err, RuleTotalRoundDollar, RuleTotalMultiple)
+				}
+
+				return results, nil
+			}),
+			NewRule(RuleItemPairs, func(r Receipt) ([]RuleResult, error) {
+				return []RuleResult{r.scoreItems(cfg.ItemPairs)}, nil
+			}),
+			NewRule(RuleItemDescription, func(r Receipt) ([]RuleResult, error) {
+				results := make([]RuleResult, 0, len(r.Items))
+				for idx, i := range r.Items {
+					if err := i.checkAmounts(idx); err != nil {
+						skipped, err := skipInvalid(cfg.Strict, err, RuleItemDescription)
+						if err != nil {
+							return nil, err
+						}
+						skipped[0].Inputs["item"] = strconv.Itoa(idx)
+						results = append(results, skipped...)
+						continue
+					}
+
+					result := i.scoreDescription(cfg.ItemDescription)
+					result.Inputs["item"] = strconv.Itoa(idx)
+					results = append(results, result)
+				}
+
+				return results, nil
+			}),
+			NewRule(RulePurchaseDayOdd, func(r Receipt) ([]RuleResult, error) {
+				return r.scoreLocal(func(local time.Time) RuleResult {
+					return purchaseDate(local).scoreDay(cfg.PurchaseDay)
+				})
+			}),
+			NewRule(RulePurchaseTime, func(r Receipt) ([]RuleResult, error) {
+				return r.scoreLocal(func(local time.Time) RuleResult {
+					return purchaseTime(local).scoreTime(cfg.PurchaseTime)
+				})
+			}),
+		},
+	}
+}
+
+// Version returns the version of the RuleSet
+func (rs *RuleSet) Version() string {
+	return rs.version
+}
+
+// Rules returns the rules in the order they are applied
+func (rs *RuleSet) Rules() []Rule {
+	rules := make([]Rule, len(rs.rules))
+	copy(rules, rs.rules)
+
+	return rules
+}
+
+// Add appends the rule to the end of the set
+func (rs *RuleSet) Add(rule Rule) error {
+	return rs.insert(len(rs.rules), rule)
+}
+
+// InsertBefore adds the rule in front of the rule with
+// the given ID
+func (rs *RuleSet) InsertBefore(id string, rule Rule) error {
+	idx := rs.index(id)
+	if idx < 0 {
+		return fmt.Errorf("%w: %s", ErrRuleNotFound, id)
+	}
+
+	return rs.insert(idx, rule)
+}
+
+// InsertAfter adds the rule after the rule with the
+// given ID
+func (rs *RuleSet) InsertAfter(id string, rule Rule) error {
+	idx := rs.index(id)
+	if idx < 0 {
+		return fmt.Errorf("%w: %s", ErrRuleNotFound, id)
+	}
+
+	return rs.insert(idx+1, rule)
+}
+
+// Remove removes the rule with the given ID from the set
+func (rs *RuleSet) Remove(id string) error {
+	idx := rs.index(id)
+	if idx < 0 {
+		return fmt.Errorf("%w: %s", ErrRuleNotFound, id)
+	}
+
+	rs.rules = append(rs.rules[:idx], rs.rules[idx+1:]...)
+
+	return nil
+}
+
+// Move moves the rule with the given ID to position pos,
+// shifting the rules after it
+func (rs *RuleSet) Move(id string, pos int) error {
+	idx := rs.index(id)
+	if idx < 0 {
+		return fmt.Errorf("%w: %s", ErrRuleNotFound, id)
+	}
+
+	if pos < 0 || pos >= len(rs.rules) {
+		return fmt.Errorf("position %d is out of range", pos)
+	}
+
+	rule := rs.rules[idx]
+	rs.rules = append(rs.rules[:idx], rs.rules[idx+1:]...)
+	rs.rules = append(rs.rules[:pos], append([]Rule{rule}, rs.rules[pos:]...)...)
+
+	return nil
+}
+
+// Score applies every rule in order and returns the
+// breakdown of the points awarded, along with how the
+// items reconcile with the total. The rules score the
+// receipt converted to the base currency, while the items
+// are reconciled in the currency of the receipt. An
+// inconsistent receipt is awarded no points under PolicyZero.
+// A receipt a rule cannot score returns a *ScoringError, other
+// errors of the rules are returned as they are
+func (rs *RuleSet) Score(r Receipt) (Breakdown, error) {
+	normalized, exchange, err := rs.exchange.normalize(r)
+	if err != nil {
+		return Breakdown{}, err
+	}
+
+	var results []RuleResult
+	for _, rule := range rs.rules {
+		res, err := rule.Score(normalized)
+		if err != nil {
+			return Breakdown{}, ruleError(rule.ID(), err)
+		}
+		results = append(results, res...)
+	}
+
+	// strict rules do not reconcile lines that could not be parsed
+	if rs.strict {
+		if err := r.checkLines(); err != nil {
+			return Breakdown{}, ruleError(RuleReconciliation, err)
+		}
+	}
+
+	reconciliation := Reconcile(r, rs.reconciliation)
+	points := sumPoints(results)
+	if reconciliation.Policy == PolicyZero {
+		points = 0
+	}
+
+	return Breakdown{
+		Points:         points,
+		Version:        rs.version,
+		Rules:          results,
+		Reconciliation: &reconciliation,
+		Exchange:       exchange,
+	}, nil
+}
+
+// ruleError returns the error of a rule as a ScoringError for the
+// rule if the receipt could not be scored, a ScoringError or an
+// invalid amount. Any other error is returned as it is, since it is
+// not caused by the receipt
+func ruleError(id string, err error) error {
+	var scoringErr *ScoringError
+	switch {
+	case errors.As(err, &scoringErr):
+		if scoringErr.Rule == "" {
+			scoringErr.Rule = id
+		}
+		return scoringErr
+	case errors.Is(err, ErrInvalidMoney):
+		return &ScoringError{Rule: id, Item: -1, Err: err}
+	default:
+		return err
+	}
+}
+
+func (rs *RuleSet) insert(pos int, rule Rule) error {
+	if rs.index(rule.ID()) >= 0 {
+		return fmt.Errorf("%w: %s", ErrDuplicateRule, rule.ID())
+	}
+
+	rs.rules = append(rs.rules[:pos], append([]Rule{rule}, rs.rules[pos:]...)...)
+
+	return nil
+}
+
+func (rs *RuleSet) index(id string) int {
+	for i, rule := range rs.rules {
+		if rule.ID() == id {
+			return i
+		}
+	}
+
+	return -1
+}
@@ -0,0 +1,154 @@
+package receipt
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func ruleIDs(rs *RuleSet) []string {
+	var ids []string
+	for _, r := range rs.Rules() {
+		ids = append(ids, r.ID())
+	}
+
+	return ids
+}
+
+func TestDefaultRuleSet_Score(t *testing.T) {
+	targetPurchaseDate, _ := time.Parse(time.DateOnly, "2022-01-01")
+	targetPurchaseTime, _ := time.Parse(timeOnly, "13:01")
+
+	r := Receipt{
+		Retailer:     "Target",
+		PurchaseDate: purchaseDate(targetPurchaseDate),
+		PurchaseTime: purchaseTime(targetPurchaseTime),
+		Items: []Item{
+			{ShortDescription: "Mountain Dew 12PK", Price: MustParseMoney("6.49")},
+			{ShortDescription: "Emils Cheese Pizza", Price: MustParseMoney("12.25")},
+			{ShortDescription: "Knorr Creamy Chicken", Price: MustParseMoney("1.26")},
+			{ShortDescription: "Doritos Nacho Cheese", Price: MustParseMoney("3.35")},
+			{ShortDescription: "   Klarbrunn 12-PK 12 FL OZ  ", Price: MustParseMoney("12.00")},
+		},
+		Total: MustParseMoney("35.35"),
+	}
+
+	got, err := DefaultRuleSet().Score(r)
+	if err != nil {
+		t.Fatalf("Score() error = %v", err)
+	}
+
+	if got.Points != 28 {
+		t.Errorf("Score() = %v, want %v", got.Points, 28)
+	}
+
+	if got.Version != DefaultVersion {
+		t.Errorf("Score() version = %v, want %v", got.Version, DefaultVersion)
+	}
+
+	want := []string{RuleRetailer, RuleTotal, RuleItemPairs, RuleItemDescription, RulePurchaseDayOdd, RulePurchaseTime}
+	if ids := ruleIDs(DefaultRuleSet()); !reflect.DeepEqual(ids, want) {
+		t.Errorf("DefaultRuleSet() rules = %v, want %v", ids, want)
+	}
+}
+
+func TestRuleSet_Modify(t *testing.T) {
+	bonus := NewRule("bonus", func(r Receipt) ([]RuleResult, error) {
+		return []RuleResult{{Rule: "bonus", Points: 100}}, nil
+	})
+
+	tests := []struct {
+		name    string
+		modify  func(rs *RuleSet) error
+		want    []string
+		wantErr error
+	}{
+		{
+			name:   "add appends the rule",
+			modify: func(rs *RuleSet) error { return rs.Add(bonus) },
+			want:   []string{RuleRetailer, RuleTotal, RuleItemPairs, RuleItemDescription, RulePurchaseDayOdd, RulePurchaseTime, "bonus"},
+		},
+		{
+			name:    "add rejects duplicate ids",
+			modify:  func(rs *RuleSet) error { return rs.Add(NewRule(RuleTotal, nil)) },
+			want:    []string{RuleRetailer, RuleTotal, RuleItemPairs, RuleItemDescription, RulePurchaseDayOdd, RulePurchaseTime},
+			wantErr: ErrDuplicateRule,
+		},
+		{
+			name:   "insert before",
+			modify: func(rs *RuleSet) error { return rs.InsertBefore(RuleTotal, bonus) },
+			want:   []string{RuleRetailer, "bonus", RuleTotal, RuleItemPairs, RuleItemDescription, RulePurchaseDayOdd, RulePurchaseTime},
+		},
+		{
+			name:   "insert after",
+			modify: func(rs *RuleSet) error { return rs.InsertAfter(RulePurchaseTime, bonus) },
+			want:   []string{RuleRetailer, RuleTotal, RuleItemPairs, RuleItemDescription, RulePurchaseDayOdd, RulePurchaseTime, "bonus"},
+		},
+		{
+			name:   "remove",
+			modify: func(rs *RuleSet) error { return rs.Remove(RuleItemPairs) },
+			want:   []string{RuleRetailer, RuleTotal, RuleItemDescription, RulePurchaseDayOdd, RulePurchaseTime},
+		},
+		{
+			name:    "remove unknown rule",
+			modify:  func(rs *RuleSet) error { return rs.Remove("unknown") },
+			want:    []string{RuleRetailer, RuleTotal, RuleItemPairs, RuleItemDescription, RulePurchaseDayOdd, RulePurchaseTime},
+			wantErr: ErrRuleNotFound,
+		},
+		{
+			name:   "move to front",
+			modify: func(rs *RuleSet) error { return rs.Move(RulePurchaseTime, 0) },
+			want:   []string{RulePurchaseTime, RuleRetailer, RuleTotal, RuleItemPairs, RuleItemDescription, RulePurchaseDayOdd},
+		},
+		{
+			name:   "move to back",
+			modify: func(rs *RuleSet) error { return rs.Move(RuleRetailer, 5) },
+			want:   []string{RuleTotal, RuleItemPairs, RuleItemDescription, RulePurchaseDayOdd, RulePurchaseTime, RuleRetailer},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rs := DefaultRuleSet()
+			if err := tt.modify(rs); !errors.Is(err, tt.wantErr) {
+				t.Errorf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if got := ruleIDs(rs); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("rules = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRuleSet_ScoreCustomRules(t *testing.T) {
+	rs, err := NewRuleSet("custom",
+		NewRule("flat", func(r Receipt) ([]RuleResult, error) {
+			return []RuleResult{{Rule: "flat", Points: 7}}, nil
+		}),
+		NewRule("per-item", func(r Receipt) ([]RuleResult, error) {
+			return []RuleResult{{Rule: "per-item", Points: len(r.Items)}}, nil
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := rs.Score(Receipt{Items: []Item{{}, {}, {}}})
+	if err != nil {
+		t.Fatalf("Score() error = %v", err)
+	}
+
+	if got.Points != 10 {
+		t.Errorf("Score() = %v, want %v", got.Points, 10)
+	}
+
+	failing := errors.New("boom")
+	rs, _ = NewRuleSet("failing", NewRule("failing", func(r Receipt) ([]RuleResult, error) {
+		return nil, failing
+	}))
+	if _, err := rs.Score(Receipt{}); !errors.Is(err, failing) {
+		t.Errorf("Score() error = %v, want %v", err, failing)
+	}
+}
@@ -0,0 +1,144 @@
+package receipt
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// RuleReconciliation is the Rule of a ScoringError for a line of a
+// receipt that could not be reconciled with the total
+const RuleReconciliation = "reconciliation"
+
+// ConstraintScore is reported for a field a rule could not score
+// for any reason other than an invalid amount
+const ConstraintScore = "score"
+
+// ScoringError is a receipt that could not be scored, along with
+// the rule and the field it failed on
+type ScoringError struct {
+	// Rule is the ID of the rule, or RuleReconciliation
+	Rule string
+	// Pointer is the JSON pointer to the field, e.g. /items/2/price,
+	// empty if the rule did not fail on a single field
+	Pointer string
+	// Item is the index of the item with the field, -1 if the field
+	// is not on an item
+	Item int
+	Err  error
+}
+
+func (e *ScoringError) Error() string {
+	if e.Pointer == "" {
+		return fmt.Sprintf("rule %s: %v", e.Rule, e.Err)
+	}
+
+	return fmt.Sprintf("rule %s: %s: %v", e.Rule, e.Pointer, e.Err)
+}
+
+func (e *ScoringError) Unwrap() error {
+	return e.Err
+}
+
+// FieldError returns the field the rule failed on, reported with
+// ConstraintAmount for an invalid amount. It is false if the rule
+// did not fail on a single field
+func (e *ScoringError) FieldError() (FieldError, bool) {
+	if e.Pointer == "" {
+		return FieldError{}, false
+	}
+
+	constraint := ConstraintScore
+	if errors.Is(e.Err, ErrInvalidMoney) {
+		constraint = ConstraintAmount
+	}
+
+	return FieldError{Pointer: e.Pointer, Constraint: constraint}, true
+}
+
+// checkAmount returns a ScoringError for an amount that could not be
+// parsed. The rule is filled in by RuleSet.Score
+func checkAmount(pointer string, item int, m Money) error {
+	if m.Valid() {
+		return nil
+	}
+
+	return &ScoringError{Pointer: pointer, Item: item, Err: ErrInvalidMoney}
+}
+
+// checkAmounts returns a ScoringError for the first amount of the
+// item that could not be parsed
+func (i Item) checkAmounts(idx int) error {
+	pointer := "/items/" + strconv.Itoa(idx)
+	if err := checkAmount(pointer+"/price", idx, i.Price); err != nil {
+		return err
+	}
+
+	if i.UnitPrice != nil {
+		return checkAmount(pointer+"/unitPrice", idx, *i.UnitPrice)
+	}
+
+	return nil
+}
+
+// checkLines returns a ScoringError for the first line reconciled
+// with the total that could not be parsed
+func (r Receipt) checkLines() error {
+	if err := r.checkSubtotal(); err != nil {
+		return err
+	}
+
+	lines := []struct {
+		pointer string
+		amount  *Money
+	}{{"/total", &r.Total}, {"/subtotal", r.Subtotal}, {"/tax", r.Tax}, {"/tip", r.Tip}}
+	for _, line := range lines {
+		if line.amount == nil {
+			continue
+		}
+		if err := checkAmount(line.pointer, -1, *line.amount); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// checkSubtotal returns a ScoringError for the first item or
+// discount that could not be parsed
+func (r Receipt) checkSubtotal() error {
+	for idx, item := range r.Items {
+		if err := item.checkAmounts(idx); err != nil {
+			return err
+		}
+	}
+
+	for idx, d := range r.Discounts {
+		if err := checkAmount(fmt.Sprintf("/discounts/%d/amount", idx), -1, d.Amount); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// skipInvalid awards no points for a field a rule could not score,
+// with a result for every result the rule gives. Strict rules, and
+// errors that are not a ScoringError, return the error instead
+func skipInvalid(strict bool, err error, rules ...string) ([]RuleResult, error) {
+	var scoringErr *ScoringError
+	if strict || !errors.As(err, &scoringErr) {
+		return nil, err
+	}
+
+	results := make([]RuleResult, 0, len(rules))
+	for _, rule := range rules {
+		results = append(results, RuleResult{
+			Rule:        rule,
+			Description: fmt.Sprintf("%s cannot be scored: %v", scoringErr.Pointer, scoringErr.Err),
+			Inputs:      map[string]string{},
+		})
+	}
+
+	return results, nil
+}
@@ -0,0 +1,178 @@
+package receipt
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRuleSet_ScoreInvalidAmounts(t *testing.T) {
+	receiptWith := func(change func(r *Receipt)) Receipt {
+		r := Receipt{
+			Retailer:     "Target",
+			PurchaseDate: purchaseDate(time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)),
+			PurchaseTime: purchaseTime(time.Date(0, 1, 1, 13, 1, 0, 0, time.UTC)),
+			Items: []Item{
+				{ShortDescription: "Emils Cheese Pizza", Price: MustParseMoney("12.25")},
+				{ShortDescription: "Mountain Dew 12PK", Price: MustParseMoney("6.49")},
+			},
+			Total: MustParseMoney("18.74"),
+		}
+		change(&r)
+		return r
+	}
+
+	tests := []struct {
+		name        string
+		r           Receipt
+		wantRule    string
+		wantPointer string
+		wantItem    int
+		// wantSkipped is the prefix of the rules awarding no points
+		// when the rules are not strict, empty if they score the
+		// receipt as usual
+		wantSkipped string
+	}{
+		{
+			name:        "item price",
+			r:           receiptWith(func(r *Receipt) { r.Items[1].Price = Money{} }),
+			wantRule:    RuleItemDescription,
+			wantPointer: "/items/1/price",
+			wantItem:    1,
+			wantSkipped: RuleItemDescription,
+		},
+		{
+			name: "item unit price",
+			r: receiptWith(func(r *Receipt) {
+				r.Items[0].UnitPrice = &Money{}
+			}),
+			wantRule:    RuleItemDescription,
+			wantPointer: "/items/0/unitPrice",
+			wantItem:    0,
+			wantSkipped: RuleItemDescription,
+		},
+		{
+			name:        "total",
+			r:           receiptWith(func(r *Receipt) { r.Total = Money{} }),
+			wantRule:    RuleTotal,
+			wantPointer: "/total",
+			wantItem:    -1,
+			wantSkipped: RuleTotal,
+		},
+		{
+			name:        "tax",
+			r:           receiptWith(func(r *Receipt) { r.Tax = &Money{} }),
+			wantRule:    RuleReconciliation,
+			wantPointer: "/tax",
+			wantItem:    -1,
+		},
+	}
+
+	strictCfg := DefaultConfig()
+	strictCfg.Strict = true
+	strict := configRuleSet(strictCfg, nil)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := strict.Score(tt.r)
+
+			var scoringErr *ScoringError
+			if !errors.As(err, &scoringErr) {
+				t.Fatalf("strict Score() error = %v, want a %T", err, scoringErr)
+			}
+			if scoringErr.Rule != tt.wantRule || scoringErr.Pointer != tt.wantPointer || scoringErr.Item != tt.wantItem {
+				t.Errorf("strict Score() error = %+v, want rule %s at %s on item %d", scoringErr, tt.wantRule, tt.wantPointer, tt.wantItem)
+			}
+			if !errors.Is(err, ErrInvalidMoney) {
+				t.Errorf("strict Score() error = %v, want %v", err, ErrInvalidMoney)
+			}
+			if field, ok := scoringErr.FieldError(); !ok || field.Pointer != tt.wantPointer || field.Constraint != ConstraintAmount {
+				t.Errorf("FieldError() = %+v, want %s with the %s constraint", field, tt.wantPointer, ConstraintAmount)
+			}
+
+			breakdown, err := DefaultRuleSet().Score(tt.r)
+			if err != nil {
+				t.Fatalf("Score() error = %v", err)
+			}
+
+			skipped := false
+			for _, result := range breakdown.Rules {
+				if strings.Contains(result.Description, "cannot be scored") {
+					skipped = true
+					if !strings.HasPrefix(result.Rule, tt.wantSkipped) || result.Points != 0 {
+						t.Errorf("Score() skipped %s with %d points, want %s with none", result.Rule, result.Points, tt.wantSkipped)
+					}
+				}
+			}
+			if skipped != (tt.wantSkipped != "") {
+				t.Errorf("Score() skipped a rule = %v, want %v", skipped, tt.wantSkipped != "")
+			}
+		})
+	}
+}
+
+func TestRuleSet_ScoreRuleError(t *testing.T) {
+	errBroken := errors.New("broken")
+
+	tests := []struct {
+		name        string
+		err         error
+		wantScoring bool
+		wantPointer string
+		wantIs      error
+	}{
+		{
+			name:        "scoring error",
+			err:         &ScoringError{Pointer: "/items/0/price", Item: 0, Err: errBroken},
+			wantScoring: true,
+			wantPointer: "/items/0/price",
+			wantIs:      errBroken,
+		},
+		{
+			name:        "invalid amount",
+			err:         fmt.Errorf("%w: %q", ErrInvalidMoney, "1.2.3"),
+			wantScoring: true,
+			wantIs:      ErrInvalidMoney,
+		},
+		{
+			name:   "plain error",
+			err:    errBroken,
+			wantIs: errBroken,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rs, err := NewRuleSet("2", NewRule("broken", func(Receipt) ([]RuleResult, error) {
+				return nil, tt.err
+			}))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = rs.Score(Receipt{Total: MustParseMoney("1.00")})
+			if !errors.Is(err, tt.wantIs) {
+				t.Fatalf("Score() error = %v, want %v", err, tt.wantIs)
+			}
+
+			var scoringErr *ScoringError
+			if errors.As(err, &scoringErr) != tt.wantScoring {
+				t.Fatalf("Score() error = %v, want a %T: %v", err, scoringErr, tt.wantScoring)
+			}
+			if !tt.wantScoring {
+				return
+			}
+
+			if scoringErr.Rule != "broken" {
+				t.Errorf("Score() error rule = %v, want %v", scoringErr.Rule, "broken")
+			}
+
+			field, ok := scoringErr.FieldError()
+			if ok != (tt.wantPointer != "") || field.Pointer != tt.wantPointer {
+				t.Errorf("FieldError() = %+v, %v, want pointer %q", field, ok, tt.wantPointer)
+			}
+		})
+	}
+}
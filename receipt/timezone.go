@@ -0,0 +1,133 @@
+package receipt
+
+import (
+	"archive/zip"
+	"bytes"
+	_ "embed"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+)
+
+var ErrUnknownTimezone = errors.New("unknown timezone")
+
+// zoneinfo is the IANA tz database, copied from
+// $GOROOT/lib/time/zoneinfo.zip. Time zones are only read from it,
+// never from the host, so a receipt is scored the same on every
+// machine, online or not
+//
+//go:embed zoneinfo.zip
+var zoneinfo []byte
+
+// zones are the files of zoneinfo by time zone name
+var zones = sync.OnceValues(func() (map[string]*zip.File, error) {
+	zr, err := zip.NewReader(bytes.NewReader(zoneinfo), int64(len(zoneinfo)))
+	if err != nil {
+		return nil, err
+	}
+
+	files := make(map[string]*zip.File, len(zr.File))
+	for _, f := range zr.File {
+		files[f.Name] = f
+	}
+
+	return files, nil
+})
+
+// locations caches the time zones read from zoneinfo
+var locations sync.Map
+
+// LoadLocation returns the IANA time zone with the name, e.g.
+// America/Chicago, from the embedded tz database
+func LoadLocation(name string) (*time.Location, error) {
+	if loc, ok := locations.Load(name); ok {
+		return loc.(*time.Location), nil
+	}
+
+	files, err := zones()
+	if err != nil {
+		return nil, err
+	}
+
+	f, ok := files[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownTimezone, name)
+	}
+
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	data, err := io.ReadAll(rc)
+	if err != nil {
+		return nil, err
+	}
+
+	loc, err := time.LoadLocationFromTZData(name, data)
+	if err != nil {
+		return nil, err
+	}
+	locations.Store(name, loc)
+
+	return loc, nil
+}
+
+// Location returns the time zone of the store, the timezone of the
+// receipt or, without one, the offset of purchasedAt. A receipt with
+// neither is in UTC
+func (r Receipt) Location() (*time.Location, error) {
+	switch {
+	case r.Timezone != "":
+		return LoadLocation(r.Timezone)
+	case r.PurchasedAt != nil:
+		_, offset := r.PurchasedAt.Zone()
+		return time.FixedZone("", offset), nil
+	default:
+		return time.UTC, nil
+	}
+}
+
+// LocalTime returns the purchase as an instant in the time zone of
+// the store, see Location. It is purchasedAt if the receipt has
+// one, otherwise the purchase date and time in that time zone
+func (r Receipt) LocalTime() (time.Time, error) {
+	loc, err := r.Location()
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if r.PurchasedAt != nil {
+		return r.PurchasedAt.In(loc), nil
+	}
+
+	d, t := time.Time(r.PurchaseDate), time.Time(r.PurchaseTime)
+	return time.Date(d.Year(), d.Month(), d.Day(), t.Hour(), t.Minute(), 0, 0, loc), nil
+}
+
+// scoreLocal scores the purchase in the local time of the store. The
+// local time is an input of the result of a receipt that is not in
+// UTC by default
+func (r Receipt) scoreLocal(score func(local time.Time) RuleResult) ([]RuleResult, error) {
+	local, err := r.LocalTime()
+	if err != nil {
+		return nil, err
+	}
+
+	result := score(local)
+	if r.Timezone != "" || r.PurchasedAt != nil {
+		result.Inputs["localTime"] = local.Format(time.RFC3339)
+	}
+
+	return []RuleResult{result}, nil
+}
+
+func validateTimezone(fl validator.FieldLevel) bool {
+	_, err := LoadLocation(fl.Field().String())
+	return err == nil
+}
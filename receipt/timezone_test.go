@@ -0,0 +1,283 @@
+package receipt
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestLoadLocation(t *testing.T) {
+	tests := []struct {
+		name    string
+		wantErr bool
+	}{
+		{name: "America/Chicago"},
+		{name: "Asia/Kolkata"},
+		{name: "UTC"},
+		{name: "Mars/Olympus_Mons", wantErr: true},
+		// the time zone of the host is never used
+		{name: "Local", wantErr: true},
+		{name: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loc, err := LoadLocation(tt.name)
+			if tt.wantErr {
+				if !errors.Is(err, ErrUnknownTimezone) {
+					t.Errorf("LoadLocation() error = %v, want %v", err, ErrUnknownTimezone)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("LoadLocation() error = %v", err)
+			}
+			if loc.String() != tt.name {
+				t.Errorf("LoadLocation() = %s, want %s", loc, tt.name)
+			}
+		})
+	}
+}
+
+func TestReceipt_LocalTime(t *testing.T) {
+	tests := []struct {
+		name           string
+		fields         string
+		want           string
+		wantPointer    string
+		wantConstraint string
+	}{
+		{
+			name:   "no time zone is UTC",
+			fields: `"purchaseDate": "2022-01-01", "purchaseTime": "13:01"`,
+			want:   "2022-01-01T13:01:00Z",
+		},
+		{
+			name:   "timezone",
+			fields: `"purchaseDate": "2022-01-01", "purchaseTime": "13:01", "timezone": "America/Chicago"`,
+			want:   "2022-01-01T13:01:00-06:00",
+		},
+		{
+			name:   "purchasedAt in its own offset",
+			fields: `"purchaseDate": "2022-01-01", "purchaseTime": "13:01", "purchasedAt": "2022-01-01T13:01:00+05:30"`,
+			want:   "2022-01-01T13:01:00+05:30",
+		},
+		{
+			name:   "purchasedAt in UTC at a store in another time zone",
+			fields: `"purchaseDate": "2022-07-01", "purchaseTime": "14:30", "timezone": "America/New_York", "purchasedAt": "2022-07-01T18:30:00Z"`,
+			want:   "2022-07-01T14:30:00-04:00",
+		},
+		{
+			name:           "purchasedAt that is not the purchase time",
+			fields:         `"purchaseDate": "2022-01-01", "purchaseTime": "13:01", "timezone": "America/Chicago", "purchasedAt": "2022-01-01T13:01:00Z"`,
+			want:           "2022-01-01T07:01:00-06:00",
+			wantPointer:    "/purchasedAt",
+			wantConstraint: ConstraintLocalTime,
+		},
+		{
+			name:           "time skipped when the clocks go forward",
+			fields:         `"purchaseDate": "2024-03-10", "purchaseTime": "02:30", "timezone": "America/New_York"`,
+			want:           "2024-03-10T01:30:00-05:00",
+			wantPointer:    "/purchaseTime",
+			wantConstraint: ConstraintLocalTime,
+		},
+		{
+			name:   "purchasedAt without the purchase date and time",
+			fields: `"timezone": "America/New_York", "purchasedAt": "2022-07-01T18:30:00Z"`,
+			want:   "2022-07-01T14:30:00-04:00",
+		},
+		{
+			name:           "unknown timezone",
+			fields:         `"purchaseDate": "2022-01-01", "purchaseTime": "13:01", "timezone": "America/Gotham"`,
+			wantPointer:    "/timezone",
+			wantConstraint: "timezone",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := `{"retailer": "Target", "total": "6.49", "items": [{"shortDescription": "Mountain Dew 12PK", "price": "6.49"}], ` + tt.fields + `}`
+
+			var r Receipt
+			if err := json.Unmarshal([]byte(body), &r); err != nil {
+				t.Fatalf("json.Unmarshal() error = %v", err)
+			}
+
+			if tt.want != "" {
+				local, err := r.LocalTime()
+				if err != nil {
+					t.Fatalf("LocalTime() error = %v", err)
+				}
+				if got := local.Format("2006-01-02T15:04:05Z07:00"); got != tt.want {
+					t.Errorf("LocalTime() = %s, want %s", got, tt.want)
+				}
+			}
+
+			_, err := r.ValidateReceipt(NewValidator())
+			if tt.wantPointer == "" {
+				if err != nil {
+					t.Errorf("expected no error but got %q", err)
+				}
+				return
+			}
+
+			var validationErr *ValidationError
+			if !errors.As(err, &validationErr) {
+				t.Fatalf("expected a *ValidationError but got %T", err)
+			}
+
+			got := validationErr.Fields
+			if len(got) != 1 || got[0].Pointer != tt.wantPointer || got[0].Constraint != tt.wantConstraint {
+				t.Errorf("expected %s %s but got %+v", tt.wantPointer, tt.wantConstraint, got)
+			}
+		})
+	}
+}
+
+func TestReceipt_PurchasedAt(t *testing.T) {
+	tests := []struct {
+		name         string
+		fields       string
+		wantPurchase string
+		wantMissing  []string
+	}{
+		{
+			name:         "local time of purchasedAt in its offset",
+			fields:       `"purchasedAt": "2022-01-01T23:30:59-06:00"`,
+			wantPurchase: "2022-01-01 23:30",
+		},
+		{
+			name:         "local time of purchasedAt in the timezone",
+			fields:       `"timezone": "Asia/Tokyo", "purchasedAt": "2022-07-01T18:30:00Z"`,
+			wantPurchase: "2022-07-02 03:30",
+		},
+		{
+			name:         "purchase time left out",
+			fields:       `"purchaseDate": "2022-07-01", "timezone": "America/New_York", "purchasedAt": "2022-07-01T18:30:00Z"`,
+			wantPurchase: "2022-07-01 14:30",
+		},
+		{
+			name:        "neither",
+			fields:      `"timezone": "America/New_York"`,
+			wantMissing: []string{"/purchaseDate", "/purchaseTime"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := `{"retailer": "Target", "total": "6.49", "items": [{"shortDescription": "Mountain Dew 12PK", "price": "6.49"}], ` + tt.fields + `}`
+
+			var r Receipt
+			if err := json.Unmarshal([]byte(body), &r); err != nil {
+				t.Fatalf("json.Unmarshal() error = %v", err)
+			}
+
+			_, err := r.ValidateReceipt(NewValidator())
+			if tt.wantMissing == nil {
+				if err != nil {
+					t.Fatalf("expected no error but got %q", err)
+				}
+
+				got := time.Time(r.PurchaseDate).Format(time.DateOnly) + " " + time.Time(r.PurchaseTime).Format(timeOnly)
+				if got != tt.wantPurchase {
+					t.Errorf("purchase date and time = %s, want %s", got, tt.wantPurchase)
+				}
+				return
+			}
+
+			var validationErr *ValidationError
+			if !errors.As(err, &validationErr) {
+				t.Fatalf("expected a *ValidationError but got %T", err)
+			}
+
+			var got []string
+			for _, f := range validationErr.Fields {
+				if f.Constraint != "required" || f.Value != nil {
+					t.Errorf("expected %s to be reported as required but got %+v", f.Pointer, f)
+				}
+				got = append(got, f.Pointer)
+			}
+			if !reflect.DeepEqual(got, tt.wantMissing) {
+				t.Errorf("expected %v to be missing but got %v", tt.wantMissing, got)
+			}
+		})
+	}
+}
+
+func TestRuleSet_ScoreLocalTime(t *testing.T) {
+	// the same purchase, at 6:30pm UTC, is 2:30pm on an odd day in
+	// New York and 3:30am on an even day in Tokyo
+	tests := []struct {
+		name          string
+		fields        string
+		wantDay       int
+		wantTime      int
+		wantLocalTime string
+	}{
+		{
+			name:     "UTC",
+			fields:   `"purchaseDate": "2022-07-01", "purchaseTime": "14:30"`,
+			wantDay:  6,
+			wantTime: 10,
+		},
+		{
+			name:          "store in New York",
+			fields:        `"purchaseDate": "2022-07-01", "purchaseTime": "14:30", "timezone": "America/New_York", "purchasedAt": "2022-07-01T18:30:00Z"`,
+			wantDay:       6,
+			wantTime:      10,
+			wantLocalTime: "2022-07-01T14:30:00-04:00",
+		},
+		{
+			name:          "store in New York with only purchasedAt",
+			fields:        `"timezone": "America/New_York", "purchasedAt": "2022-07-01T18:30:00Z"`,
+			wantDay:       6,
+			wantTime:      10,
+			wantLocalTime: "2022-07-01T14:30:00-04:00",
+		},
+		{
+			name:          "store in Tokyo",
+			fields:        `"purchaseDate": "2022-07-02", "purchaseTime": "03:30", "timezone": "Asia/Tokyo", "purchasedAt": "2022-07-01T18:30:00Z"`,
+			wantDay:       0,
+			wantTime:      0,
+			wantLocalTime: "2022-07-02T03:30:00+09:00",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := `{"retailer": "Target", "total": "6.49", "items": [{"shortDescription": "Mountain Dew 12PK", "price": "6.49"}], ` + tt.fields + `}`
+
+			var r Receipt
+			if err := json.Unmarshal([]byte(body), &r); err != nil {
+				t.Fatalf("json.Unmarshal() error = %v", err)
+			}
+
+			breakdown, err := DefaultRuleSet().Score(r)
+			if err != nil {
+				t.Fatalf("Score() error = %v", err)
+			}
+
+			for _, result := range breakdown.Rules {
+				var want int
+				switch result.Rule {
+				case RulePurchaseDayOdd:
+					want = tt.wantDay
+				case RulePurchaseTime:
+					want = tt.wantTime
+				default:
+					continue
+				}
+
+				if result.Points != want {
+					t.Errorf("%s points = %d, want %d", result.Rule, result.Points, want)
+				}
+				if got := result.Inputs["localTime"]; got != tt.wantLocalTime {
+					t.Errorf("%s localTime = %q, want %q", result.Rule, got, tt.wantLocalTime)
+				}
+			}
+		})
+	}
+}
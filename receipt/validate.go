@@ -0,0 +1,138 @@
+package receipt
+
+import (
+	"math/big"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// patterns caches the regular expressions of pattern tags
+var patterns sync.Map
+
+// ConstraintSum is reported for the lines of a receipt, or the
+// quantity and unit price of an item, that do not add up, which is
+// not a constraint of a single field in api.yml
+const ConstraintSum = "sum"
+
+// NewValidator returns a validator with the tags used by Receipt and
+// Item registered:
+//
+//	pattern=<regexp> the string matches the pattern of the field in api.yml
+//	amount           the amount was set and is in the format of api.yml
+//	currency         the string is an ISO 4217 currency, see MinorUnits
+//	timezone         the string is an IANA time zone, see LoadLocation
+//
+// along with checks that the lines of a receipt and the price of
+// every item add up, reported with ConstraintSum, and that the
+// purchase is a local time of the store, reported with
+// ConstraintLocalTime
+func NewValidator() *validator.Validate {
+	v := validator.New(validator.WithRequiredStructEnabled())
+	_ = v.RegisterValidation("pattern", validatePattern)
+	_ = v.RegisterValidation("amount", validateAmount)
+	_ = v.RegisterValidation("currency", validateCurrency)
+	_ = v.RegisterValidation("timezone", validateTimezone)
+	v.RegisterStructValidation(validateReceipt, Receipt{})
+	v.RegisterStructValidation(validateQuantity, Item{})
+
+	return v
+}
+
+func validatePattern(fl validator.FieldLevel) bool {
+	pattern, ok := patterns.Load(fl.Param())
+	if !ok {
+		pattern, _ = patterns.LoadOrStore(fl.Param(), regexp.MustCompile(fl.Param()))
+	}
+
+	return pattern.(*regexp.Regexp).MatchString(fl.Field().String())
+}
+
+func validateAmount(fl validator.FieldLevel) bool {
+	m, ok := fl.Field().Interface().(Money)
+	return ok && m.Valid() && m.Minor() >= 0
+}
+
+// validateReceipt checks the lines and the purchase time of a
+// receipt
+func validateReceipt(sl validator.StructLevel) {
+	validateLines(sl)
+	validateLocalTime(sl)
+}
+
+// validateLines checks the discounts do not exceed the items, and
+// that a subtotal is the items less the discounts and adds up to
+// the total with the tax and tip. Without a subtotal the total is
+// left to reconciliation, which allows for unstated tax
+func validateLines(sl validator.StructLevel) {
+	r := sl.Current().Interface().(Receipt)
+
+	items, discounts := r.ItemsTotal().Minor(), r.DiscountsTotal().Minor()
+	if discounts > items {
+		sl.ReportError(r.Discounts, "Discounts", "Discounts", ConstraintSum, "")
+		return
+	}
+
+	if r.Subtotal == nil {
+		return
+	}
+
+	if r.Subtotal.Minor() != items-discounts {
+		sl.ReportError(r.Subtotal, "Subtotal", "Subtotal", ConstraintSum, "")
+		return
+	}
+
+	if r.Subtotal.Minor()+lineMinor(r.Tax)+lineMinor(r.Tip) != r.Total.Minor() {
+		sl.ReportError(r.Total, "Total", "Total", ConstraintSum, "")
+	}
+}
+
+// validateLocalTime checks the purchase date and time are the local
+// time of the purchase in the time zone of the store, which they are
+// not if the time does not exist there or purchasedAt is another time
+func validateLocalTime(sl validator.StructLevel) {
+	r := sl.Current().Interface().(Receipt)
+
+	// a missing date or time and an unknown timezone are reported
+	// by their own tags
+	if time.Time(r.PurchaseDate).IsZero() || time.Time(r.PurchaseTime).IsZero() {
+		return
+	}
+	local, err := r.LocalTime()
+	if err != nil {
+		return
+	}
+
+	purchased := time.Time(r.PurchaseDate).Format(time.DateOnly) + " " + time.Time(r.PurchaseTime).Format(timeOnly)
+	if local.Format(time.DateOnly+" "+timeOnly) == purchased {
+		return
+	}
+
+	if r.PurchasedAt != nil {
+		sl.ReportError(r.PurchasedAt, "PurchasedAt", "PurchasedAt", ConstraintLocalTime, "")
+		return
+	}
+	sl.ReportError(r.PurchaseTime, "PurchaseTime", "PurchaseTime", ConstraintLocalTime, "")
+}
+
+// validateQuantity checks the price of an item is its quantity
+// times its unit price. Without a unit price the price must divide
+// into a whole number of minor units for every unit
+func validateQuantity(sl validator.StructLevel) {
+	i := sl.Current().Interface().(Item)
+
+	// a quantity below 1 is reported by its own tag
+	units := int64(i.Units())
+	if units < 1 {
+		return
+	}
+
+	// the product is not bounded by the quantity alone, a large unit
+	// price would wrap around to a small price
+	product := new(big.Int).Mul(big.NewInt(i.unitMinor()), big.NewInt(units))
+	if product.Cmp(big.NewInt(i.Price.Minor())) != 0 {
+		sl.ReportError(i.Price, "Price", "Price", ConstraintSum, "")
+	}
+}
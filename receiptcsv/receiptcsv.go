@@ -0,0 +1,360 @@
+// Package receiptcsv reads and writes receipts as CSV, with a row
+// for every item of a receipt
+package receiptcsv
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/afranco07/receipt-processor/database"
+	"github.com/afranco07/receipt-processor/receipt"
+)
+
+var ErrInvalidCSV = errors.New("invalid csv")
+
+// Columns of a receipt CSV. Rows with the same receipt key are
+// the items of a single receipt
+const (
+	ColumnReceipt          = "receipt"
+	ColumnRetailer         = "retailer"
+	ColumnPurchaseDate     = "purchaseDate"
+	ColumnPurchaseTime     = "purchaseTime"
+	ColumnTotal            = "total"
+	ColumnShortDescription = "shortDescription"
+	ColumnPrice            = "price"
+	ColumnQuantity         = "quantity"
+	ColumnUnitPrice        = "unitPrice"
+	ColumnSubtotal         = "subtotal"
+	ColumnTax              = "tax"
+	ColumnTip              = "tip"
+	ColumnCurrency         = "currency"
+	ColumnTimezone         = "timezone"
+	ColumnPurchasedAt      = "purchasedAt"
+	ColumnDiscount         = "discount"
+	ColumnDiscountAmount   = "discountAmount"
+	ColumnPoints           = "points"
+	ColumnVersion          = "version"
+	ColumnReceivedAt       = "receivedAt"
+)
+
+// receiptColumns are the columns that must be the same in every
+// row of a receipt
+var receiptColumns = []string{ColumnRetailer, ColumnPurchaseDate, ColumnPurchaseTime, ColumnTotal}
+
+// lineColumns are the optional columns that must be the same in
+// every row of a receipt
+var lineColumns = []string{ColumnSubtotal, ColumnTax, ColumnTip, ColumnCurrency, ColumnTimezone, ColumnPurchasedAt}
+
+// importColumns are the columns Read requires. The optional item,
+// line and discount columns are read if they are present, and any other
+// column is ignored
+var importColumns = slices.Concat([]string{ColumnReceipt}, receiptColumns, []string{ColumnShortDescription, ColumnPrice})
+
+// itemColumns are the optional columns of an item
+var itemColumns = []string{ColumnQuantity, ColumnUnitPrice}
+
+// exportColumns are the columns written by Writer. An export can
+// be imported again
+var exportColumns = slices.Concat(importColumns, itemColumns, lineColumns, []string{ColumnDiscount, ColumnDiscountAmount},
+	[]string{ColumnPoints, ColumnVersion, ColumnReceivedAt})
+
+// Record is a receipt read from a CSV
+type Record struct {
+	// Key is the value of the receipt column
+	Key string
+	// Line is the line of the first row of the receipt
+	Line    int
+	Receipt receipt.Receipt
+	// Err is why the rows could not be read as a receipt, such as
+	// an invalid date. The receipt still needs to be validated
+	Err error
+}
+
+// group is the rows read for a receipt
+type group struct {
+	line      int
+	fields    map[string]string
+	items     []map[string]string
+	discounts []map[string]string
+	err       error
+}
+
+// readOptions are the settings of Read
+type readOptions struct {
+	dates receipt.DateFormats
+}
+
+// ReadOption configures Read
+type ReadOption func(*readOptions)
+
+// WithDateFormats sets the formats the purchase dates and times are
+// read in. Only the formats of api.yml are read otherwise
+func WithDateFormats(f receipt.DateFormats) ReadOption {
+	return func(o *readOptions) {
+		o.dates = f
+	}
+}
+
+// Read reads the receipts in a CSV with a header row. The rows of
+// a receipt do not need to be next to each other, and receipts are
+// returned in the order they first appear. An error is only
+// returned if the CSV itself cannot be read
+func Read(r io.Reader, opts ...ReadOption) ([]Record, error) {
+	var o readOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	cr := csv.NewReader(r)
+
+	header, err := cr.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("%w: missing header row", ErrInvalidCSV)
+	} else if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCSV, err)
+	}
+
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[strings.TrimSpace(name)] = i
+	}
+
+	var missing []string
+	for _, name := range importColumns {
+		if _, ok := columns[name]; !ok {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("%w: missing columns %s", ErrInvalidCSV, strings.Join(missing, ", "))
+	}
+
+	groups := make(map[string]*group)
+	var keys []string
+	for {
+		row, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidCSV, err)
+		}
+
+		line, _ := cr.FieldPos(0)
+		// values are kept as they are, the spaces around an item
+		// description are part of the receipt. Optional columns
+		// that are missing are empty
+		value := func(name string) string {
+			i, ok := columns[name]
+			if !ok {
+				return ""
+			}
+			return row[i]
+		}
+
+		key := strings.TrimSpace(value(ColumnReceipt))
+		if key == "" {
+			return nil, fmt.Errorf("%w: line %d has no %s", ErrInvalidCSV, line, ColumnReceipt)
+		}
+
+		g, ok := groups[key]
+		if !ok {
+			g = &group{line: line, fields: make(map[string]string)}
+			for _, name := range slices.Concat(receiptColumns, lineColumns) {
+				g.fields[name] = value(name)
+			}
+			groups[key] = g
+			keys = append(keys, key)
+		}
+
+		for _, name := range slices.Concat(receiptColumns, lineColumns) {
+			if v := value(name); v != g.fields[name] && g.err == nil {
+				g.err = fmt.Errorf("%w: %s %q on line %d does not match %q on line %d", ErrInvalidCSV, name, v, line, g.fields[name], g.line)
+			}
+		}
+
+		// a row without an item only has the receipt columns, and a
+		// row can have a discount along with an item
+		if value(ColumnShortDescription) != "" || value(ColumnPrice) != "" {
+			g.items = append(g.items, map[string]string{
+				ColumnShortDescription: value(ColumnShortDescription),
+				ColumnPrice:            value(ColumnPrice),
+				ColumnQuantity:         value(ColumnQuantity),
+				ColumnUnitPrice:        value(ColumnUnitPrice),
+			})
+		}
+
+		if value(ColumnDiscount) != "" || value(ColumnDiscountAmount) != "" {
+			g.discounts = append(g.discounts, map[string]string{
+				"description": value(ColumnDiscount),
+				"amount":      value(ColumnDiscountAmount),
+			})
+		}
+	}
+
+	records := make([]Record, 0, len(keys))
+	for _, key := range keys {
+		g := groups[key]
+		record := Record{Key: key, Line: g.line, Err: g.err}
+		if record.Err == nil {
+			record.Receipt, record.Err = g.receipt(o.dates)
+		}
+		records = append(records, record)
+	}
+
+	return records, nil
+}
+
+// receipt parses the rows the same way a JSON receipt is parsed.
+// Empty columns are left out, as if they were missing from the JSON
+func (g *group) receipt(dates receipt.DateFormats) (receipt.Receipt, error) {
+	body := make(map[string]any)
+	for name, v := range g.fields {
+		if v != "" {
+			body[name] = v
+		}
+	}
+
+	items := nonEmpty(g.items)
+	for _, item := range items {
+		// a quantity is a number in JSON. One that is not an integer
+		// is left as a string to be reported like any other value
+		// of the wrong type
+		if q, ok := item[ColumnQuantity].(string); ok {
+			if n, err := strconv.Atoi(q); err == nil {
+				item[ColumnQuantity] = n
+			}
+		}
+	}
+	body["items"] = items
+	if len(g.discounts) > 0 {
+		body["discounts"] = nonEmpty(g.discounts)
+	}
+
+	b, err := json.Marshal(body)
+	if err != nil {
+		return receipt.Receipt{}, err
+	}
+
+	var rcpt receipt.Receipt
+	if err := dates.Unmarshal(b, &rcpt); err != nil {
+		return receipt.Receipt{}, err
+	}
+
+	return rcpt, nil
+}
+
+// nonEmpty returns the rows without their empty columns
+func nonEmpty(rows []map[string]string) []map[string]any {
+	objects := make([]map[string]any, 0, len(rows))
+	for _, row := range rows {
+		fields := make(map[string]any)
+		for name, v := range row {
+			if v != "" {
+				fields[name] = v
+			}
+		}
+		objects = append(objects, fields)
+	}
+
+	return objects
+}
+
+// Writer writes stored receipts with their points as CSV
+type Writer struct {
+	w           *csv.Writer
+	wroteHeader bool
+}
+
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: csv.NewWriter(w)}
+}
+
+// Write writes a row for every item of the receipt, keyed by its id
+func (w *Writer) Write(l database.ListedReceipt) error {
+	if err := w.writeHeader(); err != nil {
+		return err
+	}
+
+	// the dates and times are written as they are in JSON
+	b, err := json.Marshal(l.Receipt)
+	if err != nil {
+		return err
+	}
+
+	var fields struct {
+		PurchaseDate string `json:"purchaseDate"`
+		PurchaseTime string `json:"purchaseTime"`
+		PurchasedAt  string `json:"purchasedAt"`
+	}
+	if err := json.Unmarshal(b, &fields); err != nil {
+		return err
+	}
+
+	var receivedAt string
+	if !l.ReceivedAt.IsZero() {
+		receivedAt = l.ReceivedAt.Format(time.RFC3339Nano)
+	}
+
+	line := func(m *receipt.Money) string {
+		if m == nil {
+			return ""
+		}
+		return m.String()
+	}
+
+	// items and discounts share rows, so a receipt has a row for
+	// every item or discount, whichever it has more of
+	rows := max(len(l.Receipt.Items), len(l.Receipt.Discounts), 1)
+	for i := range rows {
+		var shortDescription, price, quantity, unitPrice, discount, discountAmount string
+		if i < len(l.Receipt.Items) {
+			item := l.Receipt.Items[i]
+			shortDescription, price, unitPrice = item.ShortDescription, item.Price.String(), line(item.UnitPrice)
+			if item.Quantity != nil {
+				quantity = strconv.Itoa(*item.Quantity)
+			}
+		}
+		if i < len(l.Receipt.Discounts) {
+			discount, discountAmount = l.Receipt.Discounts[i].Description, l.Receipt.Discounts[i].Amount.String()
+		}
+
+		err := w.w.Write([]string{
+			l.ID, l.Receipt.Retailer, fields.PurchaseDate, fields.PurchaseTime, l.Receipt.Total.String(),
+			shortDescription, price, quantity, unitPrice, line(l.Receipt.Subtotal), line(l.Receipt.Tax), line(l.Receipt.Tip),
+			l.Receipt.Currency, l.Receipt.Timezone, fields.PurchasedAt, discount, discountAmount, strconv.Itoa(l.Points), l.Version, receivedAt,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Flush writes any buffered rows, and the header if no receipt
+// was written
+func (w *Writer) Flush() error {
+	if err := w.writeHeader(); err != nil {
+		return err
+	}
+
+	w.w.Flush()
+
+	return w.w.Error()
+}
+
+func (w *Writer) writeHeader() error {
+	if w.wroteHeader {
+		return nil
+	}
+	w.wroteHeader = true
+
+	return w.w.Write(exportColumns)
+}
@@ -0,0 +1,223 @@
+package receiptcsv
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/afranco07/receipt-processor/database"
+	"github.com/afranco07/receipt-processor/receipt"
+)
+
+const header = "receipt,retailer,purchaseDate,purchaseTime,total,shortDescription,price\n"
+
+func TestRead(t *testing.T) {
+	tests := []struct {
+		name       string
+		csv        string
+		wantKeys   []string
+		wantItems  []int
+		opts       []ReadOption
+		wantErrors []bool
+		wantErr    error
+	}{
+		{
+			name: "rows are grouped by receipt in the order they first appear",
+			csv: header +
+				"a,Target,2022-01-01,13:01,35.35,Mountain Dew 12PK,6.49\n" +
+				"b,Walgreens,2022-01-02,08:13,2.65,Pepsi - 12-oz,1.25\n" +
+				"a,Target,2022-01-01,13:01,35.35,Emils Cheese Pizza,12.25\n",
+			wantKeys:   []string{"a", "b"},
+			wantItems:  []int{2, 1},
+			wantErrors: []bool{false, false},
+		},
+		{
+			name: "columns in any order and extra columns are ignored",
+			csv: "price,shortDescription,total,purchaseTime,purchaseDate,retailer,receipt,points\n" +
+				"1.25,Pepsi - 12-oz,1.25,13:13,2022-01-02,Target,a,31\n",
+			wantKeys:   []string{"a"},
+			wantItems:  []int{1},
+			wantErrors: []bool{false},
+		},
+		{
+			name: "receipts that cannot be parsed are reported on their own",
+			csv: header +
+				"a,Target,2022-13-01,13:01,35.35,Mountain Dew 12PK,6.49\n" +
+				"b,Walgreens,2022-01-02,08:13,2.65,Pepsi - 12-oz,1.2\n" +
+				"c,Target,2022-01-01,13:01,1.25,Pepsi - 12-oz,1.25\n" +
+				"c,Target,2022-01-01,13:02,1.25,Pepsi - 12-oz,1.25\n" +
+				"d,Target,2022-01-01,13:01,1.25,Pepsi - 12-oz,1.25\n",
+			wantKeys:   []string{"a", "b", "c", "d"},
+			wantItems:  []int{0, 0, 0, 1},
+			wantErrors: []bool{true, true, true, false},
+		},
+		{
+			name: "amounts in the currency of the receipt",
+			csv: strings.TrimSuffix(header, "\n") + ",currency\n" +
+				"a,Lawson,2022-01-01,13:01,1200,Onigiri,1200,JPY\n" +
+				"b,Lawson,2022-01-01,13:01,12.00,Onigiri,12.00,JPY\n",
+			wantKeys:   []string{"a", "b"},
+			wantItems:  []int{1, 0},
+			wantErrors: []bool{false, true},
+		},
+		{
+			name: "time zones and times of the purchase",
+			csv: strings.TrimSuffix(header, "\n") + ",timezone,purchasedAt\n" +
+				"a,Target,2022-07-01,14:30,1.25,Pepsi - 12-oz,1.25,America/New_York,2022-07-01T18:30:00Z\n" +
+				"b,Target,2022-07-01,14:30,1.25,Pepsi - 12-oz,1.25,,2022-07-01 18:30\n",
+			wantKeys:   []string{"a", "b"},
+			wantItems:  []int{1, 0},
+			wantErrors: []bool{false, true},
+		},
+		{
+			name: "date formats",
+			csv: header +
+				"a,Target,12/25/2022,1:13 PM,1.25,Pepsi - 12-oz,1.25\n" +
+				"b,Target,01/02/2022,1:13 PM,1.25,Pepsi - 12-oz,1.25\n",
+			opts: []ReadOption{WithDateFormats(receipt.DateFormats{
+				DateLayouts: []string{"01/02/2006", "02/01/2006"},
+				TimeLayouts: []string{"3:04 PM"},
+			})},
+			wantKeys:   []string{"a", "b"},
+			wantItems:  []int{1, 0},
+			wantErrors: []bool{false, true},
+		},
+		{
+			name:    "missing columns",
+			csv:     "receipt,retailer\na,Target\n",
+			wantErr: ErrInvalidCSV,
+		},
+		{
+			name:    "missing receipt key",
+			csv:     header + ",Target,2022-01-01,13:01,1.25,Pepsi - 12-oz,1.25\n",
+			wantErr: ErrInvalidCSV,
+		},
+		{
+			name:    "empty",
+			csv:     "",
+			wantErr: ErrInvalidCSV,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			records, err := Read(strings.NewReader(tt.csv), tt.opts...)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Read() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if len(records) != len(tt.wantKeys) {
+				t.Fatalf("Read() = %d records, want %d", len(records), len(tt.wantKeys))
+			}
+
+			for i, record := range records {
+				if record.Key != tt.wantKeys[i] || len(record.Receipt.Items) != tt.wantItems[i] || (record.Err != nil) != tt.wantErrors[i] {
+					t.Errorf("record %d = %s with %d items and error %v, want %s with %d items", i, record.Key, len(record.Receipt.Items), record.Err, tt.wantKeys[i], tt.wantItems[i])
+				}
+			}
+		})
+	}
+}
+
+func TestWriter_RoundTrip(t *testing.T) {
+	records, err := Read(strings.NewReader(header +
+		"a,Target,2022-01-01,13:01,35.35,   Klarbrunn 12-PK 12 FL OZ  ,12.00\n" +
+		"a,Target,2022-01-01,13:01,35.35,\"Emils Cheese, Pizza\",12.25\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	listed := database.ListedReceipt{
+		StoredReceipt: database.StoredReceipt{
+			ID:         "7fb1377b-b223-49d9-a31a-5a02701dd310",
+			Receipt:    records[0].Receipt,
+			ReceivedAt: time.Date(2022, 1, 1, 14, 0, 0, 0, time.UTC),
+		},
+		Points:  28,
+		Version: "1",
+	}
+	if err := w.Write(listed); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "receipt,retailer,purchaseDate,purchaseTime,total,shortDescription,price,quantity,unitPrice,subtotal,tax,tip,currency,timezone,purchasedAt,discount,discountAmount,points,version,receivedAt\n" +
+		"7fb1377b-b223-49d9-a31a-5a02701dd310,Target,2022-01-01,13:01,35.35,\"   Klarbrunn 12-PK 12 FL OZ  \",12.00,,,,,,,,,,,28,1,2022-01-01T14:00:00Z\n" +
+		"7fb1377b-b223-49d9-a31a-5a02701dd310,Target,2022-01-01,13:01,35.35,\"Emils Cheese, Pizza\",12.25,,,,,,,,,,,28,1,2022-01-01T14:00:00Z\n"
+	if buf.String() != want {
+		t.Errorf("Write() = %q, want %q", buf.String(), want)
+	}
+
+	// an export can be imported again
+	again, err := Read(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(again) != 1 || again[0].Err != nil || again[0].Receipt.Items[0].ShortDescription != "   Klarbrunn 12-PK 12 FL OZ  " {
+		t.Errorf("Read() of the export = %+v", again)
+	}
+}
+
+func TestWriter_RoundTripLines(t *testing.T) {
+	records, err := Read(strings.NewReader(strings.TrimSuffix(header, "\n") + ",quantity,unitPrice,subtotal,tax,tip,discount,discountAmount\n" +
+		"a,Target,2022-01-01,13:01,11.80,Gatorade,6.75,3,2.25,10.00,0.80,1.00,Store Coupon,1.00\n" +
+		"a,Target,2022-01-01,13:01,11.80,Emils Cheese Pizza,4.25,,,10.00,0.80,1.00,Loyalty Discount,0.00\n" +
+		"a,Target,2022-01-01,13:01,11.80,,,,,10.00,0.80,1.00,Manager Special,0.00\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(records) != 1 || records[0].Err != nil {
+		t.Fatalf("Read() = %+v", records)
+	}
+
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	if err := w.Write(database.ListedReceipt{StoredReceipt: database.StoredReceipt{ID: "a", Receipt: records[0].Receipt}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	again, err := Read(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want, _ := json.Marshal(records[0].Receipt)
+	if len(again) != 1 || again[0].Err != nil {
+		t.Fatalf("Read() of the export = %+v", again)
+	}
+	if got, _ := json.Marshal(again[0].Receipt); string(got) != string(want) {
+		t.Errorf("Read() of the export = %s, want %s", got, want)
+	}
+
+	if len(again[0].Receipt.Items) != 2 || len(again[0].Receipt.Discounts) != 3 || again[0].Receipt.Tax.String() != "0.80" {
+		t.Errorf("Read() of the export = %s, want 2 items and 3 discounts", want)
+	}
+
+	if again[0].Receipt.Units() != 4 {
+		t.Errorf("Read() of the export = %s, want 4 units", want)
+	}
+}
+
+func TestWriter_Empty(t *testing.T) {
+	var buf bytes.Buffer
+	if err := NewWriter(&buf).Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.HasPrefix(buf.String(), "receipt,") || strings.Count(buf.String(), "\n") != 1 {
+		t.Errorf("Flush() = %q, want only the header", buf.String())
+	}
+}